@@ -2,4 +2,33 @@ module github.com/ameena3/tesla/backend
 
 go 1.24.2
 
-require github.com/teslamotors/vehicle-command v0.3.4 // indirect
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/teslamotors/vehicle-command v0.3.4
+	google.golang.org/protobuf v1.34.2
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/99designs/go-keychain v0.0.0-20191008050251-8e49817e8af4 // indirect
+	github.com/99designs/keyring v1.2.2 // indirect
+	github.com/JuulLabs-OSS/cbgo v0.0.1 // indirect
+	github.com/cronokirby/saferith v0.33.0 // indirect
+	github.com/danieljoos/wincred v1.2.0 // indirect
+	github.com/dvsekhvalnov/jose2go v1.6.0 // indirect
+	github.com/go-ble/ble v0.0.0-20240122180141-8c5522f54333 // indirect
+	github.com/godbus/dbus v0.0.0-20190726142602-4481cbc300e2 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
+	github.com/gsterjov/go-libsecret v0.0.0-20161001094733-a6f4afe4910c // indirect
+	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/mattn/go-colorable v0.1.6 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
+	github.com/mgutz/logxi v0.0.0-20161027140823-aebf8a7d67ab // indirect
+	github.com/mtibben/percent v0.2.1 // indirect
+	github.com/pkg/errors v0.8.1 // indirect
+	github.com/raff/goble v0.0.0-20190909174656-72afc67d6a99 // indirect
+	github.com/sirupsen/logrus v1.5.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+	golang.org/x/term v0.5.0 // indirect
+)
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ameena3/tesla/backend/tesla/teslacam"
+)
+
+// cameraArchiveAvailable writes 503 unless a TeslaCam archive is configured.
+func (s *Server) cameraArchiveAvailable(w http.ResponseWriter) bool {
+	if s.cameraArchive == nil {
+		writeError(w, http.StatusServiceUnavailable, "TeslaCam archive not configured. Set TESLA_CAMERA_DIR.")
+		return false
+	}
+	return true
+}
+
+// writeArchiveError writes 404 for an unknown event or clip, or a missing thumbnail or telemetry,
+// and 500 for anything else.
+func writeArchiveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, teslacam.ErrEventNotFound) || errors.Is(err, teslacam.ErrNoThumbnail) || errors.Is(err, teslacam.ErrNoTelemetry) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// clipURL is the path CameraClipHandler serves clip of event from, in the API version r arrived
+// on: under /api/v1 for a versioned request, under the unversioned /api otherwise.
+func clipURL(r *http.Request, event teslacam.Event, clip teslacam.Clip) string {
+	prefix := "/api"
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		prefix = "/api/v1"
+	}
+	return prefix + "/camera/events/" + url.PathEscape(event.ID) + "/clips/" + url.PathEscape(clip.Name)
+}
+
+// CameraEventsHandler lists the TeslaCam events, newest first. ?kind=recent,saved,sentry keeps
+// only the given kinds and ?limit=n the first n events. ?telemetry=true overlays each event with
+// a summary of its telemetry, which reads every front clip the first time.
+func (s *Server) CameraEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cameraArchiveAvailable(w) {
+		return
+	}
+	var kinds []teslacam.Kind
+	for _, s := range strings.Split(r.URL.Query().Get("kind"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		kind, err := teslacam.ParseKind(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		kinds = append(kinds, kind)
+	}
+	limit := -1
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		limit = n
+	}
+	withTelemetry := r.URL.Query().Get("telemetry") == "true"
+
+	events, err := s.cameraArchive.Events()
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	matching := []teslacam.Event{}
+	for _, e := range events {
+		if limit >= 0 && len(matching) == limit {
+			break
+		}
+		if len(kinds) == 0 || slices.Contains(kinds, e.Kind) {
+			if withTelemetry {
+				e.Telemetry = s.eventTelemetry(e)
+			}
+			matching = append(matching, e)
+		}
+	}
+	WriteJsonResponse(w, http.StatusOK, map[string]interface{}{"events": matching})
+}
+
+// eventTelemetry returns the telemetry summary of event, or nil if its clips have none.
+func (s *Server) eventTelemetry(event teslacam.Event) *teslacam.TelemetrySummary {
+	summary, err := s.cameraArchive.EventTelemetry(event.ID)
+	if err != nil && !errors.Is(err, teslacam.ErrNoTelemetry) {
+		s.logger.Printf("Reading telemetry of TeslaCam event %s: %v", event.ID, err)
+	}
+	return summary
+}
+
+// CameraEventHandler describes the TeslaCam event named by {id}, with its telemetry summary.
+func (s *Server) CameraEventHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cameraArchiveAvailable(w) {
+		return
+	}
+	event, err := s.cameraArchive.Event(r.PathValue("id"))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	event.Telemetry = s.eventTelemetry(event)
+	WriteJsonResponse(w, http.StatusOK, event)
+}
+
+// CameraClipHandler streams the MP4 clip {name} of the TeslaCam event {id}. Range requests are
+// honoured, so browsers can seek without downloading the whole clip.
+func (s *Server) CameraClipHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cameraArchiveAvailable(w) {
+		return
+	}
+	f, clip, err := s.cameraArchive.OpenClip(r.PathValue("id"), r.PathValue("name"))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "video/mp4")
+	http.ServeContent(w, r, clip.Name, info.ModTime(), f)
+}
+
+// serveLatestClip writes the URL of the newest clip in the archive, preferring the front camera,
+// in the shape serveCameraFeed uses.
+func (s *Server) serveLatestClip(w http.ResponseWriter, r *http.Request) {
+	events, err := s.cameraArchive.Events()
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	if len(events) == 0 {
+		writeError(w, http.StatusNotFound, "TeslaCam archive has no clips yet")
+		return
+	}
+	latest := events[0]
+	clip := latest.Clips[len(latest.Clips)-1]
+	for _, c := range latest.Clips {
+		if c.Camera == "front" && !c.Start.Before(clip.Start) {
+			clip = c
+		}
+	}
+	WriteJsonResponse(w, http.StatusOK, map[string]string{"camera_feed_url": clipURL(r, latest, clip), "event_id": latest.ID})
+}
+
+// CameraTimelineHandler writes the playback manifest of the TeslaCam event {id}: every angle of
+// every segment on one clock, and each camera's files stitched into continuous ranges, with the
+// URLs to stream them from.
+func (s *Server) CameraTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cameraArchiveAvailable(w) {
+		return
+	}
+	event, err := s.cameraArchive.Event(r.PathValue("id"))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	timeline, err := s.cameraArchive.Timeline(event.ID)
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	for i := range timeline.Segments {
+		addClipURLs(r, event, timeline.Segments[i].Clips)
+	}
+	for i := range timeline.Tracks {
+		for j := range timeline.Tracks[i].Ranges {
+			addClipURLs(r, event, timeline.Tracks[i].Ranges[j].Clips)
+		}
+	}
+	WriteJsonResponse(w, http.StatusOK, timeline)
+}
+
+func addClipURLs(r *http.Request, event teslacam.Event, clips []teslacam.TimelineClip) {
+	for i := range clips {
+		clips[i].URL = clipURL(r, event, clips[i].Clip)
+	}
+}
+
+// CameraThumbnailHandler serves a thumbnail image of the TeslaCam event {id}.
+func (s *Server) CameraThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cameraArchiveAvailable(w) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Camera)
+	defer cancel()
+	path, err := s.cameraArchive.Thumbnail(ctx, r.PathValue("id"))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "max-age=3600")
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
+}
+
+// CameraClipTelemetryHandler writes the per-frame telemetry of the clip {name} of the TeslaCam
+// event {id}: speed, gear, steering, pedals, autopilot state and position. ?format=gpx writes the
+// positions as a GPX track instead.
+func (s *Server) CameraClipTelemetryHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cameraArchiveAvailable(w) {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "gpx" {
+		writeError(w, http.StatusBadRequest, "format must be json or gpx")
+		return
+	}
+	telemetry, err := s.cameraArchive.ClipTelemetry(r.PathValue("id"), r.PathValue("name"))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	if format != "gpx" {
+		WriteJsonResponse(w, http.StatusOK, telemetry)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gpx+xml")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.TrimSuffix(telemetry.Clip, ".mp4")+`.gpx"`)
+	if err := telemetry.WriteGPX(w); err != nil {
+		s.logger.Printf("Writing GPX for %s: %v", telemetry.Clip, err)
+	}
+}
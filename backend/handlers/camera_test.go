@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+	"github.com/ameena3/tesla/backend/tesla/teslacam"
+)
+
+// newCameraTestServer returns a Server whose camera archive is a TeslaCam folder with one saved
+// event and one recent segment.
+func newCameraTestServer(t *testing.T) *Server {
+	t.Helper()
+	root := t.TempDir()
+	for name, contents := range map[string]string{
+		"SavedClips/2024-05-01_10-15-30/2024-05-01_10-14-30-front.mp4": "0123456789",
+		"SavedClips/2024-05-01_10-15-30/event.json":                    `{"timestamp":"2024-05-01T10:15:30","reason":"user_interaction_honk"}`,
+		"RecentClips/2024-05-01_09-00-00-back.mp4":                     "back",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := teslacam.DefaultArchiveOptions()
+	opts.Location = time.UTC
+	opts.ThumbnailDir = t.TempDir()
+	opts.FFmpeg = ""
+	archive, err := teslacam.NewArchive(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestServer(Config{CameraArchive: archive})
+}
+
+func TestCameraEventsHandler(t *testing.T) {
+	s := newCameraTestServer(t)
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"saved-2024-05-01_10-15-30", "recent-2024-05-01_09-00-00"}},
+		{"?kind=recent", []string{"recent-2024-05-01_09-00-00"}},
+		{"?limit=1", []string{"saved-2024-05-01_10-15-30"}},
+	} {
+		req, _ := http.NewRequest("GET", "/api/camera/events"+tc.query, nil)
+		rr := httptest.NewRecorder()
+		s.CameraEventsHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: got status %v want %v", tc.query, rr.Code, http.StatusOK)
+		}
+		var body struct {
+			Events []teslacam.Event `json:"events"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%q: invalid JSON: %v", tc.query, err)
+		}
+		var ids []string
+		for _, e := range body.Events {
+			ids = append(ids, e.ID)
+		}
+		if len(ids) != len(tc.want) || (len(ids) > 0 && ids[0] != tc.want[0]) {
+			t.Errorf("%q: got events %v want %v", tc.query, ids, tc.want)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/api/camera/events?kind=dashcam", nil)
+	rr := httptest.NewRecorder()
+	s.CameraEventsHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown kind: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCameraEventsHandler_NotConfigured(t *testing.T) {
+	s := newTestServer(Config{})
+	req, _ := http.NewRequest("GET", "/api/camera/events", nil)
+	rr := httptest.NewRecorder()
+	s.CameraEventsHandler(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestCameraClipHandler(t *testing.T) {
+	s := newCameraTestServer(t)
+
+	get := func(name, rangeHeader string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/camera/events/saved-2024-05-01_10-15-30/clips/"+name, nil)
+		req.SetPathValue("id", "saved-2024-05-01_10-15-30")
+		req.SetPathValue("name", name)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rr := httptest.NewRecorder()
+		s.CameraClipHandler(rr, req)
+		return rr
+	}
+
+	rr := get("2024-05-01_10-14-30-front.mp4", "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "0123456789" || rr.Header().Get("Content-Type") != "video/mp4" {
+		t.Errorf("full clip: got %v %q (%s)", rr.Code, rr.Body.String(), rr.Header().Get("Content-Type"))
+	}
+	rr = get("2024-05-01_10-14-30-front.mp4", "bytes=2-5")
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "2345" || rr.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("range: got %v %q (%s)", rr.Code, rr.Body.String(), rr.Header().Get("Content-Range"))
+	}
+	if rr := get("event.json", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("non-clip file: got status %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestServeCameraFeed_FallsBackToArchive(t *testing.T) {
+	s := newCameraTestServer(t)
+
+	// The clip is linked in the API version the request arrived on.
+	for path, prefix := range map[string]string{"/api/camera": "/api", "/api/v1/camera": "/api/v1"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		s.serveCameraFeed(rr, req, noFeedClient{tesla.NewMockClient()})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: got status %v want %v (body %s)", path, rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var body map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if want := prefix + "/camera/events/saved-2024-05-01_10-15-30/clips/2024-05-01_10-14-30-front.mp4"; body["camera_feed_url"] != want {
+			t.Errorf("%s: camera_feed_url = %q, want %q", path, body["camera_feed_url"], want)
+		}
+	}
+}
+
+// noFeedClient is a tesla.Client without a live camera feed, like the real client.
+type noFeedClient struct{ tesla.Client }
+
+func (noFeedClient) GetCameraFeed(ctx context.Context) (string, error) {
+	return "", fmt.Errorf("live camera feed: %w", tesla.ErrNotSupported)
+}
+
+func TestCameraTimelineAndThumbnailHandlers(t *testing.T) {
+	s := newCameraTestServer(t)
+
+	req, _ := http.NewRequest("GET", "/api/camera/events/saved-2024-05-01_10-15-30/timeline", nil)
+	req.SetPathValue("id", "saved-2024-05-01_10-15-30")
+	rr := httptest.NewRecorder()
+	s.CameraTimelineHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("timeline: got status %v want %v (body %s)", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var timeline teslacam.Timeline
+	if err := json.Unmarshal(rr.Body.Bytes(), &timeline); err != nil {
+		t.Fatalf("timeline: invalid JSON: %v", err)
+	}
+	want := "/api/camera/events/saved-2024-05-01_10-15-30/clips/2024-05-01_10-14-30-front.mp4"
+	if len(timeline.Tracks) != 1 || len(timeline.Tracks[0].Ranges) != 1 || timeline.Tracks[0].Ranges[0].Clips[0].URL != want {
+		t.Errorf("timeline tracks = %+v, want one front range streaming from %s", timeline.Tracks, want)
+	}
+
+	// Without ffmpeg, only events the vehicle saved a thumbnail for have one.
+	req, _ = http.NewRequest("GET", "/api/camera/events/recent-2024-05-01_09-00-00/thumbnail", nil)
+	req.SetPathValue("id", "recent-2024-05-01_09-00-00")
+	rr = httptest.NewRecorder()
+	s.CameraThumbnailHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("thumbnail: got status %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestCameraClipTelemetryHandler(t *testing.T) {
+	s := newCameraTestServer(t)
+
+	get := func(name, query string) int {
+		req, _ := http.NewRequest("GET", "/api/camera/events/saved-2024-05-01_10-15-30/clips/"+name+"/telemetry"+query, nil)
+		req.SetPathValue("id", "saved-2024-05-01_10-15-30")
+		req.SetPathValue("name", name)
+		rr := httptest.NewRecorder()
+		s.CameraClipTelemetryHandler(rr, req)
+		return rr.Code
+	}
+	// The fixture clips predate telemetry.
+	if code := get("2024-05-01_10-14-30-front.mp4", ""); code != http.StatusNotFound {
+		t.Errorf("clip without telemetry: got status %v want %v", code, http.StatusNotFound)
+	}
+	if code := get("2024-05-01_10-14-30-front.mp4", "?format=kml"); code != http.StatusBadRequest {
+		t.Errorf("unknown format: got status %v want %v", code, http.StatusBadRequest)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/camera/events?telemetry=true", nil)
+	rr := httptest.NewRecorder()
+	s.CameraEventsHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("events with telemetry: got status %v want %v", rr.Code, http.StatusOK)
+	}
+}
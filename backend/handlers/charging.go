@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func startCharging(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.StartCharging(ctx)
+}
+
+func stopCharging(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.StopCharging(ctx)
+}
+
+// setChargeLimit expects {"percent": 50-100}.
+func setChargeLimit(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		Percent *int `json:"percent"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	if body.Percent == nil || *body.Percent < tesla.MinChargeLimitPercent || *body.Percent > tesla.MaxChargeLimitPercent {
+		return false, badRequestError{fmt.Sprintf("percent must be between %d and %d", tesla.MinChargeLimitPercent, tesla.MaxChargeLimitPercent)}
+	}
+	return client.SetChargeLimit(ctx, *body.Percent)
+}
+
+// setChargingAmps expects {"amps": 1-48}.
+func setChargingAmps(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		Amps *int `json:"amps"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	if body.Amps == nil || *body.Amps < tesla.MinChargingAmps || *body.Amps > tesla.MaxChargingAmps {
+		return false, badRequestError{fmt.Sprintf("amps must be between %d and %d", tesla.MinChargingAmps, tesla.MaxChargingAmps)}
+	}
+	return client.SetChargingAmps(ctx, *body.Amps)
+}
+
+func openChargePort(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.OpenChargePort(ctx)
+}
+
+func closeChargePort(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.CloseChargePort(ctx)
+}
+
+func unlockChargeCable(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.UnlockChargeCable(ctx)
+}
+
+// scheduleCharging expects {"enabled": true, "start_time": "HH:MM"}; start_time is the vehicle's
+// local time and is required when enabled.
+func scheduleCharging(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		Enabled   *bool  `json:"enabled"`
+		StartTime string `json:"start_time"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	if body.Enabled == nil {
+		return false, badRequestError{"enabled is required"}
+	}
+	if !*body.Enabled {
+		return client.ScheduleCharging(ctx, false, 0)
+	}
+	start, err := tesla.ParseTimeOfDay(body.StartTime)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	return client.ScheduleCharging(ctx, true, start)
+}
+
+// chargingCommands are the charging controls, by route, served like the climate commands for the
+// default real vehicle (/api/charging/...), a specific vehicle (/api/vehicles/{vin}/charging/...)
+// and the mock (/api/dev/charging/...).
+var chargingCommands = map[string]commandFunc{
+	"charging/start":        startCharging,
+	"charging/stop":         stopCharging,
+	"charging/limit":        setChargeLimit,
+	"charging/amps":         setChargingAmps,
+	"charging/port/open":    openChargePort,
+	"charging/port/close":   closeChargePort,
+	"charging/cable/unlock": unlockChargeCable,
+	"charging/schedule":     scheduleCharging,
+}
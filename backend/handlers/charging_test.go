@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestDevChargingCommands(t *testing.T) {
+	mock := tesla.NewMockClient()
+	h := newTestServer(Config{DevClient: mock}).Handler()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"limit", "/api/dev/charging/limit", `{"percent": 90}`, http.StatusOK},
+		{"limit too low", "/api/dev/charging/limit", `{"percent": 20}`, http.StatusBadRequest},
+		{"limit missing", "/api/dev/charging/limit", `{}`, http.StatusBadRequest},
+		{"body too large", "/api/dev/charging/limit", `{"percent": 90}` + strings.Repeat(" ", maxCommandBody), http.StatusRequestEntityTooLarge},
+		{"amps", "/api/dev/charging/amps", `{"amps": 16}`, http.StatusOK},
+		{"amps too high", "/api/dev/charging/amps", `{"amps": 80}`, http.StatusBadRequest},
+		{"start", "/api/dev/charging/start", ``, http.StatusOK},
+		{"close port with cable", "/api/dev/charging/port/close", ``, http.StatusInternalServerError},
+		{"schedule", "/api/dev/charging/schedule", `{"enabled": true, "start_time": "01:30"}`, http.StatusOK},
+		{"schedule bad time", "/api/dev/charging/schedule", `{"enabled": true, "start_time": "25:00"}`, http.StatusBadRequest},
+		{"schedule missing enabled", "/api/dev/charging/schedule", `{"start_time": "01:30"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v (body %s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	state, err := mock.GetVehicleStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := state.Charge
+	if *c.ChargingState != "charging" || *c.ChargeLimitPercent != 90 || *c.ChargerCurrentAmps != 16 ||
+		*c.ScheduledChargingMode != "start_at" || *c.ScheduledChargingStartMin != 90 {
+		t.Errorf("mock charge state not updated by commands: %+v", c)
+	}
+
+	// Unplugging ends the session; the port can then be closed but charging can't restart.
+	for _, path := range []string{"/api/dev/charging/cable/unlock", "/api/dev/charging/port/close"} {
+		req, _ := http.NewRequest("POST", path, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v (body %s)", rr.Code, http.StatusOK, rr.Body.String())
+		}
+	}
+	req, _ := http.NewRequest("POST", "/api/dev/charging/start", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("starting without a cable returned %v, want %v", rr.Code, http.StatusInternalServerError)
+	}
+	state, _ = mock.GetVehicleStats(context.Background())
+	if c := state.Charge; *c.ChargingState != "disconnected" || c.ChargeCable != nil || *c.ChargePortDoorOpen {
+		t.Errorf("mock charge state after unplugging: %+v", c)
+	}
+}
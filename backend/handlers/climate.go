@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func startClimate(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.StartClimate(ctx)
+}
+
+func stopClimate(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.StopClimate(ctx)
+}
+
+// setTemperatures expects {"driver_celsius": 21.5, "passenger_celsius": 21.5}.
+// passenger_celsius defaults to the driver setting.
+func setTemperatures(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		DriverCelsius    *float64 `json:"driver_celsius"`
+		PassengerCelsius *float64 `json:"passenger_celsius"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	if body.DriverCelsius == nil {
+		return false, badRequestError{"driver_celsius is required"}
+	}
+	if body.PassengerCelsius == nil {
+		body.PassengerCelsius = body.DriverCelsius
+	}
+	for _, t := range []float64{*body.DriverCelsius, *body.PassengerCelsius} {
+		if t < tesla.MinTemperatureCelsius || t > tesla.MaxTemperatureCelsius {
+			return false, badRequestError{fmt.Sprintf("temperatures must be between %.0f and %.0f °C", tesla.MinTemperatureCelsius, tesla.MaxTemperatureCelsius)}
+		}
+	}
+	return client.SetTemperatures(ctx, *body.DriverCelsius, *body.PassengerCelsius)
+}
+
+// setSeatHeater expects {"seat": "front_left", "level": 0-3}.
+func setSeatHeater(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		Seat  string `json:"seat"`
+		Level *int   `json:"level"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	seat, err := tesla.ParseSeat(body.Seat)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	if body.Level == nil || !tesla.HeaterLevel(*body.Level).Valid() {
+		return false, badRequestError{"level must be between 0 (off) and 3 (high)"}
+	}
+	return client.SetSeatHeater(ctx, seat, tesla.HeaterLevel(*body.Level))
+}
+
+// setSteeringWheelHeater expects {"on": true|false}.
+func setSteeringWheelHeater(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	on, err := decodeOnOff(r)
+	if err != nil {
+		return false, err
+	}
+	return client.SetSteeringWheelHeater(ctx, on)
+}
+
+// setMaxDefrost expects {"on": true|false}.
+func setMaxDefrost(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	on, err := decodeOnOff(r)
+	if err != nil {
+		return false, err
+	}
+	return client.SetMaxDefrost(ctx, on)
+}
+
+// setClimateKeeperMode expects {"mode": "off"|"keep"|"dog"|"camp"}.
+func setClimateKeeperMode(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		Mode string `json:"mode"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	mode, err := tesla.ParseClimateKeeperMode(body.Mode)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	return client.SetClimateKeeperMode(ctx, mode)
+}
+
+// climateCommands are the climate controls, by route. Each is served for the default real vehicle
+// (/api/climate/...), for a specific vehicle (/api/vehicles/{vin}/climate/...) and for the mock
+// (/api/dev/climate/...); see Server.Handler.
+var climateCommands = map[string]commandFunc{
+	"climate/start":                 startClimate,
+	"climate/stop":                  stopClimate,
+	"climate/temperature":           setTemperatures,
+	"climate/seat-heater":           setSeatHeater,
+	"climate/steering-wheel-heater": setSteeringWheelHeater,
+	"climate/max-defrost":           setMaxDefrost,
+	"climate/keeper-mode":           setClimateKeeperMode,
+}
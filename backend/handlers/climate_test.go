@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestDevClimateCommands(t *testing.T) {
+	mock := tesla.NewMockClient()
+	h := newTestServer(Config{DevClient: mock}).Handler()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"start", "/api/dev/climate/start", ``, http.StatusOK},
+		{"temperature", "/api/dev/climate/temperature", `{"driver_celsius": 23.5, "passenger_celsius": 20}`, http.StatusOK},
+		{"temperature out of range", "/api/dev/climate/temperature", `{"driver_celsius": 40}`, http.StatusBadRequest},
+		{"temperature missing", "/api/dev/climate/temperature", `{}`, http.StatusBadRequest},
+		{"seat heater", "/api/dev/climate/seat-heater", `{"seat": "rear_center", "level": 2}`, http.StatusOK},
+		{"seat heater bad seat", "/api/dev/climate/seat-heater", `{"seat": "roof", "level": 2}`, http.StatusBadRequest},
+		{"seat heater bad level", "/api/dev/climate/seat-heater", `{"seat": "front_left", "level": 7}`, http.StatusBadRequest},
+		{"steering wheel", "/api/dev/climate/steering-wheel-heater", `{"on": true}`, http.StatusOK},
+		{"steering wheel missing on", "/api/dev/climate/steering-wheel-heater", `{"enabled": true}`, http.StatusBadRequest},
+		{"max defrost", "/api/dev/climate/max-defrost", `{"on": true}`, http.StatusOK},
+		{"dog mode", "/api/dev/climate/keeper-mode", `{"mode": "dog"}`, http.StatusOK},
+		{"unknown mode", "/api/dev/climate/keeper-mode", `{"mode": "cat"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v (body %s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	state, err := mock.GetVehicleStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := state.Climate
+	if !*c.IsClimateOn || *c.DriverTempSettingCelsius != 23.5 || *c.PassengerTempSettingCelsius != 20 ||
+		*c.SeatHeaterRearCenterLevel != 2 || !*c.SteeringWheelHeaterOn || *c.DefrostMode != "max" ||
+		*c.ClimateKeeperMode != "dog" {
+		t.Errorf("mock climate state not updated by commands: %+v", c)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/dev/climate/start", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET returned wrong status code: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestStartClimateHandler_RealClientUnavailable(t *testing.T) {
+	s := newTestServer(Config{})
+	req, _ := http.NewRequest("POST", "/api/climate/start", nil)
+	rr := httptest.NewRecorder()
+	s.realCommand(startClimate).ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
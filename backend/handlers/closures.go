@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// decodeConfirm decodes a body that only carries {"confirm": true|false}; an empty body is
+// treated as unconfirmed.
+func decodeConfirm(r *http.Request) (bool, error) {
+	var body struct {
+		Confirm bool `json:"confirm"`
+	}
+	if r.Body == nil {
+		return false, nil
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil && err != io.EOF {
+		return false, badRequestError{fmt.Sprintf("Invalid request body: %v", err)}
+	}
+	return body.Confirm, nil
+}
+
+// actuateFrunk expects {"confirm": true}.
+func actuateFrunk(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	confirm, err := decodeConfirm(r)
+	if err != nil {
+		return false, err
+	}
+	if !confirm {
+		return false, confirmationRequiredError{"Opening the frunk"}
+	}
+	return client.ActuateFrunk(ctx)
+}
+
+// actuateTrunk expects {"confirm": true}; the trunk may be opened rather than closed.
+func actuateTrunk(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	confirm, err := decodeConfirm(r)
+	if err != nil {
+		return false, err
+	}
+	if !confirm {
+		return false, confirmationRequiredError{"Actuating the trunk"}
+	}
+	return client.ActuateTrunk(ctx)
+}
+
+// ventWindows expects {"confirm": true}.
+func ventWindows(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	confirm, err := decodeConfirm(r)
+	if err != nil {
+		return false, err
+	}
+	if !confirm {
+		return false, confirmationRequiredError{"Venting the windows"}
+	}
+	return client.VentWindows(ctx)
+}
+
+func closeWindows(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.CloseWindows(ctx)
+}
+
+// setSunroof expects {"percent": 0-100, "confirm": true}; confirm may be omitted when closing.
+func setSunroof(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		Percent *int `json:"percent"`
+		Confirm bool `json:"confirm"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	if body.Percent == nil || *body.Percent < 0 || *body.Percent > 100 {
+		return false, badRequestError{"percent must be between 0 and 100"}
+	}
+	if *body.Percent > 0 && !body.Confirm {
+		return false, confirmationRequiredError{"Opening the sunroof"}
+	}
+	return client.SetSunroof(ctx, *body.Percent)
+}
+
+// setTonneau expects {"action": "open"|"close"|"stop", "confirm": true}; confirm may be omitted
+// unless opening.
+func setTonneau(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		Action  string `json:"action"`
+		Confirm bool   `json:"confirm"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	action, err := tesla.ParseTonneauAction(body.Action)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	if action == tesla.TonneauOpen && !body.Confirm {
+		return false, confirmationRequiredError{"Opening the tonneau"}
+	}
+	return client.SetTonneau(ctx, action)
+}
+
+// closureCommands are the closure controls, by route, served for the default real vehicle
+// (/api/closures/...), a specific vehicle (/api/vehicles/{vin}/closures/...) and the mock
+// (/api/dev/closures/...). Commands that can open the car answer 428 Precondition Required unless
+// the body contains "confirm": true.
+var closureCommands = map[string]commandFunc{
+	"closures/frunk":         actuateFrunk,
+	"closures/trunk":         actuateTrunk,
+	"closures/windows/vent":  ventWindows,
+	"closures/windows/close": closeWindows,
+	"closures/sunroof":       setSunroof,
+	"closures/tonneau":       setTonneau,
+}
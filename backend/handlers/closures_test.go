@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestDevClosureCommands(t *testing.T) {
+	mock := tesla.NewMockClient()
+	h := newTestServer(Config{DevClient: mock}).Handler()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"frunk unconfirmed", "/api/dev/closures/frunk", ``, http.StatusPreconditionRequired},
+		{"frunk declined", "/api/dev/closures/frunk", `{"confirm": false}`, http.StatusPreconditionRequired},
+		{"frunk", "/api/dev/closures/frunk", `{"confirm": true}`, http.StatusOK},
+		{"trunk unconfirmed", "/api/dev/closures/trunk", `{}`, http.StatusPreconditionRequired},
+		{"trunk", "/api/dev/closures/trunk", `{"confirm": true}`, http.StatusOK},
+		{"vent unconfirmed", "/api/dev/closures/windows/vent", ``, http.StatusPreconditionRequired},
+		{"vent", "/api/dev/closures/windows/vent", `{"confirm": true}`, http.StatusOK},
+		{"sunroof open unconfirmed", "/api/dev/closures/sunroof", `{"percent": 15}`, http.StatusPreconditionRequired},
+		{"sunroof out of range", "/api/dev/closures/sunroof", `{"percent": 150, "confirm": true}`, http.StatusBadRequest},
+		{"sunroof", "/api/dev/closures/sunroof", `{"percent": 15, "confirm": true}`, http.StatusOK},
+		{"tonneau unsupported", "/api/dev/closures/tonneau", `{"action": "close"}`, http.StatusNotImplemented},
+		{"tonneau open unconfirmed", "/api/dev/closures/tonneau", `{"action": "open"}`, http.StatusPreconditionRequired},
+		{"tonneau bad action", "/api/dev/closures/tonneau", `{"action": "fold"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v (body %s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	state, err := mock.GetVehicleStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := state.Closures
+	if !*c.FrunkOpen || !*c.TrunkOpen || !*c.WindowDriverFrontOpen || !*c.WindowPassengerRearOpen || *c.SunroofPercentOpen != 15 {
+		t.Errorf("mock closures state not updated by commands: %+v", c)
+	}
+
+	// Closing needs no confirmation.
+	req, _ := http.NewRequest("POST", "/api/dev/closures/windows/close", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	state, _ = mock.GetVehicleStats(context.Background())
+	if *state.Closures.WindowDriverFrontOpen {
+		t.Errorf("windows still open after close: %+v", state.Closures)
+	}
+}
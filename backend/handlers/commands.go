@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// maxCommandBody bounds a command's request body; its parameters are a small JSON object. A larger
+// body is answered with 413.
+const maxCommandBody = 4 << 10
+
+// commandFunc runs one command against client using the request body for its arguments.
+// It returns an error of type badRequestError when the arguments are invalid, or
+// confirmationRequiredError when a command that opens the car was not confirmed.
+type commandFunc func(ctx context.Context, client tesla.Client, r *http.Request) (bool, error)
+
+// badRequestError marks a command failure caused by the caller's input rather than the vehicle.
+type badRequestError struct{ msg string }
+
+func (e badRequestError) Error() string { return e.msg }
+
+// confirmationRequiredError marks a command that would open the car but was sent without
+// "confirm": true in its body.
+type confirmationRequiredError struct{ action string }
+
+func (e confirmationRequiredError) Error() string {
+	return fmt.Sprintf("%s opens the vehicle; resend with \"confirm\": true", e.action)
+}
+
+// decodeBody decodes the JSON request body into dst, rejecting unknown fields.
+func decodeBody(r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return badRequestError{fmt.Sprintf("Invalid request body: %v", err)}
+	}
+	return nil
+}
+
+// serveCommand runs cmd against client and writes the usual {"success": ...} response.
+//
+// By default a sleeping vehicle fails fast with 503. With ?wake=true the vehicle is woken and the
+// command retried once it is online, within an extra Wake timeout.
+func (s *Server) serveCommand(w http.ResponseWriter, r *http.Request, client tesla.Client, cmd commandFunc) {
+	wake, err := boolParameter(r, "wake")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// The body is buffered so the command can decode it again after waking the vehicle.
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxCommandBody)); err != nil {
+			status := http.StatusBadRequest
+			if errors.As(err, new(*http.MaxBytesError)) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeError(w, status, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+	}
+	success, err := s.runCommand(r.Context(), r, client, cmd, body, wake)
+	switch err.(type) {
+	case nil:
+		WriteJsonResponse(w, http.StatusOK, map[string]bool{"success": success})
+	case badRequestError, confirmationRequiredError:
+		e, message := commandError(err)
+		writeAPIError(w, e, message)
+	default:
+		s.writeClientError(w, r, err)
+	}
+}
+
+// runCommand runs cmd against client with body as the request body, within the Command timeout.
+// If wake is set and the vehicle is asleep, it is woken and the command retried once it is online,
+// within an extra Wake timeout.
+func (s *Server) runCommand(ctx context.Context, r *http.Request, client tesla.Client, cmd commandFunc, body []byte, wake bool) (bool, error) {
+	timeout := s.timeouts.Command
+	if wake {
+		timeout += s.timeouts.Wake
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	run := func() (bool, error) {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return cmd(ctx, client, r)
+	}
+	success, err := run()
+	if wake && errors.Is(err, tesla.ErrVehicleAsleep) {
+		if err = tesla.WaitUntilOnline(ctx, client, s.wakeBackoff); err == nil {
+			success, err = run()
+		}
+	}
+	if err == nil {
+		s.nudgePoller(client)
+	}
+	return success, err
+}
+
+// commandError returns the response and message for an error returned by a commandFunc: its own
+// badRequestError and confirmationRequiredError, or a client error.
+func commandError(err error) (apiError, string) {
+	if bad, ok := err.(badRequestError); ok {
+		return apiError{http.StatusBadRequest, statusCodes[http.StatusBadRequest], false}, bad.Error()
+	}
+	if unconfirmed, ok := err.(confirmationRequiredError); ok {
+		return apiError{http.StatusPreconditionRequired, statusCodes[http.StatusPreconditionRequired], false}, unconfirmed.Error()
+	}
+	return clientError(err)
+}
+
+// boolParameter parses an optional ?name=true|false query parameter, e.g. ?wake=true.
+func boolParameter(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid %s parameter %q", name, value)
+	}
+	return b, nil
+}
+
+// realCommand builds the handler for a command sent to the default real vehicle.
+func (s *Server) realCommand(cmd commandFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := s.defaultVehicle(w, r); ok {
+			s.serveCommand(w, r, client, cmd)
+		}
+	}
+}
+
+// vehicleCommand builds the handler for a command sent to the real vehicle named by {vin}.
+func (s *Server) vehicleCommand(cmd commandFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := vehicleClient(w, r, s.registry); ok {
+			s.serveCommand(w, r, client, cmd)
+		}
+	}
+}
+
+// devCommand builds the handler for a command sent to the mock client.
+func (s *Server) devCommand(cmd commandFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveCommand(w, r, s.mockClient, cmd)
+	}
+}
+
+// onOffBody is the {"on": true|false} body shared by toggle commands.
+type onOffBody struct {
+	On *bool `json:"on"`
+}
+
+func decodeOnOff(r *http.Request) (bool, error) {
+	var body onOffBody
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	if body.On == nil {
+		return false, badRequestError{"on is required"}
+	}
+	return *body.On, nil
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// requestIDHeader carries the ID of a request. The server sets it on every response before any
+// handler runs, so error responses can quote it.
+const requestIDHeader = "X-Request-ID"
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code      string `json:"code"`    // Stable and machine-readable, e.g. "vehicle_asleep".
+	Message   string `json:"message"` // For humans; may change.
+	Retryable bool   `json:"retryable"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// apiError is how an error is reported: its status, code and whether retrying can succeed.
+type apiError struct {
+	status    int
+	code      string
+	retryable bool
+}
+
+// clientErrors maps the errors of the tesla package to responses. They are checked in order,
+// since one error can wrap several, e.g. a timeout while waking a vehicle. format turns the
+// error into the message.
+var clientErrors = []struct {
+	err    error
+	format string
+	apiError
+}{
+	{tesla.ErrTimeout, "Vehicle did not respond in time: %v", apiError{http.StatusGatewayTimeout, "timeout", true}},
+	{tesla.ErrVehicleAsleep, "%v; retry with ?wake=true to wake it first", apiError{http.StatusServiceUnavailable, "vehicle_asleep", true}},
+	{tesla.ErrVehicleOffline, "%v", apiError{http.StatusServiceUnavailable, "vehicle_offline", true}},
+	{tesla.ErrVehicleNotConnected, "%v", apiError{http.StatusServiceUnavailable, "vehicle_not_connected", true}},
+	{tesla.ErrRateLimited, "%v", apiError{http.StatusTooManyRequests, "rate_limited", true}},
+	// The caller is authorized; it is this server's credentials the vehicle or Fleet API rejected.
+	{tesla.ErrUnauthorized, "%v", apiError{http.StatusBadGateway, "vehicle_unauthorized", false}},
+	{tesla.ErrNotSupported, "%v", apiError{http.StatusNotImplemented, "not_supported", false}},
+	{tesla.ErrKeyNotFound, "%v", apiError{http.StatusNotFound, "key_not_found", false}},
+	{tesla.ErrUnknownVehicle, "%v", apiError{http.StatusNotFound, "unknown_vehicle", false}},
+}
+
+// clientError returns the response and message for an error from a Tesla client. Anything not in
+// clientErrors is a 500.
+func clientError(err error) (apiError, string) {
+	for _, e := range clientErrors {
+		if errors.Is(err, e.err) {
+			return e.apiError, fmt.Sprintf(e.format, err)
+		}
+	}
+	return apiError{http.StatusInternalServerError, "tesla_error", false}, fmt.Sprintf("Error from Tesla API: %v", err)
+}
+
+// statusCodes are the codes of errors reported by status alone, e.g. an invalid request body.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusPreconditionRequired:  "confirmation_required",
+	http.StatusInternalServerError:   "internal",
+	http.StatusNotImplemented:        "not_supported",
+	http.StatusServiceUnavailable:    "not_configured",
+}
+
+// writeError writes an error response with the code for status. Such errors are down to the
+// request or the server's configuration, so retrying will not help.
+func writeError(w http.ResponseWriter, status int, message string) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "error"
+	}
+	writeAPIError(w, apiError{status, code, false}, message)
+}
+
+func writeAPIError(w http.ResponseWriter, e apiError, message string) {
+	WriteJsonResponse(w, e.status, errorResponse{errorBody{
+		Code:      e.code,
+		Message:   message,
+		Retryable: e.retryable,
+		RequestID: w.Header().Get(requestIDHeader),
+	}})
+}
+
+// writeClientError writes the response for an error returned by a Tesla client; see
+// clientErrors. If the HTTP client itself went away there is nobody left to answer, so nothing is
+// written.
+func (s *Server) writeClientError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		s.logger.Printf("Request %s %s cancelled by client: %v", r.Method, r.URL.Path, err)
+		return
+	}
+	e, message := clientError(err)
+	writeAPIError(w, e, message)
+}
+
+// envelopeWriter turns the plain-text error a ServeMux writes for a request no route matches into
+// an error response, keeping headers such as the Allow of a 405.
+type envelopeWriter struct {
+	http.ResponseWriter
+	replaced bool
+}
+
+func (ew *envelopeWriter) WriteHeader(status int) {
+	if status < 400 {
+		ew.ResponseWriter.WriteHeader(status)
+		return
+	}
+	ew.replaced = true
+	ew.Header().Del("X-Content-Type-Options")
+	writeError(ew.ResponseWriter, status, http.StatusText(status))
+}
+
+func (ew *envelopeWriter) Write(b []byte) (int, error) {
+	if ew.replaced {
+		return len(b), nil
+	}
+	return ew.ResponseWriter.Write(b)
+}
+
+// unmatchedErrors serves mux, answering requests that match no route with error responses.
+func unmatchedErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			w = &envelopeWriter{ResponseWriter: w}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestClientError(t *testing.T) {
+	tests := []struct {
+		err       error
+		status    int
+		code      string
+		retryable bool
+	}{
+		{fmt.Errorf("lock: %w", tesla.ErrVehicleAsleep), http.StatusServiceUnavailable, "vehicle_asleep", true},
+		{tesla.ErrVehicleOffline, http.StatusServiceUnavailable, "vehicle_offline", true},
+		{tesla.ErrRateLimited, http.StatusTooManyRequests, "rate_limited", true},
+		{tesla.ErrUnauthorized, http.StatusBadGateway, "vehicle_unauthorized", false},
+		{tesla.ErrNotSupported, http.StatusNotImplemented, "not_supported", false},
+		// A timeout while waking is reported as the timeout.
+		{fmt.Errorf("%w: %w", tesla.ErrVehicleAsleep, tesla.ErrTimeout), http.StatusGatewayTimeout, "timeout", true},
+		{errors.New("boom"), http.StatusInternalServerError, "tesla_error", false},
+	}
+	for _, tt := range tests {
+		e, _ := clientError(tt.err)
+		if e.status != tt.status || e.code != tt.code || e.retryable != tt.retryable {
+			t.Errorf("clientError(%v) = %+v, want %v %s retryable=%v", tt.err, e, tt.status, tt.code, tt.retryable)
+		}
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	mock := tesla.NewMockClient()
+	mock.Sleep()
+	h := newTestServer(Config{DevClient: mock}).Handler()
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		requestID string
+		status    int
+		code      string
+		retryable bool
+	}{
+		{"client error", "POST", "/api/v1/dev/lock", "client-id-1", http.StatusServiceUnavailable, "vehicle_asleep", true},
+		{"bad request", "POST", "/api/v1/dev/charging/limit", "", http.StatusBadRequest, "bad_request", false},
+		{"no route", "GET", "/api/v1/nowhere", "", http.StatusNotFound, "not_found", false},
+		{"wrong method", "GET", "/api/v1/dev/lock", "", http.StatusMethodNotAllowed, "method_not_allowed", false},
+		{"invalid request ID replaced", "POST", "/api/v1/dev/lock", "bad id\n", http.StatusServiceUnavailable, "vehicle_asleep", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.requestID != "" {
+			req.Header.Set(requestIDHeader, tt.requestID)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		var resp errorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: body %q is not an error response: %v", tt.name, rr.Body.String(), err)
+		}
+		if rr.Code != tt.status || resp.Error.Code != tt.code || resp.Error.Retryable != tt.retryable || resp.Error.Message == "" {
+			t.Errorf("%s: got %v %s, want %v %s retryable=%v", tt.name, rr.Code, rr.Body.String(), tt.status, tt.code, tt.retryable)
+		}
+		id := rr.Header().Get(requestIDHeader)
+		if id == "" || resp.Error.RequestID != id {
+			t.Errorf("%s: error request ID %q, want the %s header %q", tt.name, resp.Error.RequestID, requestIDHeader, id)
+		}
+		if valid := validRequestID(tt.requestID); valid != (id == tt.requestID) {
+			t.Errorf("%s: request ID %q answered with %q", tt.name, tt.requestID, id)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type %q, want application/json", tt.name, ct)
+		}
+	}
+}
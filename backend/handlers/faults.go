@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// DevFaultsHandler inspects and changes the faults injected into the mock client: GET returns the
+// current tesla.FaultConfig, PUT replaces it and DELETE turns fault injection off.
+func (s *Server) DevFaultsHandler(w http.ResponseWriter, r *http.Request) {
+	fc, ok := s.mockClient.(*tesla.FaultyClient)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Fault injection is not enabled for the dev client")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var config tesla.FaultConfig
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&config); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+		if err := fc.SetFaults(config); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case http.MethodDelete:
+		fc.SetFaults(tesla.FaultConfig{})
+	}
+	WriteJsonResponse(w, http.StatusOK, fc.Faults())
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestDevFaultsHandler(t *testing.T) {
+	timeouts := tesla.DefaultTimeouts()
+	timeouts.Stats = 50 * time.Millisecond
+	s := newTestServer(Config{
+		DevClient: tesla.NewFaultyClient(tesla.NewMockClient(), tesla.FaultConfig{}),
+		Timeouts:  timeouts,
+	})
+
+	do := func(handler http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, "/api/dev", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	if rr := do(s.DevFaultsHandler, "PUT", `{"error_rate": 2}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid config: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := do(s.DevFaultsHandler, "PUT", `{"script": ["asleep", "offline", "timeout", "error"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("PUT faults: got %v (body %s)", rr.Code, rr.Body.String())
+	}
+
+	// Each fault reaches its branch of writeClientError.
+	for _, want := range []int{
+		http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		http.StatusInternalServerError,
+		http.StatusOK,
+	} {
+		if rr := do(s.DevGetStatsHandler, "GET", ""); rr.Code != want {
+			t.Errorf("stats: got %v want %v (body %s)", rr.Code, want, rr.Body.String())
+		}
+	}
+
+	if rr := do(s.DevFaultsHandler, "DELETE", ""); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "{}" {
+		t.Errorf("DELETE faults: got %v %s", rr.Code, rr.Body.String())
+	}
+	req, _ := http.NewRequest("POST", "/api/dev/faults", nil)
+	rr := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST faults: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
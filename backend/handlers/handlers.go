@@ -1,41 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/ameena3/tesla/backend/tesla" // Adjusted import path
-	"log"
 	"net/http"
-	"os"
 )
 
-var mockClient tesla.Client = tesla.NewMockClient()
-var realClient tesla.Client
-
-// initializeRealClient attempts to initialize the real Tesla client.
-// It expects TESLA_VIN environment variable to be set.
-// Other credentials (TESLA_KEY_NAME, TESLA_TOKEN_NAME, TESLA_CACHE_FILE)
-// are expected by the tesla.NewRealClient via pkg/cli.
-func initializeRealClient() {
-	vin := os.Getenv("TESLA_VIN")
-	if vin == "" {
-		log.Println("TESLA_VIN environment variable not set. Real Tesla client will not be available.")
-		return
-	}
-
-	client, err := tesla.NewRealClient(vin)
-	if err != nil {
-		log.Printf("Error initializing real Tesla client for VIN %s: %v. Real client will not be available.", vin, err)
-		return
-	}
-	realClient = client
-	log.Println("Real Tesla client initialized successfully for VIN:", vin)
-}
-
-func init() {
-	initializeRealClient()
-}
-
 // WriteJsonResponse is a helper to write JSON responses
 func WriteJsonResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -46,118 +18,195 @@ func WriteJsonResponse(w http.ResponseWriter, statusCode int, data interface{})
 }
 
 // DevGetStatsHandler handles requests for dummy stats.
-func DevGetStatsHandler(w http.ResponseWriter, r *http.Request) {
-	stats, err := mockClient.GetVehicleStats()
-	if err != nil {
-		WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
-	WriteJsonResponse(w, http.StatusOK, stats)
+func (s *Server) DevGetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveStats(w, r, s.mockClient)
 }
 
 // DevLockVehicleHandler handles requests to simulate locking the vehicle.
-func DevLockVehicleHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		WriteJsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+func (s *Server) DevLockVehicleHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveLock(w, r, s.mockClient)
+}
+
+// DevUnlockVehicleHandler handles requests to simulate unlocking the vehicle.
+func (s *Server) DevUnlockVehicleHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveUnlock(w, r, s.mockClient)
+}
+
+// DevGetCameraFeedHandler handles requests for a dummy camera feed.
+func (s *Server) DevGetCameraFeedHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveCameraFeed(w, r, s.mockClient)
+}
+
+// serveStats writes the vehicle state reported by client, limited to the comma-separated
+// ?categories= if given. A vehicle with a background poller is answered from its cache, so
+// dashboards do not keep it awake, unless ?refresh=true asks for the live state.
+func (s *Server) serveStats(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	categories, err := tesla.ParseStateCategories(r.URL.Query().Get("categories"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	success, err := mockClient.LockVehicle()
+	refresh, err := boolParameter(r, "refresh")
 	if err != nil {
-		WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	WriteJsonResponse(w, http.StatusOK, map[string]bool{"success": success})
-}
-
-// DevUnlockVehicleHandler handles requests to simulate unlocking the vehicle.
-func DevUnlockVehicleHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		WriteJsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
-		return
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Stats)
+	defer cancel()
+	var stats *tesla.VehicleState
+	switch poller := s.pollers[client]; {
+	case poller == nil:
+		stats, err = client.GetVehicleStats(ctx, categories...)
+	case refresh:
+		stats, err = poller.Refresh(ctx, categories...)
+	default:
+		stats, err = poller.Stats(ctx, categories...)
 	}
-	success, err := mockClient.UnlockVehicle()
 	if err != nil {
-		WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		s.writeClientError(w, r, err)
 		return
 	}
-	WriteJsonResponse(w, http.StatusOK, map[string]bool{"success": success})
+	WriteJsonResponse(w, http.StatusOK, stats)
 }
 
-// DevGetCameraFeedHandler handles requests for a dummy camera feed.
-func DevGetCameraFeedHandler(w http.ResponseWriter, r *http.Request) {
-	feedURL, err := mockClient.GetCameraFeed()
+func lockVehicle(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.LockVehicle(ctx)
+}
+
+func unlockVehicle(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	return client.UnlockVehicle(ctx)
+}
+
+// serveLock locks the vehicle behind client.
+func (s *Server) serveLock(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	s.serveCommand(w, r, client, lockVehicle)
+}
+
+// serveUnlock unlocks the vehicle behind client.
+func (s *Server) serveUnlock(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	s.serveCommand(w, r, client, unlockVehicle)
+}
+
+// serveCameraFeed writes the camera feed URL reported by client, or the URL of the latest
+// TeslaCam clip if client has no feed.
+func (s *Server) serveCameraFeed(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Camera)
+	defer cancel()
+	feedURL, err := client.GetCameraFeed(ctx)
+	if errors.Is(err, tesla.ErrNotSupported) && s.cameraArchive != nil {
+		// No live feed; the latest recording is the closest thing to it.
+		s.serveLatestClip(w, r)
+		return
+	}
 	if err != nil {
-		WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		s.writeClientError(w, r, err)
 		return
 	}
 	WriteJsonResponse(w, http.StatusOK, map[string]string{"camera_feed_url": feedURL})
 }
 
 // GetStatsHandler handles requests for real vehicle stats.
-func GetStatsHandler(w http.ResponseWriter, r *http.Request) {
-	if realClient == nil {
-		WriteJsonResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Real Tesla client not initialized. Check server configuration."})
-		return
-	}
-	stats, err := realClient.GetVehicleStats()
-	if err != nil {
-		WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Error from Tesla API: %v", err)})
-		return
+func (s *Server) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveStats(w, r, client)
 	}
-	WriteJsonResponse(w, http.StatusOK, stats)
 }
 
 // LockVehicleHandler handles requests to lock the vehicle.
-func LockVehicleHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		WriteJsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
-		return
+func (s *Server) LockVehicleHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveLock(w, r, client)
 	}
-	if realClient == nil {
-		WriteJsonResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Real Tesla client not initialized. Check server configuration."})
-		return
+}
+
+// UnlockVehicleHandler handles requests to unlock the vehicle.
+func (s *Server) UnlockVehicleHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveUnlock(w, r, client)
 	}
-	success, err := realClient.LockVehicle()
+}
+
+// GetCameraFeedHandler handles requests for the real camera feed.
+func (s *Server) GetCameraFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveCameraFeed(w, r, client)
+	}
+}
+
+// vehicleClient resolves the {vin} path parameter against reg. It writes 404 for an unknown VIN
+// and 503 (with the vehicle's last connection error) for one that is not connected.
+func vehicleClient(w http.ResponseWriter, r *http.Request, reg *tesla.Registry) (tesla.Client, bool) {
+	client, err := reg.Client(r.PathValue("vin"))
 	if err != nil {
-		WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Error from Tesla API: %v", err)})
-		return
+		e, message := clientError(err)
+		writeAPIError(w, e, message)
+		return nil, false
 	}
-	WriteJsonResponse(w, http.StatusOK, map[string]bool{"success": success})
+	return client, true
 }
 
-// UnlockVehicleHandler handles requests to unlock the vehicle.
-func UnlockVehicleHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		WriteJsonResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
-		return
+// ListVehiclesHandler lists the configured vehicles and their connection state.
+func (s *Server) ListVehiclesHandler(w http.ResponseWriter, r *http.Request) {
+	WriteJsonResponse(w, http.StatusOK, map[string]interface{}{"vehicles": s.registry.List()})
+}
+
+// VehicleStatsHandler handles requests for the stats of the vehicle named by {vin}.
+func (s *Server) VehicleStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveStats(w, r, client)
 	}
-	if realClient == nil {
-		WriteJsonResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Real Tesla client not initialized. Check server configuration."})
-		return
+}
+
+// VehicleLockHandler handles requests to lock the vehicle named by {vin}.
+func (s *Server) VehicleLockHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveLock(w, r, client)
 	}
-	success, err := realClient.UnlockVehicle()
-	if err != nil {
-		WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Error from Tesla API: %v", err)})
-		return
+}
+
+// VehicleUnlockHandler handles requests to unlock the vehicle named by {vin}.
+func (s *Server) VehicleUnlockHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveUnlock(w, r, client)
 	}
-	WriteJsonResponse(w, http.StatusOK, map[string]bool{"success": success})
 }
 
-// GetCameraFeedHandler handles requests for the real camera feed.
-func GetCameraFeedHandler(w http.ResponseWriter, r *http.Request) {
-	if realClient == nil {
-		WriteJsonResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Real Tesla client not initialized. Check server configuration."})
-		return
+// VehicleCameraFeedHandler handles requests for the camera feed of the vehicle named by {vin}.
+func (s *Server) VehicleCameraFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveCameraFeed(w, r, client)
 	}
-	feedURL, err := realClient.GetCameraFeed()
-	if err != nil {
-		// Specific error for camera feed not implemented yet by real client
-		if err.Error() == "GetCameraFeed not yet fully implemented with SDK" {
-			WriteJsonResponse(w, http.StatusNotImplemented, map[string]string{"error": err.Error()})
-		} else {
-			WriteJsonResponse(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Error from Tesla API: %v", err)})
-		}
-		return
+}
+
+// DevListVehiclesHandler lists the simulated vehicles.
+func (s *Server) DevListVehiclesHandler(w http.ResponseWriter, r *http.Request) {
+	WriteJsonResponse(w, http.StatusOK, map[string]interface{}{"vehicles": s.devRegistry.List()})
+}
+
+// DevVehicleStatsHandler handles requests for dummy stats of the simulated vehicle named by {vin}.
+func (s *Server) DevVehicleStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.devRegistry); ok {
+		s.serveStats(w, r, client)
+	}
+}
+
+// DevVehicleLockHandler handles requests to simulate locking the vehicle named by {vin}.
+func (s *Server) DevVehicleLockHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.devRegistry); ok {
+		s.serveLock(w, r, client)
+	}
+}
+
+// DevVehicleUnlockHandler handles requests to simulate unlocking the vehicle named by {vin}.
+func (s *Server) DevVehicleUnlockHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.devRegistry); ok {
+		s.serveUnlock(w, r, client)
+	}
+}
+
+// DevVehicleCameraFeedHandler handles requests for a dummy camera feed of the vehicle named by {vin}.
+func (s *Server) DevVehicleCameraFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.devRegistry); ok {
+		s.serveCameraFeed(w, r, client)
 	}
-	WriteJsonResponse(w, http.StatusOK, map[string]string{"camera_feed_url": feedURL})
 }
@@ -1,46 +1,125 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/ameena3/tesla/backend/tesla" // Ensure correct import path
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDevGetStatsHandler(t *testing.T) {
+	s := newTestServer(Config{})
 	req, err := http.NewRequest("GET", "/api/dev/stats", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(DevGetStatsHandler)
+	handler := http.HandlerFunc(s.DevGetStatsHandler)
 	handler.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	// Check the response body
-	expected := tesla.NewMockClient()
-	expectedStats, _ := expected.GetVehicleStats()
-	expectedJSON, _ := json.Marshal(expectedStats)
+	// Check the response body; FetchedAt differs between calls, so compare everything else.
+	var got tesla.VehicleState
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	expected, _ := tesla.NewMockClient().GetVehicleStats(context.Background())
+	if got.FetchedAt.IsZero() {
+		t.Errorf("handler returned no fetched_at timestamp")
+	}
+	got.FetchedAt, expected.FetchedAt = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(&got, expected) {
+		gotJSON, _ := json.Marshal(got)
+		expectedJSON, _ := json.Marshal(expected)
+		t.Errorf("handler returned unexpected body: got %s want %s", gotJSON, expectedJSON)
+	}
+}
+
+func TestDevGetStatsHandler_Categories(t *testing.T) {
+	s := newTestServer(Config{})
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"subset", "?categories=charge,tire_pressure", http.StatusOK},
+		{"unknown category", "?categories=charge,tires", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/dev/stats"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(s.DevGetStatsHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tc.wantStatus, rr.Body)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var got tesla.VehicleState
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("handler returned invalid JSON: %v", err)
+			}
+			if got.Charge == nil || got.TirePressure == nil || got.Climate != nil || got.Security != nil {
+				t.Errorf("handler returned %s, want only charge and tire_pressure", rr.Body)
+			}
+		})
+	}
+}
+
+func TestDevGetStatsHandler_Polled(t *testing.T) {
+	client := &countingClient{Client: tesla.NewMockClient()}
+	s := newTestServer(Config{
+		DevClient:     client,
+		PollSchedules: map[string]tesla.PollSchedule{tesla.MockVIN: tesla.FixedPollSchedule(time.Hour)},
+	})
+	t.Cleanup(s.Close)
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.DevGetStatsHandler).ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	get("/api/dev/stats")
+	polls := client.polls.Load()
+	if rr := get("/api/dev/stats?categories=security"); rr.Code != http.StatusOK || client.polls.Load() != polls {
+		t.Errorf("cached stats answered %d after %d more polls, want 200 from the cache", rr.Code, client.polls.Load()-polls)
+	}
+	if get("/api/dev/stats?refresh=true"); client.polls.Load() != polls+1 {
+		t.Errorf("?refresh=true polled the vehicle %d times, want once", client.polls.Load()-polls)
+	}
+	if rr := get("/api/dev/stats?refresh=maybe"); rr.Code != http.StatusBadRequest {
+		t.Errorf("?refresh=maybe answered %d, want 400", rr.Code)
+	}
 
-	if strings.TrimSpace(rr.Body.String()) != string(expectedJSON) {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), string(expectedJSON))
+	// A command invalidates the cache.
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.DevUnlockVehicleHandler).ServeHTTP(rr, httptest.NewRequest("POST", "/api/dev/unlock", nil))
+	var got tesla.VehicleState
+	if err := json.Unmarshal(get("/api/dev/stats").Body.Bytes(), &got); err != nil || got.Security == nil || *got.Security.Locked {
+		t.Errorf("stats after unlocking = %+v (%v), want unlocked", got.Security, err)
 	}
 }
 
 func TestDevLockVehicleHandler(t *testing.T) {
+	s := newTestServer(Config{})
 	// Test POST (successful)
 	reqPost, errPost := http.NewRequest("POST", "/api/dev/lock", nil)
 	if errPost != nil {
 		t.Fatal(errPost)
 	}
 	rrPost := httptest.NewRecorder()
-	handlerPost := http.HandlerFunc(DevLockVehicleHandler)
+	handlerPost := http.HandlerFunc(s.DevLockVehicleHandler)
 	handlerPost.ServeHTTP(rrPost, reqPost)
 
 	if status := rrPost.Code; status != http.StatusOK {
@@ -57,8 +136,7 @@ func TestDevLockVehicleHandler(t *testing.T) {
 		t.Fatal(errGet)
 	}
 	rrGet := httptest.NewRecorder()
-	handlerGet := http.HandlerFunc(DevLockVehicleHandler)
-	handlerGet.ServeHTTP(rrGet, reqGet)
+	s.Handler().ServeHTTP(rrGet, reqGet) // The route's pattern rejects other methods.
 
 	if status := rrGet.Code; status != http.StatusMethodNotAllowed {
 		t.Errorf("handler returned wrong status code for GET: got %v want %v", status, http.StatusMethodNotAllowed)
@@ -67,13 +145,14 @@ func TestDevLockVehicleHandler(t *testing.T) {
 
 // Add similar tests for DevUnlockVehicleHandler and DevGetCameraFeedHandler
 func TestDevUnlockVehicleHandler(t *testing.T) {
+	s := newTestServer(Config{})
 	// Test POST (successful)
 	reqPost, errPost := http.NewRequest("POST", "/api/dev/unlock", nil)
 	if errPost != nil {
 		t.Fatal(errPost)
 	}
 	rrPost := httptest.NewRecorder()
-	handlerPost := http.HandlerFunc(DevUnlockVehicleHandler)
+	handlerPost := http.HandlerFunc(s.DevUnlockVehicleHandler)
 	handlerPost.ServeHTTP(rrPost, reqPost)
 
 	if status := rrPost.Code; status != http.StatusOK {
@@ -90,8 +169,7 @@ func TestDevUnlockVehicleHandler(t *testing.T) {
 		t.Fatal(errGet)
 	}
 	rrGet := httptest.NewRecorder()
-	handlerGet := http.HandlerFunc(DevUnlockVehicleHandler)
-	handlerGet.ServeHTTP(rrGet, reqGet)
+	s.Handler().ServeHTTP(rrGet, reqGet) // The route's pattern rejects other methods.
 
 	if status := rrGet.Code; status != http.StatusMethodNotAllowed {
 		t.Errorf("handler returned wrong status code for GET: got %v want %v", status, http.StatusMethodNotAllowed)
@@ -99,13 +177,14 @@ func TestDevUnlockVehicleHandler(t *testing.T) {
 }
 
 func TestDevGetCameraFeedHandler(t *testing.T) {
+	s := newTestServer(Config{})
 	req, err := http.NewRequest("GET", "/api/dev/camera", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(DevGetCameraFeedHandler)
+	handler := http.HandlerFunc(s.DevGetCameraFeedHandler)
 	handler.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -113,21 +192,19 @@ func TestDevGetCameraFeedHandler(t *testing.T) {
 	}
 
 	expectedClient := tesla.NewMockClient()
-	expectedURL, _ := expectedClient.GetCameraFeed()
+	expectedURL, _ := expectedClient.GetCameraFeed(context.Background())
 	expectedBody := `{"camera_feed_url":"` + expectedURL + `"}`
 	if strings.TrimSpace(rr.Body.String()) != expectedBody {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expectedBody)
 	}
 }
 
-// Tests for Real API Handlers when realClient is not initialized
+// Tests for Real API Handlers when the server has no real client
 
-const expectedUnavailableErrorMessage = `{"error":"Real Tesla client not initialized. Check server configuration."}`
+const expectedUnavailableErrorMessage = `{"error":{"code":"not_configured","message":"Real Tesla client not initialized. Check server configuration.","retryable":false}}`
 
 func TestGetStatsHandler_RealClientUnavailable(t *testing.T) {
-	originalRealClient := realClient
-	realClient = nil
-	defer func() { realClient = originalRealClient }()
+	s := newTestServer(Config{})
 
 	req, err := http.NewRequest("GET", "/api/stats", nil) // Path doesn't matter here
 	if err != nil {
@@ -135,7 +212,7 @@ func TestGetStatsHandler_RealClientUnavailable(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(GetStatsHandler)
+	handler := http.HandlerFunc(s.GetStatsHandler)
 	handler.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusServiceUnavailable {
@@ -148,9 +225,7 @@ func TestGetStatsHandler_RealClientUnavailable(t *testing.T) {
 }
 
 func TestLockVehicleHandler_RealClientUnavailable(t *testing.T) {
-	originalRealClient := realClient
-	realClient = nil
-	defer func() { realClient = originalRealClient }()
+	s := newTestServer(Config{})
 
 	req, err := http.NewRequest("POST", "/api/lock", nil) // Path doesn't matter here
 	if err != nil {
@@ -158,7 +233,7 @@ func TestLockVehicleHandler_RealClientUnavailable(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(LockVehicleHandler)
+	handler := http.HandlerFunc(s.LockVehicleHandler)
 	handler.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusServiceUnavailable {
@@ -170,9 +245,7 @@ func TestLockVehicleHandler_RealClientUnavailable(t *testing.T) {
 }
 
 func TestUnlockVehicleHandler_RealClientUnavailable(t *testing.T) {
-	originalRealClient := realClient
-	realClient = nil
-	defer func() { realClient = originalRealClient }()
+	s := newTestServer(Config{})
 
 	req, err := http.NewRequest("POST", "/api/unlock", nil) // Path doesn't matter here
 	if err != nil {
@@ -180,7 +253,7 @@ func TestUnlockVehicleHandler_RealClientUnavailable(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(UnlockVehicleHandler)
+	handler := http.HandlerFunc(s.UnlockVehicleHandler)
 	handler.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusServiceUnavailable {
@@ -193,9 +266,7 @@ func TestUnlockVehicleHandler_RealClientUnavailable(t *testing.T) {
 }
 
 func TestGetCameraFeedHandler_RealClientUnavailable(t *testing.T) {
-	originalRealClient := realClient
-	realClient = nil
-	defer func() { realClient = originalRealClient }()
+	s := newTestServer(Config{})
 
 	req, err := http.NewRequest("GET", "/api/camera", nil) // Path doesn't matter here
 	if err != nil {
@@ -203,7 +274,7 @@ func TestGetCameraFeedHandler_RealClientUnavailable(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(GetCameraFeedHandler)
+	handler := http.HandlerFunc(s.GetCameraFeedHandler)
 	handler.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusServiceUnavailable {
@@ -214,3 +285,128 @@ func TestGetCameraFeedHandler_RealClientUnavailable(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %q want %q", rr.Body.String(), expectedUnavailableErrorMessage)
 	}
 }
+
+// blockingClient is a tesla.Client whose calls never complete until their context is done,
+// standing in for a vehicle that stops answering. Methods it does not override panic.
+type blockingClient struct{ tesla.Client }
+
+func (blockingClient) GetVehicleStats(ctx context.Context, categories ...tesla.StateCategory) (*tesla.VehicleState, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("getting vehicle data: %w", tesla.ErrTimeout)
+}
+
+func (blockingClient) LockVehicle(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, fmt.Errorf("locking vehicle: %w", tesla.ErrTimeout)
+}
+
+func (blockingClient) UnlockVehicle(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, fmt.Errorf("unlocking vehicle: %w", tesla.ErrTimeout)
+}
+
+func (blockingClient) GetCameraFeed(ctx context.Context) (string, error) {
+	<-ctx.Done()
+	return "", fmt.Errorf("getting camera feed: %w", tesla.ErrTimeout)
+}
+
+func TestLockVehicleHandler_Timeout(t *testing.T) {
+	timeouts := tesla.DefaultTimeouts()
+	timeouts.Command = 10 * time.Millisecond
+	s := newTestServer(Config{Client: blockingClient{}, Timeouts: timeouts})
+
+	req, err := http.NewRequest("POST", "/api/lock", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(s.LockVehicleHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusGatewayTimeout {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusGatewayTimeout)
+	}
+	if !strings.Contains(rr.Body.String(), "Vehicle did not respond in time") {
+		t.Errorf("handler returned unexpected body: got %q", rr.Body.String())
+	}
+}
+
+func TestGetStatsHandler_ClientDisconnects(t *testing.T) {
+	s := newTestServer(Config{Client: blockingClient{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", "/api/stats", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		http.HandlerFunc(s.GetStatsHandler).ServeHTTP(rr, req)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return after the request context was cancelled")
+	}
+}
+
+func TestVehicleRoutes(t *testing.T) {
+	registry := tesla.NewRegistry()
+	registry.Set("VIN1", tesla.NewMockClient())
+	unreachable := tesla.NewSupervisedClient("VIN2", func(context.Context) (tesla.Client, error) {
+		return nil, fmt.Errorf("no route to vehicle")
+	}, tesla.DefaultSupervisorOptions())
+	t.Cleanup(func() { unreachable.Close() })
+	registry.Set("VIN2", unreachable)
+	for _, err := unreachable.ConnectionState(); err == nil; _, err = unreachable.ConnectionState() {
+		time.Sleep(time.Millisecond) // The first connection attempt.
+	}
+	s := newTestServer(Config{Registry: registry})
+
+	tests := []struct {
+		name    string
+		method  string
+		vin     string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"stats", "GET", "VIN1", s.VehicleStatsHandler, http.StatusOK},
+		{"lock", "POST", "vin1", s.VehicleLockHandler, http.StatusOK},
+		{"camera", "GET", "VIN1", s.VehicleCameraFeedHandler, http.StatusOK},
+		{"disconnected vehicle", "GET", "VIN2", s.VehicleStatsHandler, http.StatusServiceUnavailable},
+		{"unknown vehicle", "POST", "VIN3", s.VehicleLockHandler, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/api/vehicles/"+tt.vin, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("vin", tt.vin)
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v (body %s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "/api/vehicles", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.ListVehiclesHandler).ServeHTTP(rr, req)
+	var body struct {
+		Vehicles []tesla.VehicleStatus `json:"vehicles"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Vehicles) != 2 || !body.Vehicles[0].Connected || body.Vehicles[1].Error == "" {
+		t.Errorf("ListVehiclesHandler returned %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// addKey expects {"public_key": PEM or hex, "role": "owner"|"driver", "form_factor": ...};
+// form_factor defaults to cloud_key.
+func addKey(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	var body struct {
+		PublicKey  string `json:"public_key"`
+		Role       string `json:"role"`
+		FormFactor string `json:"form_factor"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return false, err
+	}
+	publicKey, err := tesla.ParsePublicKey(body.PublicKey)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	role, err := tesla.ParseKeyRole(body.Role)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	formFactor, err := tesla.ParseKeyFormFactor(body.FormFactor)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	return client.AddKey(ctx, publicKey, role, formFactor)
+}
+
+// removeKey removes the key named by the {public_key} path parameter, in hex.
+func removeKey(ctx context.Context, client tesla.Client, r *http.Request) (bool, error) {
+	publicKey, err := tesla.ParsePublicKey(r.PathValue("public_key"))
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+	return client.RemoveKey(ctx, publicKey)
+}
+
+// serveKeys lists the keychain of the vehicle behind client on GET and enrolls a key on POST.
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	if r.Method == http.MethodPost {
+		s.serveCommand(w, r, client, addKey)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Command)
+	defer cancel()
+	keys, err := client.ListKeys(ctx)
+	if err != nil {
+		s.writeClientError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []tesla.VehicleKey{}
+	}
+	WriteJsonResponse(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// KeysHandler lists (GET) or enrolls a key on (POST) the default real vehicle.
+func (s *Server) KeysHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveKeys(w, r, client)
+	}
+}
+
+// RemoveKeyHandler removes the key {public_key} from the default real vehicle.
+func (s *Server) RemoveKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveCommand(w, r, client, removeKey)
+	}
+}
+
+// VehicleKeysHandler lists (GET) or enrolls a key on (POST) the vehicle named by {vin}.
+func (s *Server) VehicleKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveKeys(w, r, client)
+	}
+}
+
+// VehicleRemoveKeyHandler removes the key {public_key} from the vehicle named by {vin}.
+func (s *Server) VehicleRemoveKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveCommand(w, r, client, removeKey)
+	}
+}
+
+// DevKeysHandler lists (GET) or enrolls a key on (POST) the mock vehicle.
+func (s *Server) DevKeysHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveKeys(w, r, s.mockClient)
+}
+
+// DevRemoveKeyHandler removes the key {public_key} from the mock vehicle.
+func (s *Server) DevRemoveKeyHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveCommand(w, r, s.mockClient, removeKey)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// driverKey is a valid uncompressed P-256 point: the public key for the private scalar 10.
+const driverKey = "04cef66d6b2a3a993e591214d1ea223fb545ca6c471c48306e4c36069404c5723f878662a229aaae906e123cdd9d3b4c10590ded29fe751eeeca34bbaa44af0773"
+
+func TestDevKeysHandlers(t *testing.T) {
+	s := newTestServer(Config{})
+
+	list := func() []tesla.VehicleKey {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/api/dev/keys", nil)
+		rr := httptest.NewRecorder()
+		s.DevKeysHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET: got status %v want %v (body %s)", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var body struct {
+			Keys []tesla.VehicleKey `json:"keys"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("GET returned invalid JSON: %v", err)
+		}
+		return body.Keys
+	}
+	add := func(body string) int {
+		req, _ := http.NewRequest("POST", "/api/dev/keys", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		s.DevKeysHandler(rr, req)
+		return rr.Code
+	}
+	remove := func(key string) int {
+		req, _ := http.NewRequest("DELETE", "/api/dev/keys/"+key, nil)
+		req.SetPathValue("public_key", key)
+		rr := httptest.NewRecorder()
+		s.DevRemoveKeyHandler(rr, req)
+		return rr.Code
+	}
+
+	if keys := list(); len(keys) != 3 {
+		t.Fatalf("initial keychain: got %d keys, want 3", len(keys))
+	}
+	if code := add(`{"public_key":"` + driverKey + `","role":"driver"}`); code != http.StatusOK {
+		t.Fatalf("POST: got status %v want %v", code, http.StatusOK)
+	}
+	keys := list()
+	if len(keys) != 4 || keys[3].PublicKey != driverKey || keys[3].Role != tesla.KeyRoleDriver || keys[3].FormFactor != tesla.KeyFormFactorCloudKey {
+		t.Errorf("after POST: got %+v, want the driver cloud key in slot 3", keys)
+	}
+	if code := add(`{"public_key":"` + driverKey + `","role":"service"}`); code != http.StatusBadRequest {
+		t.Errorf("POST with an unknown role: got status %v want %v", code, http.StatusBadRequest)
+	}
+	if code := add(`{"public_key":"abc","role":"driver"}`); code != http.StatusBadRequest {
+		t.Errorf("POST with an invalid key: got status %v want %v", code, http.StatusBadRequest)
+	}
+
+	if code := remove(driverKey); code != http.StatusOK {
+		t.Errorf("DELETE: got status %v want %v", code, http.StatusOK)
+	}
+	if code := remove(driverKey); code != http.StatusNotFound {
+		t.Errorf("DELETE of a removed key: got status %v want %v", code, http.StatusNotFound)
+	}
+
+	req, _ := http.NewRequest("PUT", "/api/dev/keys", nil)
+	rr := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT: got status %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/middleware"
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// vehicleOp is an operation on one vehicle, run against the client its selector picked.
+type vehicleOp func(w http.ResponseWriter, r *http.Request, client tesla.Client)
+
+// vehicleRoute is one /api/v1 vehicle operation: the method and the path below a selector's
+// prefix it is served at.
+type vehicleRoute struct {
+	method string
+	path   string
+	admin  bool // Key management, which needs the admin role on the real selectors.
+	op     vehicleOp
+}
+
+// selector picks the vehicle an /api/v1 request below prefix acts on, writing the error response
+// itself when there is none. auth and admin guard the operations served through it.
+type selector struct {
+	prefix      string
+	client      func(w http.ResponseWriter, r *http.Request) (tesla.Client, bool)
+	auth, admin func(http.HandlerFunc) http.HandlerFunc
+}
+
+// noAuth leaves a route open, as the dev routes are.
+func noAuth(h http.HandlerFunc) http.HandlerFunc { return h }
+
+// selectors are the vehicles /api/v1 serves: the default real vehicle, a real vehicle by VIN, the
+// dev client and the dev client by VIN. Every vehicle route is served through each of them.
+func (s *Server) selectors() []selector {
+	return []selector{
+		{"/api/v1", s.defaultVehicle, middleware.APIKeyAuthMiddleware, middleware.AdminAuthMiddleware},
+		{"/api/v1/vehicles/{vin}", s.registryVehicle, middleware.APIKeyAuthMiddleware, middleware.AdminAuthMiddleware},
+		{"/api/v1/dev", s.devVehicle, noAuth, noAuth},
+		{"/api/v1/dev/vehicles/{vin}", s.devRegistryVehicle, noAuth, noAuth},
+	}
+}
+
+// defaultVehicle selects the default real vehicle, writing 503 when there is none.
+func (s *Server) defaultVehicle(w http.ResponseWriter, r *http.Request) (tesla.Client, bool) {
+	if s.realClient == nil {
+		writeError(w, http.StatusServiceUnavailable, "Real Tesla client not initialized. Check server configuration.")
+		return nil, false
+	}
+	return s.realClient, true
+}
+
+// registryVehicle selects the real vehicle named by {vin}.
+func (s *Server) registryVehicle(w http.ResponseWriter, r *http.Request) (tesla.Client, bool) {
+	return vehicleClient(w, r, s.registry)
+}
+
+// devVehicle selects the dev client.
+func (s *Server) devVehicle(w http.ResponseWriter, r *http.Request) (tesla.Client, bool) {
+	return s.mockClient, true
+}
+
+// devRegistryVehicle selects the dev vehicle named by {vin}.
+func (s *Server) devRegistryVehicle(w http.ResponseWriter, r *http.Request) (tesla.Client, bool) {
+	return vehicleClient(w, r, s.devRegistry)
+}
+
+// vehicleRoutes lists every vehicle operation once; registerV1 serves each through every
+// selector.
+func (s *Server) vehicleRoutes() []vehicleRoute {
+	routes := []vehicleRoute{
+		{method: http.MethodGet, path: "/stats", op: s.serveStats},
+		{method: http.MethodPost, path: "/lock", op: s.serveLock},
+		{method: http.MethodPost, path: "/unlock", op: s.serveUnlock},
+		{method: http.MethodPost, path: "/wake", op: s.serveWake},
+		{method: http.MethodGet, path: "/camera", op: s.serveCameraFeed},
+		{method: http.MethodGet, path: "/tires", op: s.serveTires},
+		{method: http.MethodGet, path: "/stream", op: s.serveStream},
+		{method: http.MethodGet, path: "/ws", op: s.serveSocket},
+		{method: http.MethodGet, path: "/keys", admin: true, op: s.serveKeys},
+		{method: http.MethodPost, path: "/keys", admin: true, op: s.serveKeys},
+		{method: http.MethodDelete, path: "/keys/{public_key}", admin: true, op: s.commandOp(removeKey)},
+	}
+	for _, commands := range []map[string]commandFunc{climateCommands, chargingCommands, closureCommands} {
+		for route, cmd := range commands {
+			routes = append(routes, vehicleRoute{method: http.MethodPost, path: "/" + route, op: s.commandOp(cmd)})
+		}
+	}
+	return routes
+}
+
+// commandOp serves cmd as a vehicle operation.
+func (s *Server) commandOp(cmd commandFunc) vehicleOp {
+	return func(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+		s.serveCommand(w, r, client, cmd)
+	}
+}
+
+// registerV1 adds the /api/v1 routes to mux. Their patterns carry the method, so the mux answers
+// any other method with 405 and an Allow header before a handler runs.
+func (s *Server) registerV1(mux *http.ServeMux) {
+	for _, sel := range s.selectors() {
+		for _, route := range s.vehicleRoutes() {
+			guard := sel.auth
+			if route.admin {
+				guard = sel.admin
+			}
+			mux.HandleFunc(route.method+" "+sel.prefix+route.path, guard(s.selected(sel, route.op)))
+		}
+	}
+
+	auth := middleware.APIKeyAuthMiddleware
+	mux.HandleFunc("GET /api/v1/vehicles", auth(s.ListVehiclesHandler))
+	mux.HandleFunc("GET /api/v1/camera/events", auth(s.CameraEventsHandler))
+	mux.HandleFunc("GET /api/v1/camera/events/{id}", auth(s.CameraEventHandler))
+	mux.HandleFunc("GET /api/v1/camera/events/{id}/timeline", auth(s.CameraTimelineHandler))
+	mux.HandleFunc("GET /api/v1/camera/events/{id}/thumbnail", auth(s.CameraThumbnailHandler))
+	mux.HandleFunc("GET /api/v1/camera/events/{id}/clips/{name}", auth(s.CameraClipHandler))
+	mux.HandleFunc("GET /api/v1/camera/events/{id}/clips/{name}/telemetry", auth(s.CameraClipTelemetryHandler))
+
+	mux.HandleFunc("GET /api/v1/dev/vehicles", s.DevListVehiclesHandler)
+	mux.HandleFunc("POST /api/v1/dev/simulator/advance", s.DevSimulatorAdvanceHandler)
+	mux.HandleFunc("POST /api/v1/dev/simulator/action", s.DevSimulatorActionHandler)
+	mux.HandleFunc("POST /api/v1/dev/simulator/scenario", s.DevSimulatorScenarioHandler)
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		mux.HandleFunc(method+" /api/v1/dev/faults", s.DevFaultsHandler)
+	}
+}
+
+// selected serves op against the vehicle sel picks for the request.
+func (s *Server) selected(sel selector, op vehicleOp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := sel.client(w, r); ok {
+			op(w, r, client)
+		}
+	}
+}
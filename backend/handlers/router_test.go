@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestV1Routes(t *testing.T) {
+	t.Setenv("TESLA_API_KEY", "secret")
+	t.Setenv("TESLA_ADMIN_API_KEY", "admin")
+	registry := tesla.NewRegistry()
+	registry.Set("VIN1", tesla.NewMockClient())
+	h := newTestServer(Config{Registry: registry}).Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		body   string
+		want   int
+	}{
+		{"dev stats", "GET", "/api/v1/dev/stats", "", "", http.StatusOK},
+		{"dev lock", "POST", "/api/v1/dev/lock", "", "", http.StatusOK},
+		{"dev command", "POST", "/api/v1/dev/charging/limit", "", `{"percent": 90}`, http.StatusOK},
+		{"dev command bad body", "POST", "/api/v1/dev/charging/limit", "", `{"percent": 20}`, http.StatusBadRequest},
+		{"dev vehicle by VIN", "GET", "/api/v1/dev/vehicles/" + tesla.MockVIN + "/tires", "", "", http.StatusOK},
+		{"dev keys without admin key", "GET", "/api/v1/dev/keys", "", "", http.StatusOK},
+		{"default vehicle", "GET", "/api/v1/stats", "secret", "", http.StatusOK},
+		{"default vehicle without key", "GET", "/api/v1/stats", "", "", http.StatusUnauthorized},
+		{"vehicle by VIN", "POST", "/api/v1/vehicles/VIN1/climate/start", "secret", "", http.StatusOK},
+		{"unknown vehicle", "POST", "/api/v1/vehicles/VIN9/lock", "secret", "", http.StatusNotFound},
+		{"keys need admin", "GET", "/api/v1/vehicles/VIN1/keys", "secret", "", http.StatusForbidden},
+		{"keys", "GET", "/api/v1/vehicles/VIN1/keys", "admin", "", http.StatusOK},
+		{"vehicle list", "GET", "/api/v1/vehicles", "secret", "", http.StatusOK},
+		{"faults", "DELETE", "/api/v1/dev/faults", "", "", http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set("X-API-KEY", tt.key)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("got status %v want %v (body %s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoutes_MethodNotAllowed(t *testing.T) {
+	h := newTestServer(Config{}).Handler()
+
+	for _, tt := range []struct {
+		method, path, allow string
+	}{
+		{"GET", "/api/v1/dev/lock", "POST"},
+		{"POST", "/api/v1/dev/stats", "GET, HEAD"},
+		{"PUT", "/api/v1/dev/keys", "GET, HEAD, POST"},
+		{"POST", "/api/v1/vehicles/VIN1/stats", "GET, HEAD"},
+		{"GET", "/api/dev/charging/limit", "POST"},
+		{"GET", "/api/dev/simulator/advance", "POST"},
+		{"POST", "/api/dev/faults", "DELETE, GET, HEAD, PUT"},
+		{"GET", "/api/vehicles/VIN1/unlock", "POST"},
+		{"GET", "/api/keys/abc", "DELETE"},
+		{"POST", "/api/camera/events/e/clips/c.mp4", "GET, HEAD"},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s: got %v with Allow %q, want %v with Allow %q",
+				tt.method, tt.path, rr.Code, rr.Header().Get("Allow"), http.StatusMethodNotAllowed, tt.allow)
+		}
+	}
+}
+
+func TestV1Routes_NoDefaultVehicle(t *testing.T) {
+	t.Setenv("TESLA_API_KEY", "secret")
+	h := newTestServer(Config{}).Handler()
+
+	req := httptest.NewRequest("POST", "/api/v1/lock", nil)
+	req.Header.Set("X-API-KEY", "secret")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	var resp errorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusServiceUnavailable || resp.Error.Code != "not_configured" || resp.Error.Retryable {
+		t.Errorf("got %v %s, want 503 not_configured", rr.Code, rr.Body.String())
+	}
+	if resp.Error.RequestID == "" || resp.Error.RequestID != rr.Header().Get(requestIDHeader) {
+		t.Errorf("error request ID %q, want the %s header %q", resp.Error.RequestID, requestIDHeader, rr.Header().Get(requestIDHeader))
+	}
+}
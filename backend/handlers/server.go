@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ameena3/tesla/backend/middleware"
+	"github.com/ameena3/tesla/backend/tesla"
+	"github.com/ameena3/tesla/backend/tesla/teslacam"
+)
+
+// Config is everything a Server depends on. Zero fields get the defaults described below, so a
+// test only sets what it cares about, e.g. Config{DevClient: tesla.NewMockClient()}.
+type Config struct {
+	// Registry holds one real client per vehicle and serves /api/vehicles/{vin}/...; it defaults to
+	// an empty registry.
+	Registry *tesla.Registry
+	// Client backs the legacy single-vehicle routes (/api/stats, /api/lock, ...). It defaults to
+	// the Registry's default vehicle; with neither, those routes answer 503.
+	Client tesla.Client
+	// DevClient backs the /api/dev routes, served under tesla.MockVIN; it defaults to a new
+	// simulator.
+	DevClient tesla.Client
+	// Timeouts bounds every client call; zero means tesla.DefaultTimeouts.
+	Timeouts tesla.Timeouts
+	// WakeBackoff is the polling schedule while waiting for a vehicle to wake; zero means
+	// tesla.DefaultWakeBackoff.
+	WakeBackoff tesla.Backoff
+	// TireThresholds bounds the pressures that raise tire alerts; zero means
+	// tesla.DefaultTireThresholds.
+	TireThresholds tesla.TireThresholds
+	// CameraArchive serves the /api/camera/events routes; nil answers them with 503.
+	CameraArchive *teslacam.Archive
+	// StreamInterval is how often a vehicle is polled while its state is streamed, and
+	// StreamHeartbeat how often an idle stream sends a heartbeat; zero means 10s and 15s.
+	StreamInterval  time.Duration
+	StreamHeartbeat time.Duration
+	// PollSchedules lists, by VIN, the vehicles polled in the background on a sleep-aware
+	// schedule; see tesla.Poller. Their stats routes answer from the poller's cache and their
+	// streams follow its polls. tesla.MockVIN names the dev client. Other vehicles are only polled
+	// while streamed, every StreamInterval.
+	PollSchedules map[string]tesla.PollSchedule
+	// Logger defaults to log.Default, Now to time.Now.
+	Logger *log.Logger
+	Now    func() time.Time
+}
+
+// Server serves the API for the clients in its Config. Create one with NewServer.
+type Server struct {
+	registry       *tesla.Registry
+	realClient     tesla.Client
+	mockClient     tesla.Client
+	devRegistry    *tesla.Registry
+	timeouts       tesla.Timeouts
+	wakeBackoff    tesla.Backoff
+	tireThresholds tesla.TireThresholds
+	cameraArchive  *teslacam.Archive
+	logger         *log.Logger
+	now            func() time.Time
+
+	streamInterval  time.Duration
+	streamHeartbeat time.Duration
+	streamsMu       sync.Mutex
+	streams         map[tesla.Client]*stateHub // One per vehicle streamed so far.
+	streamsDone     chan struct{}              // Closed by CloseStreams.
+	closeStreams    sync.Once
+
+	pollers map[tesla.Client]*tesla.Poller // The background pollers of Config.PollSchedules.
+}
+
+// NewServer returns a Server for cfg, filling in the defaults of its zero fields.
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		registry:       cfg.Registry,
+		realClient:     cfg.Client,
+		mockClient:     cfg.DevClient,
+		timeouts:       cfg.Timeouts,
+		wakeBackoff:    cfg.WakeBackoff,
+		tireThresholds: cfg.TireThresholds,
+		cameraArchive:  cfg.CameraArchive,
+		logger:         cfg.Logger,
+		now:            cfg.Now,
+
+		streamInterval:  cfg.StreamInterval,
+		streamHeartbeat: cfg.StreamHeartbeat,
+		streams:         map[tesla.Client]*stateHub{},
+		streamsDone:     make(chan struct{}),
+	}
+	if s.registry == nil {
+		s.registry = tesla.NewRegistry()
+	}
+	if s.realClient == nil {
+		if client, err := s.registry.Client(s.registry.DefaultVIN()); err == nil {
+			s.realClient = client
+		}
+	}
+	if s.mockClient == nil {
+		s.mockClient = tesla.NewMockClient()
+	}
+	s.devRegistry = tesla.NewRegistry()
+	s.devRegistry.Set(tesla.MockVIN, s.mockClient)
+	if s.timeouts == (tesla.Timeouts{}) {
+		s.timeouts = tesla.DefaultTimeouts()
+	}
+	if s.wakeBackoff == (tesla.Backoff{}) {
+		s.wakeBackoff = tesla.DefaultWakeBackoff
+	}
+	if s.tireThresholds == (tesla.TireThresholds{}) {
+		s.tireThresholds = tesla.DefaultTireThresholds()
+	}
+	if s.streamInterval == 0 {
+		s.streamInterval = defaultStreamInterval
+	}
+	if s.streamHeartbeat == 0 {
+		s.streamHeartbeat = defaultStreamHeartbeat
+	}
+	if s.logger == nil {
+		s.logger = log.Default()
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	s.pollers = map[tesla.Client]*tesla.Poller{}
+	for vin, schedule := range cfg.PollSchedules {
+		registry := s.registry
+		if vin == tesla.MockVIN {
+			registry = s.devRegistry
+		}
+		client, err := registry.Client(vin)
+		if err != nil {
+			s.logger.Printf("Not polling VIN %s: %v", vin, err)
+			continue
+		}
+		s.pollers[client] = tesla.NewPoller(client, schedule, s.timeouts.Stats)
+	}
+	return s
+}
+
+// ConfigFromEnvironment builds the production Config:
+//   - a real Tesla client for every VIN in TESLA_VINS (or the single TESLA_VIN), each connecting on
+//     its own so one failing does not affect the rest. Other credentials (TESLA_KEY_NAME,
+//     TESLA_TOKEN_NAME, TESLA_CACHE_FILE) are read by tesla.NewRealClient via pkg/cli.
+//   - the dev client: the vehicle simulator, or recorded fixtures when TESLA_REPLAY_FIXTURES is
+//     set, behind a fault injector that is off unless TESLA_MOCK_FAULTS or /api/dev/faults
+//     configures it.
+//   - a background poller for every real vehicle, on the schedule from
+//     tesla.PollScheduleFromEnvironment.
+//   - timeouts, tire thresholds and the TeslaCam archive from their environment variables.
+//
+// Invalid settings are logged and ignored.
+func ConfigFromEnvironment() Config {
+	cfg := Config{
+		Timeouts:       tesla.TimeoutsFromEnvironment(),
+		TireThresholds: tesla.TireThresholdsFromEnvironment(),
+		Logger:         log.Default(),
+	}
+	cfg.Registry = registryFromEnvironment(cfg.Timeouts)
+	cfg.PollSchedules = map[string]tesla.PollSchedule{}
+	for _, vin := range cfg.Registry.VINs() {
+		cfg.PollSchedules[vin] = tesla.PollScheduleFromEnvironment(vin)
+	}
+
+	var client tesla.Client = tesla.MockClientFromEnvironment()
+	replay, err := tesla.ReplayClientFromEnvironment()
+	switch {
+	case err != nil:
+		log.Printf("Ignoring TESLA_REPLAY_FIXTURES: %v", err)
+	case replay != nil:
+		log.Println("Dev routes replay recorded fixtures from", os.Getenv("TESLA_REPLAY_FIXTURES"))
+		client = replay
+	}
+	cfg.DevClient = tesla.NewFaultyClient(client, tesla.FaultConfigFromEnvironment())
+
+	archive, err := teslacam.ArchiveFromEnvironment()
+	if err != nil {
+		log.Printf("Ignoring TESLA_CAMERA_DIR: %v", err)
+	}
+	cfg.CameraArchive = archive
+	return cfg
+}
+
+// registryFromEnvironment registers a real Tesla client for every configured VIN.
+func registryFromEnvironment(timeouts tesla.Timeouts) *tesla.Registry {
+	registry := tesla.NewRegistry()
+	vins := tesla.VINsFromEnvironment()
+	if len(vins) == 0 {
+		log.Println("Neither TESLA_VINS nor TESLA_VIN environment variable set. Real Tesla clients will not be available.")
+		return registry
+	}
+
+	// Each vehicle gets a self-healing client that keeps retrying in the background, so a car
+	// that can't be reached at startup becomes available as soon as it can, without a restart.
+	opts := tesla.DefaultSupervisorOptions()
+	opts.ConnectTimeout = timeouts.Connect
+	for i, vin := range vins {
+		registry.Set(vin, tesla.NewSupervisedClient(vin, func(ctx context.Context) (tesla.Client, error) {
+			client, err := tesla.NewRealClient(ctx, vin)
+			if err != nil {
+				return nil, err
+			}
+			// With TESLA_RECORD_DIR set, every call is also written to a fixture file.
+			recorded, err := tesla.RecordFromEnvironment(vin, i+1, client)
+			if err != nil {
+				client.Close()
+				return nil, err
+			}
+			return recorded, nil
+		}, opts))
+		log.Println("Connecting real Tesla client in the background for VIN:", vin)
+	}
+	return registry
+}
+
+// nudgePoller tells the background poller of client, if it has one, that the vehicle was just
+// acted on: it may have changed, and it is awake.
+func (s *Server) nudgePoller(client tesla.Client) {
+	if poller := s.pollers[client]; poller != nil {
+		poller.Nudge()
+	}
+}
+
+// Close stops the background pollers and closes the real Tesla clients, which persists their
+// session caches. Call it after the HTTP server has stopped accepting requests.
+func (s *Server) Close() {
+	for _, poller := range s.pollers {
+		poller.Close()
+	}
+	for vin, err := range s.registry.Close() {
+		s.logger.Printf("Error closing Tesla client for VIN %s: %v", vin, err)
+	}
+}
+
+// Handler returns the API: the dev routes, which need no auth, and the real routes behind
+// middleware.APIKeyAuthMiddleware (TESLA_API_KEY), with key management behind
+// middleware.AdminAuthMiddleware (TESLA_ADMIN_API_KEY). The same operations are served under
+// /api/v1; see registerV1. Every pattern carries its method, so the mux answers any other method
+// with 405 and an Allow header. Every request is logged.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	auth := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, middleware.APIKeyAuthMiddleware(h))
+	}
+	admin := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, middleware.AdminAuthMiddleware(h))
+	}
+
+	// Dev API routes (no auth needed)
+	mux.HandleFunc("GET /api/dev/stats", s.DevGetStatsHandler)
+	mux.HandleFunc("POST /api/dev/lock", s.DevLockVehicleHandler)
+	mux.HandleFunc("POST /api/dev/unlock", s.DevUnlockVehicleHandler)
+	mux.HandleFunc("POST /api/dev/wake", s.DevWakeHandler)
+	mux.HandleFunc("GET /api/dev/camera", s.DevGetCameraFeedHandler)
+	mux.HandleFunc("GET /api/dev/tires", s.DevGetTiresHandler)
+	mux.HandleFunc("GET /api/dev/stream", s.DevStreamHandler)
+	mux.HandleFunc("GET /api/dev/ws", s.DevSocketHandler)
+	mux.HandleFunc("GET /api/dev/keys", s.DevKeysHandler)
+	mux.HandleFunc("POST /api/dev/keys", s.DevKeysHandler)
+	mux.HandleFunc("DELETE /api/dev/keys/{public_key}", s.DevRemoveKeyHandler)
+	mux.HandleFunc("GET /api/dev/vehicles", s.DevListVehiclesHandler)
+	mux.HandleFunc("GET /api/dev/vehicles/{vin}/stats", s.DevVehicleStatsHandler)
+	mux.HandleFunc("POST /api/dev/vehicles/{vin}/lock", s.DevVehicleLockHandler)
+	mux.HandleFunc("POST /api/dev/vehicles/{vin}/unlock", s.DevVehicleUnlockHandler)
+	mux.HandleFunc("GET /api/dev/vehicles/{vin}/camera", s.DevVehicleCameraFeedHandler)
+	mux.HandleFunc("POST /api/dev/simulator/advance", s.DevSimulatorAdvanceHandler)
+	mux.HandleFunc("POST /api/dev/simulator/action", s.DevSimulatorActionHandler)
+	mux.HandleFunc("POST /api/dev/simulator/scenario", s.DevSimulatorScenarioHandler)
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		mux.HandleFunc(method+" /api/dev/faults", s.DevFaultsHandler)
+	}
+
+	// Real API routes; the legacy ones act on the first VIN in TESLA_VINS.
+	auth("GET /api/stats", s.GetStatsHandler)
+	auth("POST /api/lock", s.LockVehicleHandler)
+	auth("POST /api/unlock", s.UnlockVehicleHandler)
+	auth("POST /api/wake", s.WakeHandler)
+	auth("GET /api/camera", s.GetCameraFeedHandler)
+	auth("GET /api/camera/events", s.CameraEventsHandler)
+	auth("GET /api/camera/events/{id}", s.CameraEventHandler)
+	auth("GET /api/camera/events/{id}/timeline", s.CameraTimelineHandler)
+	auth("GET /api/camera/events/{id}/thumbnail", s.CameraThumbnailHandler)
+	auth("GET /api/camera/events/{id}/clips/{name}", s.CameraClipHandler)
+	auth("GET /api/camera/events/{id}/clips/{name}/telemetry", s.CameraClipTelemetryHandler)
+	auth("GET /api/tires", s.GetTiresHandler)
+	auth("GET /api/stream", s.StreamHandler)
+	auth("GET /api/ws", s.SocketHandler)
+	admin("GET /api/keys", s.KeysHandler)
+	admin("POST /api/keys", s.KeysHandler)
+	admin("DELETE /api/keys/{public_key}", s.RemoveKeyHandler)
+
+	// Per-vehicle routes
+	auth("GET /api/vehicles", s.ListVehiclesHandler)
+	auth("GET /api/vehicles/{vin}/stats", s.VehicleStatsHandler)
+	auth("POST /api/vehicles/{vin}/lock", s.VehicleLockHandler)
+	auth("POST /api/vehicles/{vin}/unlock", s.VehicleUnlockHandler)
+	auth("POST /api/vehicles/{vin}/wake", s.VehicleWakeHandler)
+	auth("GET /api/vehicles/{vin}/camera", s.VehicleCameraFeedHandler)
+	auth("GET /api/vehicles/{vin}/tires", s.VehicleTiresHandler)
+	auth("GET /api/vehicles/{vin}/stream", s.VehicleStreamHandler)
+	auth("GET /api/vehicles/{vin}/ws", s.VehicleSocketHandler)
+	admin("GET /api/vehicles/{vin}/keys", s.VehicleKeysHandler)
+	admin("POST /api/vehicles/{vin}/keys", s.VehicleKeysHandler)
+	admin("DELETE /api/vehicles/{vin}/keys/{public_key}", s.VehicleRemoveKeyHandler)
+
+	for _, commands := range []map[string]commandFunc{climateCommands, chargingCommands, closureCommands} {
+		for route, cmd := range commands {
+			mux.HandleFunc("POST /api/dev/"+route, s.devCommand(cmd))
+			auth("POST /api/"+route, s.realCommand(cmd))
+			auth("POST /api/vehicles/{vin}/"+route, s.vehicleCommand(cmd))
+		}
+	}
+
+	// The unversioned routes above are kept for existing clients.
+	s.registerV1(mux)
+	return s.logRequests(unmatchedErrors(mux))
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+// Hijack lets a WebSocket handshake take over the connection, which switches protocols.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
+// logRequests gives every request served by next an ID, returned in X-Request-ID, and logs it
+// with the method, path, status and duration. A caller's own X-Request-ID is kept if it is
+// usable, so a request can be traced across services.
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := s.now()
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		s.logger.Printf("%s %s %s %d %s", id, r.Method, r.URL.Path, rec.status, s.now().Sub(start))
+	})
+}
+
+// newRequestID returns a random 16-character hex ID.
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID reports whether a caller-supplied ID is short and safe to echo and log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// newTestServer returns NewServer(cfg) with request logging discarded unless cfg sets a Logger.
+func newTestServer(cfg Config) *Server {
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(io.Discard, "", 0)
+	}
+	return NewServer(cfg)
+}
+
+func TestNewServer_Defaults(t *testing.T) {
+	registry := tesla.NewRegistry()
+	vehicle := tesla.NewMockClient()
+	registry.Set("VIN1", vehicle)
+	s := newTestServer(Config{Registry: registry})
+
+	if s.realClient != vehicle {
+		t.Errorf("real client = %v, want the registry's default vehicle", s.realClient)
+	}
+	if _, ok := s.mockClient.(*tesla.MockClient); !ok {
+		t.Errorf("dev client = %T, want a simulator", s.mockClient)
+	}
+	if client, err := s.devRegistry.Client(tesla.MockVIN); err != nil || client != s.mockClient {
+		t.Errorf("dev registry serves %v (%v), want the dev client", client, err)
+	}
+	if s.timeouts != tesla.DefaultTimeouts() || s.wakeBackoff != tesla.DefaultWakeBackoff || s.tireThresholds != tesla.DefaultTireThresholds() {
+		t.Errorf("zero settings not defaulted: %+v %+v %+v", s.timeouts, s.wakeBackoff, s.tireThresholds)
+	}
+}
+
+func TestServerHandler(t *testing.T) {
+	t.Setenv("TESLA_API_KEY", "secret")
+	t.Setenv("TESLA_ADMIN_API_KEY", "admin")
+	var logs bytes.Buffer
+	clock := time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC)
+	h := NewServer(Config{
+		Client: tesla.NewMockClient(),
+		Logger: log.New(&logs, "", 0),
+		Now: func() time.Time {
+			clock = clock.Add(time.Second)
+			return clock
+		},
+	}).Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{"dev route without key", "POST", "/api/dev/lock", "", http.StatusOK},
+		{"dev command", "POST", "/api/dev/climate/start", "", http.StatusOK},
+		{"real route without key", "GET", "/api/stats", "", http.StatusUnauthorized},
+		{"real route", "GET", "/api/stats", "secret", http.StatusOK},
+		{"real command", "POST", "/api/charging/port/open", "secret", http.StatusOK},
+		{"vehicle command for unknown vehicle", "POST", "/api/vehicles/VIN9/closures/frunk", "secret", http.StatusNotFound},
+		{"admin route with regular key", "GET", "/api/keys", "secret", http.StatusForbidden},
+		{"admin route", "GET", "/api/keys", "admin", http.StatusOK},
+		{"unknown route", "GET", "/api/nothing", "secret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-KEY", tt.key)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("got status %v want %v (body %s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	if !strings.Contains(logs.String(), " GET /api/stats 401 1s\n") {
+		t.Errorf("request log %q does not record the rejected stats request", logs.String())
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// simulator returns the simulator behind the mock client, writing 501 when the dev routes are
+// not backed by one.
+func (s *Server) simulator(w http.ResponseWriter) (*tesla.MockClient, bool) {
+	client := s.mockClient
+	if wrapper, ok := client.(interface{ Unwrap() tesla.Client }); ok {
+		client = wrapper.Unwrap()
+	}
+	mc, ok := client.(*tesla.MockClient)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "The dev client is not a simulator")
+		return nil, false
+	}
+	return mc, true
+}
+
+// DevSimulatorAdvanceHandler moves the simulated clock forward by {"duration": "10m"} and
+// returns the resulting vehicle state.
+func (s *Server) DevSimulatorAdvanceHandler(w http.ResponseWriter, r *http.Request) {
+	mc, ok := s.simulator(w)
+	if !ok {
+		return
+	}
+	var body struct {
+		Duration tesla.Duration `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if body.Duration <= 0 {
+		writeError(w, http.StatusBadRequest, "duration must be positive")
+		return
+	}
+	mc.Advance(time.Duration(body.Duration))
+	WriteJsonResponse(w, http.StatusOK, mc.Snapshot())
+}
+
+// DevSimulatorActionHandler applies a tesla.SimAction, e.g. {"action": "drive", "speed_mph": 40},
+// and returns the resulting vehicle state.
+func (s *Server) DevSimulatorActionHandler(w http.ResponseWriter, r *http.Request) {
+	mc, ok := s.simulator(w)
+	if !ok {
+		return
+	}
+	var action tesla.SimAction
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if err := mc.Apply(action); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	WriteJsonResponse(w, http.StatusOK, mc.Snapshot())
+}
+
+// DevSimulatorScenarioHandler loads the scenario in the request body, JSON or YAML according to
+// its Content-Type, and returns the starting vehicle state.
+func (s *Server) DevSimulatorScenarioHandler(w http.ResponseWriter, r *http.Request) {
+	mc, ok := s.simulator(w)
+	if !ok {
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isYAML := mediaType == "application/yaml" || mediaType == "application/x-yaml" || mediaType == "text/yaml"
+	sc, err := tesla.ParseScenario(data, isYAML)
+	if err == nil {
+		err = mc.LoadScenario(sc)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	WriteJsonResponse(w, http.StatusOK, mc.Snapshot())
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestDevSimulatorHandlers(t *testing.T) {
+	mock := tesla.NewMockClientWithClock(tesla.NewManualClock(time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC)))
+	s := newTestServer(Config{DevClient: mock})
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		contentType string
+		body        string
+		want        int
+	}{
+		{"unplug", s.DevSimulatorActionHandler, "", `{"action": "unplug"}`, http.StatusOK},
+		{"drive", s.DevSimulatorActionHandler, "", `{"action": "drive", "speed_mph": 60}`, http.StatusOK},
+		{"open door while driving", s.DevSimulatorActionHandler, "", `{"action": "open_door", "door": "driver_front"}`, http.StatusConflict},
+		{"unknown action", s.DevSimulatorActionHandler, "", `{"action": "fly"}`, http.StatusConflict},
+		{"advance", s.DevSimulatorAdvanceHandler, "", `{"duration": "30m"}`, http.StatusOK},
+		{"advance without duration", s.DevSimulatorAdvanceHandler, "", `{}`, http.StatusBadRequest},
+		{"yaml scenario", s.DevSimulatorScenarioHandler, "application/yaml", "name: parked\nsteps:\n  - {action: lock}\n", http.StatusOK},
+		{"bad scenario", s.DevSimulatorScenarioHandler, "application/json", `{"steps": 3}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/api/dev/simulator", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v (body %s)", rr.Code, tt.want, rr.Body.String())
+			}
+			if tt.name == "advance" {
+				var state tesla.VehicleState
+				if err := json.Unmarshal(rr.Body.Bytes(), &state); err != nil {
+					t.Fatal(err)
+				}
+				if *state.Drive.OdometerMiles != 12345.6+30 {
+					t.Errorf("odometer after 30 min at 60 mph = %v", *state.Drive.OdometerMiles)
+				}
+			}
+		})
+	}
+
+	// The scenario replaced the vehicle and ran its lock step.
+	if !*mock.Snapshot().Security.Locked {
+		t.Error("scenario lock step did not run")
+	}
+}
+
+func TestDevSimulatorHandlers_NotASimulator(t *testing.T) {
+	s := newTestServer(Config{DevClient: blockingClient{}})
+	req, err := http.NewRequest("POST", "/api/dev/simulator/advance", strings.NewReader(`{"duration": "1m"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	s.DevSimulatorAdvanceHandler(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+	"github.com/gorilla/websocket"
+)
+
+// socketProtocol is the WebSocket subprotocol of the channel. A browser offers it alongside the
+// middleware.APIKeyProtocolPrefix one that carries its API key, and the server picks it.
+const socketProtocol = "tesla-dashboard.v1"
+
+const socketWriteTimeout = 10 * time.Second
+
+// socketRequest is a message from a WebSocket client. Every request is answered with an ack
+// carrying its ID; a command is answered again with its result once it has run.
+type socketRequest struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`    // subscribe, unsubscribe or command.
+	Topics  []string        `json:"topics"`  // subscribe, unsubscribe: state categories, e.g. "charge".
+	Command string          `json:"command"` // command: a command route, e.g. "lock" or "climate/start".
+	Params  json.RawMessage `json:"params"`  // command: the body the command's REST route takes.
+	Wake    bool            `json:"wake"`    // command: wake the vehicle if asleep, as ?wake=true.
+}
+
+// socketMessage is a message to a WebSocket client. Its Type is one of:
+//   - ack: the request ID was accepted, or rejected with Error.
+//   - result: the command ID finished, with Success or Error.
+//   - snapshot: Data is the whole of Topic, sent when it is subscribed.
+//   - state: Data has the fields of Topic that changed; see stateHub.
+//   - error: polling the vehicle failed with Error.
+type socketMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Success *bool           `json:"success,omitempty"`
+	Error   *errorBody      `json:"error,omitempty"`
+}
+
+// socketCommands are the commands a WebSocket client can send, by route. Key management is
+// left to the admin REST routes.
+func socketCommands() map[string]commandFunc {
+	commands := map[string]commandFunc{"lock": lockVehicle, "unlock": unlockVehicle}
+	for _, m := range []map[string]commandFunc{climateCommands, chargingCommands, closureCommands} {
+		for route, cmd := range m {
+			commands[route] = cmd
+		}
+	}
+	return commands
+}
+
+// socket is one WebSocket connection to the vehicle behind client.
+type socket struct {
+	s         *Server
+	conn      *websocket.Conn
+	client    tesla.Client
+	hub       *stateHub
+	commands  map[string]commandFunc
+	requestID string          // Of the handshake, quoted in errors.
+	ctx       context.Context // Done when the connection closes.
+
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	topics map[string]bool
+	events chan streamEvent // From hub while any topic is subscribed.
+	lastID string           // Of the last state event received from hub.
+}
+
+// serveSocket serves a WebSocket channel to the vehicle behind client: the client subscribes to
+// state topics, which are fed by the same stateHub as the event streams, and sends commands.
+// The connection is pinged every heartbeat interval and closed if it stops answering.
+func (s *Server) serveSocket(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{socketProtocol},
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			writeError(w, status, reason.Error())
+		},
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has answered.
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sock := &socket{
+		s:         s,
+		conn:      conn,
+		client:    client,
+		hub:       s.stateHub(client),
+		commands:  socketCommands(),
+		requestID: w.Header().Get(requestIDHeader),
+		ctx:       ctx,
+		topics:    map[string]bool{},
+	}
+	defer sock.unsubscribe(nil)
+
+	readTimeout := 2 * s.streamHeartbeat
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	go sock.heartbeat()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req socketRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			sock.reply(req.ID, "ack", badRequestError{fmt.Sprintf("Invalid message: %v", err)})
+			continue
+		}
+		sock.handle(req)
+	}
+}
+
+// heartbeat pings the client until the connection closes, and closes it when the server shuts
+// down.
+func (sock *socket) heartbeat() {
+	ticker := time.NewTicker(sock.s.streamHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sock.ctx.Done():
+			return
+		case <-sock.s.streamsDone:
+			sock.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(socketWriteTimeout))
+			sock.conn.Close()
+			return
+		case <-ticker.C:
+			if err := sock.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout)); err != nil {
+				sock.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// send writes msg to the client.
+func (sock *socket) send(msg socketMessage) error {
+	sock.writeMu.Lock()
+	defer sock.writeMu.Unlock()
+	sock.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	return sock.conn.WriteJSON(msg)
+}
+
+// reply sends the ack or result of request id: a success, or the error a command failed with.
+func (sock *socket) reply(id, kind string, err error) {
+	msg := socketMessage{ID: id, Type: kind}
+	if err != nil {
+		e, message := commandError(err)
+		msg.Error = &errorBody{Code: e.code, Message: message, Retryable: e.retryable, RequestID: sock.requestID}
+	}
+	sock.send(msg)
+}
+
+func (sock *socket) handle(req socketRequest) {
+	switch req.Type {
+	case "subscribe", "unsubscribe":
+		for _, topic := range req.Topics {
+			if !tesla.StateCategory(topic).Valid() {
+				sock.reply(req.ID, "ack", badRequestError{fmt.Sprintf("unknown topic %q", topic)})
+				return
+			}
+		}
+		sock.reply(req.ID, "ack", nil)
+		if req.Type == "subscribe" {
+			if len(req.Topics) == 0 {
+				req.Topics = allTopics()
+			}
+			sock.subscribe(req.Topics)
+		} else {
+			sock.unsubscribe(req.Topics)
+		}
+	case "command":
+		cmd, ok := sock.commands[req.Command]
+		if !ok {
+			sock.reply(req.ID, "ack", badRequestError{fmt.Sprintf("unknown command %q", req.Command)})
+			return
+		}
+		sock.reply(req.ID, "ack", nil)
+		go sock.run(req, cmd)
+	default:
+		sock.reply(req.ID, "ack", badRequestError{fmt.Sprintf("unknown message type %q", req.Type)})
+	}
+}
+
+// run runs the command req and sends its result.
+func (sock *socket) run(req socketRequest, cmd commandFunc) {
+	r, _ := http.NewRequestWithContext(sock.ctx, http.MethodPost, "/"+req.Command, nil)
+	success, err := sock.s.runCommand(sock.ctx, r, sock.client, cmd, req.Params, req.Wake)
+	if err != nil {
+		if sock.ctx.Err() == nil {
+			sock.reply(req.ID, "result", err)
+		}
+		return
+	}
+	sock.send(socketMessage{ID: req.ID, Type: "result", Success: &success})
+}
+
+// subscribe adds topics, sending a snapshot of each new one. The first topic subscribes the
+// socket to its stateHub.
+func (sock *socket) subscribe(topics []string) {
+	sock.mu.Lock()
+	defer sock.mu.Unlock()
+	if sock.events == nil {
+		for _, topic := range topics {
+			sock.topics[topic] = true
+		}
+		// Start from a snapshot: the topics may not have been subscribed to before.
+		events, backlog := sock.hub.subscribe("")
+		sock.events = events
+		go sock.forward(events, backlog)
+		return
+	}
+
+	var added []string
+	for _, topic := range topics {
+		if !sock.topics[topic] {
+			sock.topics[topic] = true
+			added = append(added, topic)
+		}
+	}
+	if state := sock.hub.current(); state != nil {
+		sock.sendTopics("snapshot", state, added)
+	}
+}
+
+// unsubscribe removes topics, or all of them if there are none. The socket leaves its stateHub
+// with the last one.
+func (sock *socket) unsubscribe(topics []string) {
+	sock.mu.Lock()
+	defer sock.mu.Unlock()
+	if len(topics) == 0 {
+		clear(sock.topics)
+	}
+	for _, topic := range topics {
+		delete(sock.topics, topic)
+	}
+	if len(sock.topics) == 0 && sock.events != nil {
+		events := sock.events
+		sock.events = nil
+		sock.hub.unsubscribe(events)
+	}
+}
+
+// forward sends backlog and then the events from the stateHub to the client. If the hub drops
+// the socket for falling behind, it resubscribes from the last event received.
+func (sock *socket) forward(events chan streamEvent, backlog []streamEvent) {
+	for {
+		for _, event := range backlog {
+			sock.deliver(event)
+		}
+		for event := range events {
+			sock.deliver(event)
+		}
+
+		sock.mu.Lock()
+		if sock.events != events || sock.ctx.Err() != nil {
+			sock.mu.Unlock()
+			return // Unsubscribed.
+		}
+		events, backlog = sock.hub.subscribe(sock.lastID)
+		sock.events = events
+		sock.mu.Unlock()
+	}
+}
+
+// deliver sends the messages for a stateHub event.
+func (sock *socket) deliver(event streamEvent) {
+	if event.name == "error" {
+		var body errorBody
+		json.Unmarshal(event.data, &body)
+		body.RequestID = sock.requestID
+		sock.send(socketMessage{Type: "error", Error: &body})
+		return
+	}
+	var state map[string]any
+	if err := json.Unmarshal(event.data, &state); err != nil {
+		return
+	}
+	sock.mu.Lock()
+	defer sock.mu.Unlock()
+	if event.id != "" {
+		sock.lastID = event.id
+	}
+	sock.sendTopics(event.name, state, slices.Collect(maps.Keys(sock.topics)))
+}
+
+// sendTopics sends the part of state under each of topics that it has as a message of type
+// kind. sock.mu must be held.
+func (sock *socket) sendTopics(kind string, state map[string]any, topics []string) {
+	for _, category := range tesla.AllStateCategories {
+		topic := string(category)
+		if value, ok := state[topic]; ok && slices.Contains(topics, topic) {
+			data, _ := json.Marshal(value)
+			sock.send(socketMessage{Type: kind, Topic: topic, Data: data})
+		}
+	}
+}
+
+// allTopics returns every state category, the topics subscribed to when none are named.
+func allTopics() []string {
+	var topics []string
+	for _, category := range tesla.AllStateCategories {
+		topics = append(topics, string(category))
+	}
+	return topics
+}
+
+// SocketHandler serves a WebSocket channel to the default real vehicle.
+func (s *Server) SocketHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveSocket(w, r, client)
+	}
+}
+
+// VehicleSocketHandler serves a WebSocket channel to the vehicle named by {vin}.
+func (s *Server) VehicleSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveSocket(w, r, client)
+	}
+}
+
+// DevSocketHandler serves a WebSocket channel to the mock vehicle.
+func (s *Server) DevSocketHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveSocket(w, r, s.mockClient)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+	"github.com/gorilla/websocket"
+)
+
+// dialSocket opens the WebSocket channel at path on srv with the given handshake header.
+func dialSocket(t *testing.T, srv *httptest.Server, path string, header http.Header) *websocket.Conn {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+path, header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("dial %s: %v (status %d)", path, err, status)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readMessage returns the next message on conn of type kind, skipping others.
+func readMessage(t *testing.T, conn *websocket.Conn, kind string) socketMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg socketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for a %s message: %v", kind, err)
+		}
+		if msg.Type == kind {
+			return msg
+		}
+	}
+}
+
+func TestSocket(t *testing.T) {
+	mock := tesla.NewMockClient()
+	srv := httptest.NewServer(newTestServer(Config{DevClient: mock, StreamInterval: 10 * time.Millisecond}).Handler())
+	t.Cleanup(srv.Close)
+	conn := dialSocket(t, srv, "/api/v1/dev/ws", nil)
+
+	conn.WriteJSON(socketRequest{ID: "1", Type: "subscribe", Topics: []string{"security", "charge"}})
+	if ack := readMessage(t, conn, "ack"); ack.ID != "1" || ack.Error != nil {
+		t.Fatalf("subscribe ack = %+v", ack)
+	}
+	topics := map[string]bool{}
+	for len(topics) < 2 {
+		msg := readMessage(t, conn, "state")
+		if msg.Topic != "security" && msg.Topic != "charge" {
+			t.Errorf("state of unsubscribed topic %q", msg.Topic)
+		}
+		topics[msg.Topic] = true
+	}
+
+	conn.WriteJSON(socketRequest{ID: "2", Type: "command", Command: "unlock"})
+	if ack := readMessage(t, conn, "ack"); ack.ID != "2" || ack.Error != nil {
+		t.Fatalf("command ack = %+v", ack)
+	}
+	var sawResult, sawUnlocked bool
+	for !sawResult || !sawUnlocked {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg socketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg.Type == "result":
+			if msg.ID != "2" || msg.Success == nil || !*msg.Success {
+				t.Errorf("unlock result = %+v", msg)
+			}
+			sawResult = true
+		case msg.Type == "state" && msg.Topic == "security":
+			var security tesla.SecurityState
+			json.Unmarshal(msg.Data, &security)
+			sawUnlocked = security.Locked != nil && !*security.Locked
+		}
+	}
+
+	// A topic added later starts with a snapshot.
+	conn.WriteJSON(socketRequest{ID: "3", Type: "subscribe", Topics: []string{"climate"}})
+	if msg := readMessage(t, conn, "snapshot"); msg.Topic != "climate" || !strings.Contains(string(msg.Data), "inside_temp_celsius") {
+		t.Errorf("climate snapshot = %+v", msg)
+	}
+
+	tests := []struct {
+		req  socketRequest
+		code string
+	}{
+		{socketRequest{ID: "4", Type: "subscribe", Topics: []string{"weather"}}, "bad_request"},
+		{socketRequest{ID: "5", Type: "command", Command: "keys"}, "bad_request"},
+		{socketRequest{ID: "6", Type: "dance"}, "bad_request"},
+	}
+	for _, tt := range tests {
+		conn.WriteJSON(tt.req)
+		if ack := readMessage(t, conn, "ack"); ack.ID != tt.req.ID || ack.Error == nil || ack.Error.Code != tt.code || ack.Error.RequestID == "" {
+			t.Errorf("ack of %+v = %+v, want error %s", tt.req, ack, tt.code)
+		}
+	}
+
+	// Invalid arguments are accepted but fail like the REST route.
+	conn.WriteJSON(socketRequest{ID: "7", Type: "command", Command: "charging/limit", Params: json.RawMessage(`{"percent": 20}`)})
+	readMessage(t, conn, "ack")
+	if result := readMessage(t, conn, "result"); result.ID != "7" || result.Error == nil || result.Error.Code != "bad_request" {
+		t.Errorf("charging/limit 20%% result = %+v", result)
+	}
+}
+
+func TestSocket_Auth(t *testing.T) {
+	t.Setenv("TESLA_API_KEY", "secret")
+	srv := httptest.NewServer(newTestServer(Config{Client: tesla.NewMockClient()}).Handler())
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("dial without an API key: %v, want 401", err)
+	}
+
+	// Browsers send the key as a subprotocol and get the channel's own back.
+	conn := dialSocket(t, srv, "/api/ws", http.Header{"Sec-WebSocket-Protocol": {socketProtocol + ", api-key.secret"}})
+	if conn.Subprotocol() != socketProtocol {
+		t.Errorf("subprotocol = %q, want %q", conn.Subprotocol(), socketProtocol)
+	}
+	dialSocket(t, srv, "/api/v1/ws", http.Header{"X-API-KEY": {"secret"}})
+
+	// Plain HTTP requests get an error response.
+	req, _ := http.NewRequest("GET", srv.URL+"/api/dev/ws", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusBadRequest || body.Error.Code != "bad_request" {
+		t.Errorf("plain GET answered %v %+v (%v), want 400 bad_request", resp.StatusCode, body, err)
+	}
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+const (
+	defaultStreamInterval  = 10 * time.Second
+	defaultStreamHeartbeat = 15 * time.Second
+
+	streamHistory = 100             // State events a stateHub keeps for resuming streams.
+	streamBuffer  = 16              // Events a stream may fall behind before it is dropped.
+	streamRetry   = 5 * time.Second // How long browsers wait before reconnecting.
+)
+
+// streamEvent is one server-sent event. Only state events have an ID, as only they are replayed
+// to a resuming stream.
+type streamEvent struct {
+	seq  uint64
+	id   string
+	name string
+	data []byte // One line of JSON.
+}
+
+func (e streamEvent) writeTo(w io.Writer) error {
+	var b bytes.Buffer
+	if e.id != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.id)
+	}
+	fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", e.name, e.data)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// stateHub follows the polls of one vehicle while any stream of its state is open and fans the
+// changes out to all of them, so several dashboards cost no more SDK calls than one. It sends three
+// events:
+//   - snapshot: the whole state, to a stream that connects without a Last-Event-ID.
+//   - state: the fields that changed since the previous state event; the first one after the hub
+//     starts has them all. A field that disappeared is null.
+//   - error: the error body of a failed poll, sent once until a poll succeeds again.
+//
+// The last streamHistory state events are kept, so a stream that reconnects with a Last-Event-ID
+// gets the ones it missed instead of a snapshot.
+type stateHub struct {
+	// acquire returns the poller to follow when the first stream opens, and the function to call
+	// when the last one closes.
+	acquire func() (*tesla.Poller, func())
+	epoch   string // Tells this hub's event IDs from another's, e.g. from before a restart.
+
+	mu       sync.Mutex
+	streams  map[chan streamEvent]bool
+	release  func()         // Stops following the poller; nil while no stream is open.
+	gen      uint64         // Counts the times the poller was followed, to ignore late polls.
+	seq      uint64         // Of the last state event.
+	state    map[string]any // The last state polled, as JSON.
+	history  []streamEvent  // The last state events, oldest first.
+	errEvent *streamEvent   // Set while polls fail.
+	errCode  string
+}
+
+func newStateHub(acquire func() (*tesla.Poller, func())) *stateHub {
+	return &stateHub{acquire: acquire, epoch: newRequestID(), streams: map[chan streamEvent]bool{}}
+}
+
+// subscribe opens a stream that resumes after lastEventID, returning its channel and the events
+// to send first. The hub starts following the poller with the first stream.
+func (h *stateHub) subscribe(lastEventID string) (chan streamEvent, []streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.release == nil {
+		poller, release := h.acquire()
+		h.gen++
+		gen := h.gen
+		unsubscribe := poller.Subscribe(func(state *tesla.VehicleState, err error) { h.update(gen, state, err) })
+		h.release = func() {
+			unsubscribe()
+			release()
+		}
+		// A background poller has usually polled already.
+		if state, err := poller.Last(); state != nil || err != nil {
+			h.apply(state, err)
+		}
+	}
+
+	var backlog []streamEvent
+	if missed, ok := h.missedSince(lastEventID); ok {
+		backlog = missed
+	} else if h.state != nil {
+		data, _ := json.Marshal(h.state)
+		backlog = []streamEvent{{seq: h.seq, id: h.eventID(h.seq), name: "snapshot", data: data}}
+	}
+	if h.errEvent != nil {
+		backlog = append(backlog, *h.errEvent)
+	}
+
+	events := make(chan streamEvent, streamBuffer)
+	h.streams[events] = true
+	return events, backlog
+}
+
+// unsubscribe closes the stream events; the hub stops following the poller with the last one.
+func (h *stateHub) unsubscribe(events chan streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streams[events] {
+		h.drop(events)
+	}
+}
+
+// drop removes events from the streams, closing it. h.mu must be held.
+func (h *stateHub) drop(events chan streamEvent) {
+	delete(h.streams, events)
+	close(events)
+	if len(h.streams) == 0 {
+		h.release()
+		h.release = nil
+	}
+}
+
+// current returns the last state polled as JSON, or nil if there is none yet. It must not be
+// modified.
+func (h *stateHub) current() map[string]any {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+func (h *stateHub) eventID(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// missedSince returns the state events after the one with ID id, or false if they are not all
+// in the history. h.mu must be held.
+func (h *stateHub) missedSince(id string) ([]streamEvent, bool) {
+	epoch, s, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return nil, false
+	}
+	seq, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || seq > h.seq || len(h.history) > 0 && seq+1 < h.history[0].seq {
+		return nil, false
+	}
+	var missed []streamEvent
+	for _, e := range h.history {
+		if e.seq > seq {
+			missed = append(missed, e)
+		}
+	}
+	return missed, true
+}
+
+// update sends the events for the result of a poll made while the poller was followed for the
+// gen'th time.
+func (h *stateHub) update(gen uint64, state *tesla.VehicleState, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if gen != h.gen || h.release == nil {
+		return // No stream was open when the poll finished.
+	}
+	h.apply(state, err)
+}
+
+// apply sends the events for the result of a poll. h.mu must be held.
+func (h *stateHub) apply(state *tesla.VehicleState, err error) {
+	if err != nil {
+		e, message := clientError(err)
+		if e.code == h.errCode {
+			return
+		}
+		data, _ := json.Marshal(errorBody{Code: e.code, Message: message, Retryable: e.retryable})
+		h.errEvent, h.errCode = &streamEvent{name: "error", data: data}, e.code
+		h.broadcast(*h.errEvent)
+		return
+	}
+	h.errEvent, h.errCode = nil, ""
+
+	next, err := jsonObject(state)
+	if err != nil {
+		return
+	}
+	changes := next
+	if h.state != nil {
+		changes = stateChanges(h.state, next)
+	}
+	h.state = next
+	if changes == nil {
+		return
+	}
+	data, _ := json.Marshal(changes)
+	h.seq++
+	event := streamEvent{seq: h.seq, id: h.eventID(h.seq), name: "state", data: data}
+	if len(h.history) == streamHistory {
+		h.history = h.history[1:]
+	}
+	h.history = append(h.history, event)
+	h.broadcast(event)
+}
+
+// broadcast sends event to every stream, dropping those too far behind to take it; they resume
+// from their last event when they reconnect. h.mu must be held.
+func (h *stateHub) broadcast(event streamEvent) {
+	for events := range h.streams {
+		select {
+		case events <- event:
+		default:
+			h.drop(events)
+		}
+	}
+}
+
+// jsonObject returns v as it appears in JSON.
+func jsonObject(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]any
+	err = json.Unmarshal(data, &object)
+	return object, err
+}
+
+// stateChanges returns the fields of the vehicle state next that differ from prev, or nil if
+// none do. fetched_at changes with every poll, so it is only included alongside other changes.
+func stateChanges(prev, next map[string]any) map[string]any {
+	changes := objectChanges(prev, next)
+	delete(changes, "fetched_at")
+	if len(changes) == 0 {
+		return nil
+	}
+	changes["fetched_at"] = next["fetched_at"]
+	return changes
+}
+
+// objectChanges returns the fields of next that differ from prev. Nested objects are compared
+// field by field, so only their changed fields are included; a field next lacks is null.
+func objectChanges(prev, next map[string]any) map[string]any {
+	changes := map[string]any{}
+	for name, value := range next {
+		old, ok := prev[name]
+		oldObject, oldIsObject := old.(map[string]any)
+		object, isObject := value.(map[string]any)
+		switch {
+		case ok && oldIsObject && isObject:
+			if c := objectChanges(oldObject, object); len(c) > 0 {
+				changes[name] = c
+			}
+		case !ok || !reflect.DeepEqual(old, value):
+			changes[name] = value
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			changes[name] = nil
+		}
+	}
+	return changes
+}
+
+// stateHub returns the hub streaming the state of client, creating it on first use. It follows
+// the background poller of client if there is one, and otherwise polls every stream interval while
+// streams are open.
+func (s *Server) stateHub(client tesla.Client) *stateHub {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	hub, ok := s.streams[client]
+	if !ok {
+		hub = newStateHub(func() (*tesla.Poller, func()) {
+			if poller := s.pollers[client]; poller != nil {
+				return poller, func() {}
+			}
+			poller := tesla.NewPoller(client, tesla.FixedPollSchedule(s.streamInterval), s.timeouts.Stats)
+			// Not waited for: the last stream may close from within a poll's update.
+			return poller, func() { go poller.Close() }
+		})
+		s.streams[client] = hub
+	}
+	return hub
+}
+
+// CloseStreams ends every open stream, which would otherwise keep http.Server.Shutdown waiting.
+// Register it with http.Server.RegisterOnShutdown.
+func (s *Server) CloseStreams() {
+	s.closeStreams.Do(func() { close(s.streamsDone) })
+}
+
+// serveStream streams the state of the vehicle behind client as server-sent events; see
+// stateHub for the events. A comment is sent every heartbeat interval so proxies keep the
+// connection open.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	hub := s.stateHub(client)
+	events, backlog := hub.subscribe(r.Header.Get("Last-Event-ID"))
+	defer hub.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Stops nginx buffering the stream.
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	for _, event := range backlog {
+		event.writeTo(w)
+	}
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		s.logger.Printf("Cannot stream %s: %v", r.URL.Path, err)
+		return
+	}
+
+	heartbeat := time.NewTicker(s.streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return // Dropped for falling behind.
+			}
+			err = event.writeTo(w)
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// StreamHandler streams the default real vehicle's state.
+func (s *Server) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveStream(w, r, client)
+	}
+}
+
+// VehicleStreamHandler streams the state of the vehicle named by {vin}.
+func (s *Server) VehicleStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveStream(w, r, client)
+	}
+}
+
+// DevStreamHandler streams the mock vehicle's state.
+func (s *Server) DevStreamHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveStream(w, r, s.mockClient)
+}
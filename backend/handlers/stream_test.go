@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestStateChanges(t *testing.T) {
+	prev := map[string]any{
+		"vin":        "VIN1",
+		"fetched_at": "t1",
+		"charge":     map[string]any{"battery_level_percent": 75.0, "charging_state": "stopped"},
+		"security":   map[string]any{"locked": true},
+		"errors":     map[string]any{"media": "timeout"},
+	}
+	next := map[string]any{
+		"vin":        "VIN1",
+		"fetched_at": "t2",
+		"charge":     map[string]any{"battery_level_percent": 76.0, "charging_state": "stopped"},
+		"security":   nil,
+	}
+	want := map[string]any{
+		"fetched_at": "t2",
+		"charge":     map[string]any{"battery_level_percent": 76.0},
+		"security":   nil,
+		"errors":     nil,
+	}
+	if got := stateChanges(prev, next); !reflect.DeepEqual(got, want) {
+		t.Errorf("stateChanges = %v, want %v", got, want)
+	}
+	if got := stateChanges(next, map[string]any{"vin": "VIN1", "fetched_at": "t3", "charge": next["charge"], "security": nil}); got != nil {
+		t.Errorf("stateChanges with only fetched_at changed = %v, want nil", got)
+	}
+}
+
+// sseEvent is an event read from a stream; a heartbeat has only a comment.
+type sseEvent struct {
+	id, name, data, comment string
+}
+
+// openStream sends req, which must answer with an event stream, and returns its events. The
+// channel is closed when the stream ends.
+func openStream(t *testing.T, req *http.Request) <-chan sseEvent {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req.WithContext(t.Context()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		t.Fatalf("stream answered %v with Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		var event sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if event != (sseEvent{}) {
+					events <- event
+				}
+				event = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				event.comment = strings.TrimSpace(line[1:])
+			default:
+				field, value, _ := strings.Cut(line, ": ")
+				switch field {
+				case "id":
+					event.id = value
+				case "event":
+					event.name = value
+				case "data":
+					event.data = value
+				}
+			}
+		}
+	}()
+	return events
+}
+
+// streamRequest returns a request for the stream at url resuming after lastEventID.
+func streamRequest(url, lastEventID string) *http.Request {
+	req := httptest.NewRequest("GET", url, nil)
+	req.RequestURI = ""
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	return req
+}
+
+// nextEvent returns the next event named name, skipping others.
+func nextEvent(t *testing.T, events <-chan sseEvent, name string) sseEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("stream ended waiting for a %s event", name)
+			}
+			if event.name == name {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", name)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(Config{
+		StreamInterval:  10 * time.Millisecond,
+		StreamHeartbeat: 10 * time.Millisecond,
+	}).Handler())
+	t.Cleanup(srv.Close)
+
+	events := openStream(t, streamRequest(srv.URL+"/api/dev/stream", ""))
+	first := nextEvent(t, events, "state")
+	var state tesla.VehicleState
+	if err := json.Unmarshal([]byte(first.data), &state); err != nil || state.VIN != tesla.MockVIN || state.Security == nil {
+		t.Fatalf("first state event %q is not the whole state (%v)", first.data, err)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/dev/unlock", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var unlocked sseEvent
+	for unlocked.id == "" {
+		event := nextEvent(t, events, "state")
+		var changes map[string]any
+		if err := json.Unmarshal([]byte(event.data), &changes); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := changes["vin"]; ok {
+			t.Errorf("state event %q repeats unchanged fields", event.data)
+		}
+		if security, ok := changes["security"].(map[string]any); ok && security["locked"] == false {
+			unlocked = event
+		}
+	}
+
+	// A dashboard resuming after the first event gets what it missed, not a snapshot.
+	resumed := openStream(t, streamRequest(srv.URL+"/api/dev/stream", first.id))
+	event := <-resumed
+	for event.name == "state" && event.id != unlocked.id {
+		event = <-resumed
+	}
+	if event.id != unlocked.id {
+		t.Errorf("stream resumed after %s sent %+v, want the state events since, including %s", first.id, event, unlocked.id)
+	}
+
+	// One it cannot resume gets a snapshot.
+	fresh := openStream(t, streamRequest(srv.URL+"/api/dev/stream", "unknown-1"))
+	if event := <-fresh; event.name != "snapshot" || !strings.Contains(event.data, `"locked":false`) {
+		t.Errorf("stream with an unknown Last-Event-ID started with %+v, want a snapshot", event)
+	}
+
+	for event := range events {
+		if event.comment == "heartbeat" {
+			return
+		}
+	}
+	t.Error("stream ended without a heartbeat")
+}
+
+// countingClient counts the vehicle data requests made of a Client.
+type countingClient struct {
+	tesla.Client
+	polls atomic.Int32
+}
+
+func (c *countingClient) GetVehicleStats(ctx context.Context, categories ...tesla.StateCategory) (*tesla.VehicleState, error) {
+	c.polls.Add(1)
+	return c.Client.GetVehicleStats(ctx, categories...)
+}
+
+func TestStream_SharedPoller(t *testing.T) {
+	t.Setenv("TESLA_API_KEY", "secret")
+	client := &countingClient{Client: tesla.NewMockClient()}
+	s := newTestServer(Config{Client: client, StreamInterval: time.Hour})
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/api/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("stream without an API key answered %v, want 401", resp.StatusCode)
+	}
+
+	var streams []<-chan sseEvent
+	for _, want := range []string{"state", "snapshot", "snapshot"} {
+		req := streamRequest(srv.URL+"/api/v1/stream", "")
+		req.Header.Set("X-API-KEY", "secret")
+		events := openStream(t, req)
+		nextEvent(t, events, want)
+		streams = append(streams, events)
+	}
+	if n := client.polls.Load(); n != 1 {
+		t.Errorf("three dashboards polled the vehicle %d times, want once", n)
+	}
+
+	// Shutting down ends the streams.
+	s.CloseStreams()
+	for _, events := range streams {
+		for range events {
+		}
+	}
+}
+
+func TestStream_Errors(t *testing.T) {
+	mock := tesla.NewMockClient()
+	mock.Sleep()
+	srv := httptest.NewServer(newTestServer(Config{DevClient: mock, StreamInterval: 10 * time.Millisecond}).Handler())
+	t.Cleanup(srv.Close)
+
+	events := openStream(t, streamRequest(srv.URL+"/api/v1/dev/stream", ""))
+	event := nextEvent(t, events, "error")
+	var body errorBody
+	if err := json.Unmarshal([]byte(event.data), &body); err != nil || body.Code != "vehicle_asleep" || !body.Retryable {
+		t.Errorf("error event %q, want a retryable vehicle_asleep error", event.data)
+	}
+
+	// The error is sent once, and the state follows when the vehicle wakes.
+	time.Sleep(50 * time.Millisecond)
+	mock.Wake(context.Background())
+	for event := range events {
+		if event.name == "error" {
+			t.Fatalf("error sent again: %+v", event)
+		}
+		if event.name == "state" {
+			return
+		}
+	}
+	t.Error("stream ended without a state event")
+}
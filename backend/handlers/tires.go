@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// serveTires writes the TPMS report, with alerts, for the vehicle behind client.
+func (s *Server) serveTires(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Stats)
+	defer cancel()
+	state, err := client.GetVehicleStats(ctx, tesla.CategoryTirePressure)
+	if err != nil {
+		s.writeClientError(w, r, err)
+		return
+	}
+	report, err := tesla.NewTireReport(state, s.tireThresholds)
+	if err != nil {
+		s.writeClientError(w, r, err)
+		return
+	}
+	WriteJsonResponse(w, http.StatusOK, report)
+}
+
+// GetTiresHandler handles requests for the default real vehicle's tire pressures.
+func (s *Server) GetTiresHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveTires(w, r, client)
+	}
+}
+
+// VehicleTiresHandler handles requests for the tire pressures of the vehicle named by {vin}.
+func (s *Server) VehicleTiresHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveTires(w, r, client)
+	}
+}
+
+// DevGetTiresHandler handles requests for the mock vehicle's tire pressures.
+func (s *Server) DevGetTiresHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveTires(w, r, s.mockClient)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestDevGetTiresHandler(t *testing.T) {
+	mock := tesla.NewMockClient()
+	h := newTestServer(Config{DevClient: mock}).Handler()
+
+	get := func() tesla.TireReport {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/api/dev/tires", nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v (body %s)", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var report tesla.TireReport
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("handler returned invalid JSON: %v", err)
+		}
+		return report
+	}
+
+	if report := get(); len(report.Tires) != 4 || len(report.Alerts) != 0 {
+		t.Errorf("healthy tires: got %+v", report)
+	}
+
+	leak := 0.5
+	if err := mock.Apply(tesla.SimAction{Action: "tire_leak", Tire: tesla.TireFrontLeft, BarPerHour: &leak}); err != nil {
+		t.Fatal(err)
+	}
+	mock.Advance(time.Hour)
+	report := get()
+	if len(report.Alerts) != 1 || report.Alerts[0].Position != tesla.TireFrontLeft || report.Alerts[0].Kind != "low" {
+		t.Errorf("leaking tire: alerts %+v, want one low alert for the front left tire", report.Alerts)
+	}
+}
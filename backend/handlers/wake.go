@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+// serveWake wakes the vehicle behind client and waits, up to the Wake timeout, until it is online.
+func (s *Server) serveWake(w http.ResponseWriter, r *http.Request, client tesla.Client) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Wake)
+	defer cancel()
+	if err := tesla.WaitUntilOnline(ctx, client, s.wakeBackoff); err != nil {
+		s.writeClientError(w, r, err)
+		return
+	}
+	s.nudgePoller(client)
+	WriteJsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// WakeHandler wakes the default real vehicle and waits until it is online.
+func (s *Server) WakeHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := s.defaultVehicle(w, r); ok {
+		s.serveWake(w, r, client)
+	}
+}
+
+// VehicleWakeHandler wakes the vehicle named by {vin} and waits until it is online.
+func (s *Server) VehicleWakeHandler(w http.ResponseWriter, r *http.Request) {
+	if client, ok := vehicleClient(w, r, s.registry); ok {
+		s.serveWake(w, r, client)
+	}
+}
+
+// DevWakeHandler wakes the mock vehicle.
+func (s *Server) DevWakeHandler(w http.ResponseWriter, r *http.Request) {
+	s.serveWake(w, r, s.mockClient)
+}
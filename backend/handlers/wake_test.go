@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla"
+)
+
+func TestCommandOnSleepingVehicle(t *testing.T) {
+	mock := tesla.NewMockClient()
+	h := newTestServer(Config{
+		DevClient:   mock,
+		WakeBackoff: tesla.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2},
+	}).Handler()
+
+	mock.Sleep()
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"fail fast", "/api/dev/climate/start", http.StatusServiceUnavailable},
+		{"bad wake option", "/api/dev/climate/start?wake=maybe", http.StatusBadRequest},
+		{"auto wake", "/api/dev/climate/start?wake=true", http.StatusOK},
+		{"already awake", "/api/dev/climate/start", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v (body %s)", tt.name, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+
+	mock.Sleep()
+	req, _ := http.NewRequest("POST", "/api/dev/wake", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("wake handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	req, _ = http.NewRequest("POST", "/api/dev/lock", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("lock after wake returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
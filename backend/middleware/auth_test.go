@@ -27,7 +27,7 @@ func TestAPIKeyAuthMiddleware(t *testing.T) {
 	if rr1.Code != http.StatusInternalServerError {
 		t.Errorf("Case 1: Expected status %d, got %d", http.StatusInternalServerError, rr1.Code)
 	}
-    expectedError1 := `{"error":"API key not configured on server"}`
+    expectedError1 := `{"error":{"code":"internal","message":"API key not configured on server","retryable":false}}`
 	if strings.TrimSpace(rr1.Body.String()) != expectedError1 {
 		t.Errorf("Case 1: Expected body %s, got %s", expectedError1, rr1.Body.String())
 	}
@@ -44,7 +44,7 @@ func TestAPIKeyAuthMiddleware(t *testing.T) {
 	if rr2.Code != http.StatusUnauthorized {
 		t.Errorf("Case 2: Expected status %d, got %d", http.StatusUnauthorized, rr2.Code)
 	}
-    expectedError2 := `{"error":"API key missing in X-API-KEY header"}`
+    expectedError2 := `{"error":{"code":"unauthorized","message":"API key missing in X-API-KEY header","retryable":false}}`
 	if strings.TrimSpace(rr2.Body.String()) != expectedError2 {
 		t.Errorf("Case 2: Expected body %s, got %s", expectedError2, rr2.Body.String())
 	}
@@ -57,7 +57,7 @@ func TestAPIKeyAuthMiddleware(t *testing.T) {
 	if rr3.Code != http.StatusUnauthorized {
 		t.Errorf("Case 3: Expected status %d, got %d", http.StatusUnauthorized, rr3.Code)
 	}
-    expectedError3 := `{"error":"Invalid API key"}`
+    expectedError3 := `{"error":{"code":"unauthorized","message":"Invalid API key","retryable":false}}`
 	if strings.TrimSpace(rr3.Body.String()) != expectedError3 {
 		t.Errorf("Case 3: Expected body %s, got %s", expectedError3, rr3.Body.String())
 	}
@@ -75,3 +75,71 @@ func TestAPIKeyAuthMiddleware(t *testing.T) {
 		t.Errorf("Case 4: Expected body 'OK', got '%s'", rr4.Body.String())
 	}
 }
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	t.Setenv("TESLA_API_KEY", "user-key")
+	t.Setenv("TESLA_ADMIN_API_KEY", "")
+
+	serve := func(h func(http.HandlerFunc) http.HandlerFunc, key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if key != "" {
+			req.Header.Set("X-API-KEY", key)
+		}
+		rr := httptest.NewRecorder()
+		h(dummyHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(AdminAuthMiddleware, "user-key"); rr.Code != http.StatusInternalServerError {
+		t.Errorf("admin key unset: expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	t.Setenv("TESLA_ADMIN_API_KEY", "admin-key")
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{key: "", want: http.StatusUnauthorized},
+		{key: "wrong-key", want: http.StatusUnauthorized},
+		{key: "user-key", want: http.StatusForbidden},
+		{key: "admin-key", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rr := serve(AdminAuthMiddleware, tt.key); rr.Code != tt.want {
+			t.Errorf("AdminAuthMiddleware with key %q: expected status %d, got %d", tt.key, tt.want, rr.Code)
+		}
+	}
+
+	// The admin key also opens the regular routes.
+	if rr := serve(APIKeyAuthMiddleware, "admin-key"); rr.Code != http.StatusOK {
+		t.Errorf("APIKeyAuthMiddleware with the admin key: expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestAPIKeyAuthMiddleware_WebSocket(t *testing.T) {
+	t.Setenv("TESLA_API_KEY", "user-key")
+
+	tests := []struct {
+		name      string
+		upgrade   string
+		protocols string
+		want      int
+	}{
+		{"key as subprotocol", "websocket", "tesla-dashboard, api-key.user-key", http.StatusOK},
+		{"wrong key", "websocket", "api-key.wrong-key", http.StatusUnauthorized},
+		{"no key", "websocket", "tesla-dashboard", http.StatusUnauthorized},
+		{"not a handshake", "", "api-key.user-key", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if tt.upgrade != "" {
+			req.Header.Set("Upgrade", tt.upgrade)
+		}
+		req.Header.Set("Sec-WebSocket-Protocol", tt.protocols)
+		rr := httptest.NewRecorder()
+		APIKeyAuthMiddleware(dummyHandler).ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+}
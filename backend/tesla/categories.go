@@ -0,0 +1,109 @@
+package tesla
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StateCategory names one section of VehicleState. Callers pass categories to
+// Client.GetVehicleStats to fetch only what they need; passing none fetches every category.
+type StateCategory string
+
+const (
+	CategoryCharge         StateCategory = "charge"
+	CategoryClimate        StateCategory = "climate"
+	CategoryDrive          StateCategory = "drive"
+	CategoryClosures       StateCategory = "closures"
+	CategoryLocation       StateCategory = "location"
+	CategorySecurity       StateCategory = "security"
+	CategoryTirePressure   StateCategory = "tire_pressure"
+	CategoryMedia          StateCategory = "media"
+	CategorySoftwareUpdate StateCategory = "software_update"
+)
+
+// AllStateCategories lists every category in the order VehicleState declares them.
+var AllStateCategories = []StateCategory{
+	CategoryCharge,
+	CategoryClimate,
+	CategoryDrive,
+	CategoryClosures,
+	CategoryLocation,
+	CategorySecurity,
+	CategoryTirePressure,
+	CategoryMedia,
+	CategorySoftwareUpdate,
+}
+
+// Valid reports whether c is one of AllStateCategories.
+func (c StateCategory) Valid() bool {
+	return slices.Contains(AllStateCategories, c)
+}
+
+// ParseStateCategories parses a comma-separated list such as "charge,climate", as accepted by the
+// ?categories= query parameter. An empty string yields no categories, meaning all of them.
+func ParseStateCategories(s string) ([]StateCategory, error) {
+	var categories []StateCategory
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c := StateCategory(name)
+		if !c.Valid() {
+			return nil, fmt.Errorf("unknown state category %q", name)
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// requestedCategories returns categories without duplicates, or AllStateCategories if it is empty.
+func requestedCategories(categories []StateCategory) []StateCategory {
+	if len(categories) == 0 {
+		return AllStateCategories
+	}
+	var out []StateCategory
+	for _, c := range categories {
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// clear drops category c from s.
+func (s *VehicleState) clear(c StateCategory) {
+	switch c {
+	case CategoryCharge:
+		s.Charge = nil
+	case CategoryClimate:
+		s.Climate = nil
+	case CategoryDrive:
+		s.Drive = nil
+	case CategoryClosures:
+		s.Closures = nil
+	case CategoryLocation:
+		s.Location = nil
+	case CategorySecurity:
+		s.Security = nil
+	case CategoryTirePressure:
+		s.TirePressure = nil
+	case CategoryMedia:
+		s.Media = nil
+	case CategorySoftwareUpdate:
+		s.SoftwareUpdate = nil
+	}
+}
+
+// keepOnly drops every category of s that is not in categories. No categories keeps them all.
+func (s *VehicleState) keepOnly(categories []StateCategory) {
+	if len(categories) == 0 {
+		return
+	}
+	for _, c := range AllStateCategories {
+		if !slices.Contains(categories, c) {
+			s.clear(c)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package tesla
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits accepted by the charging commands.
+const (
+	MinChargeLimitPercent = 50
+	MaxChargeLimitPercent = 100
+	MinChargingAmps       = 1
+	MaxChargingAmps       = 48
+)
+
+// ParseTimeOfDay parses an "HH:MM" wall-clock time into the offset after midnight used by
+// Client.ScheduleCharging.
+func ParseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -1,9 +1,57 @@
 package tesla
 
+import (
+	"context"
+	"crypto/ecdh"
+	"time"
+)
+
 // Client defines the interface for interacting with the Tesla API (or a mock).
+// Every method takes a context so callers can cancel in-flight SDK work or bound it with a deadline.
 type Client interface {
-	GetVehicleStats() (map[string]interface{}, error)
-	LockVehicle() (bool, error)
-	UnlockVehicle() (bool, error)
-	GetCameraFeed() (string, error) // Returns a URL or data for the camera feed
+	// GetVehicleStats fetches the given categories, or all of them if none are given. A category
+	// that fails is reported in VehicleState.Errors; an error is returned only if all of them fail.
+	GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error)
+	LockVehicle(ctx context.Context) (bool, error)
+	UnlockVehicle(ctx context.Context) (bool, error)
+	GetCameraFeed(ctx context.Context) (string, error) // Returns a URL or data for the camera feed
+
+	// Wake asks a sleeping vehicle to come online without waiting for it; see WaitUntilOnline.
+	// IsOnline reports whether the vehicle is awake and reachable. Other methods fail with an
+	// error wrapping ErrVehicleAsleep while the vehicle sleeps.
+	Wake(ctx context.Context) (bool, error)
+	IsOnline(ctx context.Context) (bool, error)
+
+	// Climate control.
+	StartClimate(ctx context.Context) (bool, error)
+	StopClimate(ctx context.Context) (bool, error)
+	SetTemperatures(ctx context.Context, driverCelsius, passengerCelsius float64) (bool, error)
+	SetSeatHeater(ctx context.Context, seat Seat, level HeaterLevel) (bool, error)
+	SetSteeringWheelHeater(ctx context.Context, on bool) (bool, error)
+	SetMaxDefrost(ctx context.Context, on bool) (bool, error)
+	SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) (bool, error)
+
+	// Charging control.
+	StartCharging(ctx context.Context) (bool, error)
+	StopCharging(ctx context.Context) (bool, error)
+	SetChargeLimit(ctx context.Context, percent int) (bool, error)
+	SetChargingAmps(ctx context.Context, amps int) (bool, error)
+	OpenChargePort(ctx context.Context) (bool, error)
+	CloseChargePort(ctx context.Context) (bool, error)
+	UnlockChargeCable(ctx context.Context) (bool, error)
+	ScheduleCharging(ctx context.Context, enabled bool, startAfterMidnight time.Duration) (bool, error)
+
+	// Closures. ActuateFrunk can only open the frunk; ActuateTrunk opens or closes the trunk.
+	ActuateFrunk(ctx context.Context) (bool, error)
+	ActuateTrunk(ctx context.Context) (bool, error)
+	VentWindows(ctx context.Context) (bool, error)
+	CloseWindows(ctx context.Context) (bool, error)
+	SetSunroof(ctx context.Context, percentOpen int) (bool, error)
+	SetTonneau(ctx context.Context, action TonneauAction) (bool, error)
+
+	// Key management. Adding and removing keys needs the client's own key to be an owner key;
+	// RemoveKey fails with an error wrapping ErrKeyNotFound for a key that is not enrolled.
+	ListKeys(ctx context.Context) ([]VehicleKey, error)
+	AddKey(ctx context.Context, publicKey *ecdh.PublicKey, role KeyRole, formFactor KeyFormFactor) (bool, error)
+	RemoveKey(ctx context.Context, publicKey *ecdh.PublicKey) (bool, error)
 }
@@ -0,0 +1,99 @@
+package tesla
+
+import (
+	"fmt"
+
+	"github.com/teslamotors/vehicle-command/pkg/vehicle"
+)
+
+// Temperature limits accepted by the vehicle's HVAC system.
+const (
+	MinTemperatureCelsius = 15.0
+	MaxTemperatureCelsius = 28.0
+)
+
+// Seat identifies a heated seat.
+type Seat string
+
+const (
+	SeatFrontLeft  Seat = "front_left"
+	SeatFrontRight Seat = "front_right"
+	SeatRearLeft   Seat = "rear_left"
+	SeatRearCenter Seat = "rear_center"
+	SeatRearRight  Seat = "rear_right"
+)
+
+// ParseSeat validates a seat name received from an API caller.
+func ParseSeat(s string) (Seat, error) {
+	switch seat := Seat(s); seat {
+	case SeatFrontLeft, SeatFrontRight, SeatRearLeft, SeatRearCenter, SeatRearRight:
+		return seat, nil
+	}
+	return "", fmt.Errorf("unknown seat %q", s)
+}
+
+func (s Seat) sdkPosition() vehicle.SeatPosition {
+	switch s {
+	case SeatFrontLeft:
+		return vehicle.SeatFrontLeft
+	case SeatFrontRight:
+		return vehicle.SeatFrontRight
+	case SeatRearLeft:
+		return vehicle.SeatSecondRowLeft
+	case SeatRearCenter:
+		return vehicle.SeatSecondRowCenter
+	case SeatRearRight:
+		return vehicle.SeatSecondRowRight
+	}
+	return vehicle.SeatUnknown
+}
+
+// HeaterLevel is a seat heater setting from HeaterOff to HeaterHigh.
+type HeaterLevel int
+
+const (
+	HeaterOff HeaterLevel = iota
+	HeaterLow
+	HeaterMedium
+	HeaterHigh
+)
+
+// Valid reports whether l is one of the defined heater levels.
+func (l HeaterLevel) Valid() bool {
+	return l >= HeaterOff && l <= HeaterHigh
+}
+
+func (l HeaterLevel) sdkLevel() vehicle.Level {
+	return vehicle.Level(l) // vehicle.LevelOff..LevelHigh use the same numbering.
+}
+
+// ClimateKeeperMode selects what the HVAC does after the driver leaves the car.
+type ClimateKeeperMode string
+
+const (
+	ClimateKeeperOff  ClimateKeeperMode = "off"
+	ClimateKeeperKeep ClimateKeeperMode = "keep"
+	ClimateKeeperDog  ClimateKeeperMode = "dog"
+	ClimateKeeperCamp ClimateKeeperMode = "camp"
+)
+
+// ParseClimateKeeperMode validates a climate keeper mode received from an API caller.
+func ParseClimateKeeperMode(s string) (ClimateKeeperMode, error) {
+	switch mode := ClimateKeeperMode(s); mode {
+	case ClimateKeeperOff, ClimateKeeperKeep, ClimateKeeperDog, ClimateKeeperCamp:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown climate keeper mode %q", s)
+}
+
+func (m ClimateKeeperMode) sdkMode() vehicle.ClimateKeeperMode {
+	switch m {
+	case ClimateKeeperKeep:
+		return vehicle.ClimateKeeperModeOn
+	case ClimateKeeperDog:
+		return vehicle.ClimateKeeperModeDog
+	case ClimateKeeperCamp:
+		return vehicle.ClimateKeeperModeCamp
+	}
+	return vehicle.ClimateKeeperModeOff
+}
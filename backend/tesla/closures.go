@@ -0,0 +1,21 @@
+package tesla
+
+import "fmt"
+
+// TonneauAction moves a Cybertruck's powered tonneau cover.
+type TonneauAction string
+
+const (
+	TonneauOpen  TonneauAction = "open"
+	TonneauClose TonneauAction = "close"
+	TonneauStop  TonneauAction = "stop"
+)
+
+// ParseTonneauAction validates a tonneau action received from an API caller.
+func ParseTonneauAction(s string) (TonneauAction, error) {
+	switch action := TonneauAction(s); action {
+	case TonneauOpen, TonneauClose, TonneauStop:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown tonneau action %q", s)
+}
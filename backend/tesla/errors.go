@@ -0,0 +1,31 @@
+package tesla
+
+import "errors"
+
+// The errors a Client reports for conditions callers handle differently, e.g. by waking the
+// vehicle or retrying later. Implementations wrap them with the failed operation, so test for
+// them with errors.Is.
+var (
+	// ErrVehicleAsleep is returned when the vehicle must be woken before it can answer.
+	ErrVehicleAsleep = errors.New("vehicle is asleep")
+
+	// ErrVehicleOffline is returned when the vehicle cannot be reached at all, e.g. it has no
+	// connectivity. Unlike ErrVehicleAsleep, waking it will not help.
+	ErrVehicleOffline = errors.New("vehicle offline")
+
+	// ErrUnauthorized is returned when the vehicle or the Fleet API rejects our credentials, e.g. an
+	// expired OAuth token or a key that was never paired with the vehicle.
+	ErrUnauthorized = errors.New("not authorized")
+
+	// ErrNotSupported is returned for features the vehicle or client does not have, e.g. tonneau
+	// control on a car without a tonneau cover, or a live camera feed.
+	ErrNotSupported = errors.New("not supported by this vehicle")
+
+	// ErrRateLimited is returned when the Fleet API throttles our requests. Retrying after a
+	// pause will succeed.
+	ErrRateLimited = errors.New("rate limited by the Tesla API")
+
+	// ErrTimeout is returned when the vehicle (or the SDK talking to it) does not answer before the
+	// operation's deadline expires.
+	ErrTimeout = errors.New("timed out waiting for vehicle")
+)
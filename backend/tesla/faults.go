@@ -0,0 +1,394 @@
+package tesla
+
+import (
+	"context"
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is the generic failure produced by FaultKindError.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultKind names a failure FaultyClient can inject.
+type FaultKind string
+
+const (
+	FaultKindNone    FaultKind = "none"    // The call goes through; used to space out a Script.
+	FaultKindError   FaultKind = "error"   // The call fails with ErrInjectedFault.
+	FaultKindAsleep  FaultKind = "asleep"  // The call fails with ErrVehicleAsleep; IsOnline reports false.
+	FaultKindOffline FaultKind = "offline" // The call fails with ErrVehicleOffline.
+	FaultKindTimeout FaultKind = "timeout" // The call hangs until its context expires.
+	// FaultKindRateLimited fails the call with ErrRateLimited, as the Fleet API does when throttling.
+	FaultKindRateLimited FaultKind = "rate_limited"
+)
+
+// FaultConfig describes the faults a FaultyClient injects. The zero value injects nothing.
+type FaultConfig struct {
+	// Latency delays every call, plus a random extra of up to Jitter.
+	Latency Duration `json:"latency,omitempty"`
+	Jitter  Duration `json:"jitter,omitempty"`
+
+	// ErrorRate is the probability, from 0 to 1, that a call fails with Error (default "error").
+	ErrorRate float64   `json:"error_rate,omitempty"`
+	Error     FaultKind `json:"error,omitempty"`
+
+	// Script lists the outcome of the next calls, one entry per call, ahead of ErrorRate.
+	Script []FaultKind `json:"script,omitempty"`
+
+	// PartialData lists state categories, e.g. "climate", that GetVehicleStats leaves out and
+	// reports in VehicleState.Errors, as the Fleet API does when a module does not answer.
+	PartialData []StateCategory `json:"partial_data,omitempty"`
+
+	// Operations restricts the faults to these Client methods, e.g. "GetVehicleStats". Empty means
+	// every method.
+	Operations []string `json:"operations,omitempty"`
+}
+
+var faultKinds = []FaultKind{FaultKindNone, FaultKindError, FaultKindAsleep, FaultKindOffline, FaultKindTimeout, FaultKindRateLimited}
+
+// Validate reports the first invalid setting in c.
+func (c FaultConfig) Validate() error {
+	if c.Latency < 0 || c.Jitter < 0 {
+		return errors.New("latency and jitter must not be negative")
+	}
+	if c.ErrorRate < 0 || c.ErrorRate > 1 {
+		return fmt.Errorf("error_rate must be between 0 and 1, got %g", c.ErrorRate)
+	}
+	if c.Error != "" && !slices.Contains(faultKinds, c.Error) {
+		return fmt.Errorf("unknown fault %q", c.Error)
+	}
+	for _, kind := range c.Script {
+		if !slices.Contains(faultKinds, kind) {
+			return fmt.Errorf("unknown fault %q in script", kind)
+		}
+	}
+	for _, category := range c.PartialData {
+		if !category.Valid() {
+			return fmt.Errorf("unknown state category %q", category)
+		}
+	}
+	client := reflect.TypeFor[Client]()
+	for _, op := range c.Operations {
+		if _, ok := client.MethodByName(op); !ok {
+			return fmt.Errorf("unknown operation %q", op)
+		}
+	}
+	return nil
+}
+
+// FaultConfigFromEnvironment reads a FaultConfig, as JSON, from TESLA_MOCK_FAULTS. An invalid
+// setting is logged and ignored.
+func FaultConfigFromEnvironment() FaultConfig {
+	value := os.Getenv("TESLA_MOCK_FAULTS")
+	if value == "" {
+		return FaultConfig{}
+	}
+	var c FaultConfig
+	err := json.Unmarshal([]byte(value), &c)
+	if err == nil {
+		err = c.Validate()
+	}
+	if err != nil {
+		log.Printf("Ignoring invalid TESLA_MOCK_FAULTS=%q: %v", value, err)
+		return FaultConfig{}
+	}
+	return c
+}
+
+// FaultyClient wraps a Client and makes it misbehave according to a FaultConfig, so error
+// handling can be exercised against the mock. The configuration can be changed at any time.
+type FaultyClient struct {
+	client Client
+
+	mu     sync.Mutex
+	config FaultConfig
+	script []FaultKind // The part of config.Script not yet consumed.
+}
+
+// NewFaultyClient wraps client with the faults in config, which must be valid.
+func NewFaultyClient(client Client, config FaultConfig) *FaultyClient {
+	fc := &FaultyClient{client: client}
+	if err := fc.SetFaults(config); err != nil {
+		panic(err)
+	}
+	return fc
+}
+
+// Unwrap returns the wrapped client.
+func (fc *FaultyClient) Unwrap() Client { return fc.client }
+
+// Faults returns the current configuration, with Script reduced to the calls not made yet.
+func (fc *FaultyClient) Faults() FaultConfig {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	c := fc.config
+	c.Script = slices.Clone(fc.script)
+	return c
+}
+
+// SetFaults replaces the configuration; the zero FaultConfig turns fault injection off.
+func (fc *FaultyClient) SetFaults(config FaultConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.config = config
+	fc.script = slices.Clone(config.Script)
+	return nil
+}
+
+// plan decides the delay and fault for one call to op.
+func (fc *FaultyClient) plan(op string) (time.Duration, FaultKind, []StateCategory) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	c := fc.config
+	if len(c.Operations) > 0 && !slices.Contains(c.Operations, op) {
+		return 0, FaultKindNone, nil
+	}
+	delay := time.Duration(c.Latency)
+	if c.Jitter > 0 {
+		delay += rand.N(time.Duration(c.Jitter))
+	}
+	fault := FaultKindNone
+	switch {
+	case len(fc.script) > 0:
+		fault, fc.script = fc.script[0], fc.script[1:]
+	case c.ErrorRate > 0 && rand.Float64() < c.ErrorRate:
+		fault = c.Error
+		if fault == "" {
+			fault = FaultKindError
+		}
+	}
+	return delay, fault, c.PartialData
+}
+
+// inject delays the call to op and returns the fault to fail it with, if any.
+func (fc *FaultyClient) inject(ctx context.Context, op string) (FaultKind, []StateCategory, error) {
+	delay, fault, partial := fc.plan(op)
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return fault, nil, contextError(ctx, op, ctx.Err())
+		}
+	}
+	switch fault {
+	case FaultKindError:
+		return fault, nil, fmt.Errorf("%s: %w", op, ErrInjectedFault)
+	case FaultKindAsleep:
+		return fault, nil, fmt.Errorf("%s: %w", op, ErrVehicleAsleep)
+	case FaultKindOffline:
+		return fault, nil, fmt.Errorf("%s: %w", op, ErrVehicleOffline)
+	case FaultKindRateLimited:
+		return fault, nil, fmt.Errorf("%s: %w", op, ErrRateLimited)
+	case FaultKindTimeout:
+		if _, ok := ctx.Deadline(); !ok {
+			return fault, nil, fmt.Errorf("%s: %w", op, ErrTimeout) // Don't hang forever.
+		}
+		<-ctx.Done()
+		return fault, nil, contextError(ctx, op, ctx.Err())
+	}
+	return fault, partial, nil
+}
+
+// faulty runs f unless a fault is injected for op.
+func faulty[T any](ctx context.Context, fc *FaultyClient, op string, f func() (T, error)) (T, error) {
+	if _, _, err := fc.inject(ctx, op); err != nil {
+		var zero T
+		return zero, err
+	}
+	return f()
+}
+
+// GetVehicleStats implements Client, leaving out the categories listed in PartialData.
+func (fc *FaultyClient) GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	_, partial, err := fc.inject(ctx, "GetVehicleStats")
+	if err != nil {
+		return nil, err
+	}
+	state, err := fc.client.GetVehicleStats(ctx, categories...)
+	if err != nil || len(partial) == 0 {
+		return state, err
+	}
+	state = state.Clone()
+	for _, category := range requestedCategories(categories) {
+		if !slices.Contains(partial, category) {
+			continue
+		}
+		state.clear(category)
+		if state.Errors == nil {
+			state.Errors = make(map[StateCategory]string)
+		}
+		state.Errors[category] = fmt.Sprintf("fetching %s: %v", category, ErrInjectedFault)
+	}
+	return state, nil
+}
+
+// IsOnline implements Client. An injected "asleep" fault reports the vehicle offline rather than
+// failing, as a real sleeping vehicle does.
+func (fc *FaultyClient) IsOnline(ctx context.Context) (bool, error) {
+	fault, _, err := fc.inject(ctx, "IsOnline")
+	if fault == FaultKindAsleep {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return fc.client.IsOnline(ctx)
+}
+
+// LockVehicle implements Client.
+func (fc *FaultyClient) LockVehicle(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "LockVehicle", func() (bool, error) { return fc.client.LockVehicle(ctx) })
+}
+
+// UnlockVehicle implements Client.
+func (fc *FaultyClient) UnlockVehicle(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "UnlockVehicle", func() (bool, error) { return fc.client.UnlockVehicle(ctx) })
+}
+
+// GetCameraFeed implements Client.
+func (fc *FaultyClient) GetCameraFeed(ctx context.Context) (string, error) {
+	return faulty(ctx, fc, "GetCameraFeed", func() (string, error) { return fc.client.GetCameraFeed(ctx) })
+}
+
+// Wake implements Client.
+func (fc *FaultyClient) Wake(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "Wake", func() (bool, error) { return fc.client.Wake(ctx) })
+}
+
+// StartClimate implements Client.
+func (fc *FaultyClient) StartClimate(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "StartClimate", func() (bool, error) { return fc.client.StartClimate(ctx) })
+}
+
+// StopClimate implements Client.
+func (fc *FaultyClient) StopClimate(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "StopClimate", func() (bool, error) { return fc.client.StopClimate(ctx) })
+}
+
+// SetTemperatures implements Client.
+func (fc *FaultyClient) SetTemperatures(ctx context.Context, driverCelsius, passengerCelsius float64) (bool, error) {
+	return faulty(ctx, fc, "SetTemperatures", func() (bool, error) {
+		return fc.client.SetTemperatures(ctx, driverCelsius, passengerCelsius)
+	})
+}
+
+// SetSeatHeater implements Client.
+func (fc *FaultyClient) SetSeatHeater(ctx context.Context, seat Seat, level HeaterLevel) (bool, error) {
+	return faulty(ctx, fc, "SetSeatHeater", func() (bool, error) { return fc.client.SetSeatHeater(ctx, seat, level) })
+}
+
+// SetSteeringWheelHeater implements Client.
+func (fc *FaultyClient) SetSteeringWheelHeater(ctx context.Context, on bool) (bool, error) {
+	return faulty(ctx, fc, "SetSteeringWheelHeater", func() (bool, error) { return fc.client.SetSteeringWheelHeater(ctx, on) })
+}
+
+// SetMaxDefrost implements Client.
+func (fc *FaultyClient) SetMaxDefrost(ctx context.Context, on bool) (bool, error) {
+	return faulty(ctx, fc, "SetMaxDefrost", func() (bool, error) { return fc.client.SetMaxDefrost(ctx, on) })
+}
+
+// SetClimateKeeperMode implements Client.
+func (fc *FaultyClient) SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) (bool, error) {
+	return faulty(ctx, fc, "SetClimateKeeperMode", func() (bool, error) { return fc.client.SetClimateKeeperMode(ctx, mode) })
+}
+
+// StartCharging implements Client.
+func (fc *FaultyClient) StartCharging(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "StartCharging", func() (bool, error) { return fc.client.StartCharging(ctx) })
+}
+
+// StopCharging implements Client.
+func (fc *FaultyClient) StopCharging(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "StopCharging", func() (bool, error) { return fc.client.StopCharging(ctx) })
+}
+
+// SetChargeLimit implements Client.
+func (fc *FaultyClient) SetChargeLimit(ctx context.Context, percent int) (bool, error) {
+	return faulty(ctx, fc, "SetChargeLimit", func() (bool, error) { return fc.client.SetChargeLimit(ctx, percent) })
+}
+
+// SetChargingAmps implements Client.
+func (fc *FaultyClient) SetChargingAmps(ctx context.Context, amps int) (bool, error) {
+	return faulty(ctx, fc, "SetChargingAmps", func() (bool, error) { return fc.client.SetChargingAmps(ctx, amps) })
+}
+
+// OpenChargePort implements Client.
+func (fc *FaultyClient) OpenChargePort(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "OpenChargePort", func() (bool, error) { return fc.client.OpenChargePort(ctx) })
+}
+
+// CloseChargePort implements Client.
+func (fc *FaultyClient) CloseChargePort(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "CloseChargePort", func() (bool, error) { return fc.client.CloseChargePort(ctx) })
+}
+
+// UnlockChargeCable implements Client.
+func (fc *FaultyClient) UnlockChargeCable(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "UnlockChargeCable", func() (bool, error) { return fc.client.UnlockChargeCable(ctx) })
+}
+
+// ScheduleCharging implements Client.
+func (fc *FaultyClient) ScheduleCharging(ctx context.Context, enabled bool, startAfterMidnight time.Duration) (bool, error) {
+	return faulty(ctx, fc, "ScheduleCharging", func() (bool, error) {
+		return fc.client.ScheduleCharging(ctx, enabled, startAfterMidnight)
+	})
+}
+
+// ActuateFrunk implements Client.
+func (fc *FaultyClient) ActuateFrunk(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "ActuateFrunk", func() (bool, error) { return fc.client.ActuateFrunk(ctx) })
+}
+
+// ActuateTrunk implements Client.
+func (fc *FaultyClient) ActuateTrunk(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "ActuateTrunk", func() (bool, error) { return fc.client.ActuateTrunk(ctx) })
+}
+
+// VentWindows implements Client.
+func (fc *FaultyClient) VentWindows(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "VentWindows", func() (bool, error) { return fc.client.VentWindows(ctx) })
+}
+
+// CloseWindows implements Client.
+func (fc *FaultyClient) CloseWindows(ctx context.Context) (bool, error) {
+	return faulty(ctx, fc, "CloseWindows", func() (bool, error) { return fc.client.CloseWindows(ctx) })
+}
+
+// SetSunroof implements Client.
+func (fc *FaultyClient) SetSunroof(ctx context.Context, percentOpen int) (bool, error) {
+	return faulty(ctx, fc, "SetSunroof", func() (bool, error) { return fc.client.SetSunroof(ctx, percentOpen) })
+}
+
+// SetTonneau implements Client.
+func (fc *FaultyClient) SetTonneau(ctx context.Context, action TonneauAction) (bool, error) {
+	return faulty(ctx, fc, "SetTonneau", func() (bool, error) { return fc.client.SetTonneau(ctx, action) })
+}
+
+// ListKeys implements Client.
+func (fc *FaultyClient) ListKeys(ctx context.Context) ([]VehicleKey, error) {
+	return faulty(ctx, fc, "ListKeys", func() ([]VehicleKey, error) { return fc.client.ListKeys(ctx) })
+}
+
+// AddKey implements Client.
+func (fc *FaultyClient) AddKey(ctx context.Context, publicKey *ecdh.PublicKey, role KeyRole, formFactor KeyFormFactor) (bool, error) {
+	return faulty(ctx, fc, "AddKey", func() (bool, error) { return fc.client.AddKey(ctx, publicKey, role, formFactor) })
+}
+
+// RemoveKey implements Client.
+func (fc *FaultyClient) RemoveKey(ctx context.Context, publicKey *ecdh.PublicKey) (bool, error) {
+	return faulty(ctx, fc, "RemoveKey", func() (bool, error) { return fc.client.RemoveKey(ctx, publicKey) })
+}
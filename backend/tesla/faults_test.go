@@ -0,0 +1,110 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFaultyClient_Script(t *testing.T) {
+	fc := NewFaultyClient(NewMockClient(), FaultConfig{
+		Script: []FaultKind{FaultKindError, FaultKindAsleep, FaultKindOffline, FaultKindRateLimited, FaultKindNone},
+	})
+	ctx := context.Background()
+
+	for _, want := range []error{ErrInjectedFault, ErrVehicleAsleep, ErrVehicleOffline, ErrRateLimited, nil, nil} {
+		if _, err := fc.LockVehicle(ctx); !errors.Is(err, want) {
+			t.Errorf("LockVehicle() error = %v, want %v", err, want)
+		}
+	}
+	if got := fc.Faults().Script; len(got) != 0 {
+		t.Errorf("script not consumed: %v", got)
+	}
+}
+
+func TestFaultyClient_AsleepIsOnline(t *testing.T) {
+	fc := NewFaultyClient(NewMockClient(), FaultConfig{Script: []FaultKind{FaultKindAsleep}})
+	online, err := fc.IsOnline(context.Background())
+	if err != nil || online {
+		t.Errorf("IsOnline() = %v, %v; want false, nil", online, err)
+	}
+}
+
+func TestFaultyClient_ErrorRateAndOperations(t *testing.T) {
+	fc := NewFaultyClient(NewMockClient(), FaultConfig{
+		ErrorRate:  1,
+		Error:      FaultKindOffline,
+		Operations: []string{"GetVehicleStats"},
+	})
+	ctx := context.Background()
+	if _, err := fc.GetVehicleStats(ctx); !errors.Is(err, ErrVehicleOffline) {
+		t.Errorf("GetVehicleStats() error = %v, want ErrVehicleOffline", err)
+	}
+	if _, err := fc.LockVehicle(ctx); err != nil {
+		t.Errorf("LockVehicle() is not in Operations but failed: %v", err)
+	}
+}
+
+func TestFaultyClient_LatencyAndTimeout(t *testing.T) {
+	fc := NewFaultyClient(NewMockClient(), FaultConfig{Latency: Duration(20 * time.Millisecond)})
+	start := time.Now()
+	if _, err := fc.LockVehicle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("call took %v, want at least the injected latency", elapsed)
+	}
+
+	// Latency longer than the deadline, and a timeout fault, both end in ErrTimeout.
+	for _, config := range []FaultConfig{
+		{Latency: Duration(time.Minute)},
+		{Script: []FaultKind{FaultKindTimeout}},
+	} {
+		if err := fc.SetFaults(config); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := fc.UnlockVehicle(ctx)
+		cancel()
+		if !errors.Is(err, ErrTimeout) {
+			t.Errorf("UnlockVehicle() with %+v: error = %v, want ErrTimeout", config, err)
+		}
+	}
+}
+
+func TestFaultyClient_PartialData(t *testing.T) {
+	mock := NewMockClient()
+	fc := NewFaultyClient(mock, FaultConfig{PartialData: []StateCategory{CategoryClimate, CategoryLocation}})
+	state, err := fc.GetVehicleStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Climate != nil || state.Location != nil || state.Charge == nil {
+		t.Errorf("partial state: climate %v, location %v, charge %v", state.Climate, state.Location, state.Charge)
+	}
+	if state.Errors[CategoryClimate] == "" || state.Errors[CategoryLocation] == "" || len(state.Errors) != 2 {
+		t.Errorf("partial state errors = %v, want climate and location", state.Errors)
+	}
+	if full, _ := mock.GetVehicleStats(context.Background()); full.Climate == nil {
+		t.Error("partial data modified the wrapped client's state")
+	}
+}
+
+func TestFaultConfig_Validate(t *testing.T) {
+	for name, config := range map[string]FaultConfig{
+		"error rate":      {ErrorRate: 1.5},
+		"negative jitter": {Jitter: Duration(-time.Second)},
+		"unknown error":   {Error: "gremlins"},
+		"unknown script":  {Script: []FaultKind{"gremlins"}},
+		"unknown data":    {PartialData: []StateCategory{"tires"}},
+		"unknown op":      {Operations: []string{"Fly"}},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: Validate() accepted %+v", name, config)
+		}
+	}
+	if err := (FaultConfig{Operations: []string{"SetTonneau"}, Error: FaultKindTimeout}).Validate(); err != nil {
+		t.Errorf("Validate() rejected a valid config: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+// Package fleetapitest runs a fake Tesla Fleet API for one vehicle, so the RealClient path —
+// connect, session handshake, signed commands and vehicle data — can be exercised offline.
+//
+// The fake speaks the same vehicle-command protocol as a car: it answers session info requests,
+// checks the HMAC on every signed command against the keys paired with it, and serves whatever
+// carserver.VehicleData the test configures. Point RealClient at it with TESLA_FLEET_API_URL.
+package fleetapitest
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+	universal "github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/universalmessage"
+	"google.golang.org/protobuf/proto"
+)
+
+// Server is a fake Fleet API serving a single vehicle over HTTPS. Use TrustCertificate so the
+// SDK, which always dials https:// with the default transport, accepts its certificate.
+type Server struct {
+	*httptest.Server
+
+	vin   string
+	token string
+	key   *ecdh.PrivateKey // The vehicle's key, used for the session handshake.
+	start time.Time        // Vehicle clock zero; session info reports seconds since then.
+
+	mu       sync.Mutex
+	paired   map[string]bool // Public keys allowed to send commands, keyed by their bytes.
+	sessions map[universal.Domain]*domainState
+	asleep   bool
+	data     *carserver.VehicleData
+	commands []string
+}
+
+// NewServer starts a fake Fleet API for vin. The vehicle starts awake, reporting
+// DefaultVehicleData, with no paired keys; call PairKey or WriteCredentials before connecting.
+func NewServer(vin string) *Server {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("fleetapitest: generating vehicle key: %v", err))
+	}
+	s := &Server{
+		vin:      vin,
+		token:    newToken(vin),
+		key:      key,
+		start:    time.Now(),
+		paired:   make(map[string]bool),
+		sessions: make(map[universal.Domain]*domainState),
+		data:     DefaultVehicleData(),
+	}
+	for _, domain := range []universal.Domain{universal.Domain_DOMAIN_VEHICLE_SECURITY, universal.Domain_DOMAIN_INFOTAINMENT} {
+		s.sessions[domain] = newDomainState()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/1/vehicles/{vin}/signed_command", s.authorized(s.handleSignedCommand))
+	mux.HandleFunc("POST /api/1/vehicles/{vin}/wake_up", s.authorized(s.handleWakeUp))
+	s.Server = httptest.NewTLSServer(mux)
+	return s
+}
+
+// DefaultVehicleData is the state a new Server reports: a parked, locked car at 80% charge.
+func DefaultVehicleData() *carserver.VehicleData {
+	return &carserver.VehicleData{
+		ChargeState: &carserver.ChargeState{
+			OptionalBatteryLevel:   &carserver.ChargeState_BatteryLevel{BatteryLevel: 80},
+			OptionalChargeLimitSoc: &carserver.ChargeState_ChargeLimitSoc{ChargeLimitSoc: 90},
+			OptionalBatteryRange:   &carserver.ChargeState_BatteryRange{BatteryRange: 250},
+		},
+		ClimateState: &carserver.ClimateState{
+			OptionalInsideTempCelsius: &carserver.ClimateState_InsideTempCelsius{InsideTempCelsius: 21},
+			OptionalIsClimateOn:       &carserver.ClimateState_IsClimateOn{IsClimateOn: false},
+		},
+		ClosuresState: &carserver.ClosuresState{
+			OptionalLocked: &carserver.ClosuresState_Locked{Locked: true},
+		},
+	}
+}
+
+// Host returns the address to put in TESLA_FLEET_API_URL.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "https://")
+}
+
+// Token returns an OAuth token the server accepts. It is shaped like a Fleet API JWT so
+// account.New can parse it, but is not signed.
+func (s *Server) Token() string {
+	return s.token
+}
+
+// TrustCertificate makes http.DefaultTransport trust the server's certificate and returns a
+// function that restores the previous TLS configuration. The SDK's Fleet API connection does not
+// accept a custom http.Client, so this is the only way to reach a test server over HTTPS.
+func (s *Server) TrustCertificate() (restore func()) {
+	transport := http.DefaultTransport.(*http.Transport)
+	previous := transport.TLSClientConfig
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	transport.CloseIdleConnections()
+	return func() {
+		transport.TLSClientConfig = previous
+		transport.CloseIdleConnections()
+	}
+}
+
+// PairKey adds an uncompressed P-256 public key to the vehicle's keychain.
+func (s *Server) PairKey(publicKey []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paired[string(publicKey)] = true
+}
+
+// WriteCredentials generates a private key, pairs it with the vehicle and writes it and the
+// server's token to dir, returning the paths to use for TESLA_KEY_FILE and TESLA_TOKEN_FILE.
+func (s *Server) WriteCredentials(dir string) (keyFile, tokenFile string, err error) {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("generating client key: %w", err)
+	}
+	skey := protocol.UnmarshalECDHPrivateKey(private.Bytes())
+	keyFile = filepath.Join(dir, "private-key.pem")
+	if err := protocol.SavePrivateKey(skey, keyFile); err != nil {
+		return "", "", fmt.Errorf("writing client key: %w", err)
+	}
+	tokenFile = filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte(s.token), 0600); err != nil {
+		return "", "", fmt.Errorf("writing token: %w", err)
+	}
+	s.PairKey(skey.PublicBytes())
+	return keyFile, tokenFile, nil
+}
+
+// SetAsleep puts the vehicle to sleep or wakes it. A sleeping vehicle rejects signed commands
+// the way the Fleet API does until it receives a wake_up request.
+func (s *Server) SetAsleep(asleep bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.asleep = asleep
+}
+
+// Asleep reports whether the vehicle is asleep.
+func (s *Server) Asleep() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.asleep
+}
+
+// SetVehicleData replaces the data the vehicle reports. The server keeps its own copy.
+func (s *Server) SetVehicleData(data *carserver.VehicleData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = proto.Clone(data).(*carserver.VehicleData)
+}
+
+// VehicleData returns a copy of the data the vehicle currently reports, including the effect of
+// any commands it has executed.
+func (s *Server) VehicleData() *carserver.VehicleData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return proto.Clone(s.data).(*carserver.VehicleData)
+}
+
+// Locked reports whether the vehicle is locked.
+func (s *Server) Locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.GetClosuresState().GetLocked()
+}
+
+// Commands returns the names of the authenticated commands the vehicle has executed, in order:
+// the carserver action, e.g. "GetVehicleData" or "HvacAutoAction", the RKE action, e.g.
+// "RKE_ACTION_LOCK", or the tonneau move, e.g. "TONNEAU_OPEN".
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// authorized rejects requests without the server's bearer token or for another VIN, as the
+// Fleet API does.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid bearer token"})
+			return
+		}
+		if r.PathValue("vin") != s.vin {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "vehicle not found"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleWakeUp(w http.ResponseWriter, r *http.Request) {
+	s.SetAsleep(false)
+	writeJSON(w, http.StatusOK, map[string]any{"response": map[string]string{"state": "online"}})
+}
+
+func (s *Server) handleSignedCommand(w http.ResponseWriter, r *http.Request) {
+	if s.Asleep() {
+		// The SDK recognises this body as a sleeping vehicle.
+		writeJSON(w, http.StatusRequestTimeout, map[string]string{"error": "vehicle unavailable: vehicle is offline or asleep"})
+		return
+	}
+
+	var body struct {
+		RoutableMessage []byte `json:"routable_message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	var request universal.RoutableMessage
+	if err := proto.Unmarshal(body.RoutableMessage, &request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid routable_message"})
+		return
+	}
+
+	response, err := proto.Marshal(s.handleMessage(&request))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]byte{"response": response})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// newToken builds an unsigned JWT whose audience is the production Fleet API, which is all
+// account.New looks at.
+func newToken(vin string) string {
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawStdEncoding.EncodeToString(data)
+	}
+	header := encode(map[string]string{"alg": "none", "typ": "JWT"})
+	payload := encode(map[string]any{
+		"aud":     []string{"https://fleet-api.prd.na.vn.cloud.tesla.com"},
+		"ou_code": "NA",
+		"sub":     "fleetapitest-" + vin,
+	})
+	return header + "." + payload + ".unsigned"
+}
@@ -0,0 +1,395 @@
+package fleetapitest
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/signatures"
+	universal "github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/universalmessage"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/vcsec"
+	"google.golang.org/protobuf/proto"
+)
+
+// Key derivation labels from the vehicle-command protocol.
+const (
+	labelSessionInfo = "session info"
+	labelCommand     = "authenticated command"
+)
+
+// domainState is one vehicle subsystem's side of its authenticated sessions. Each domain keeps
+// its own epoch and anti-replay counters, as VCSEC and infotainment do on a real car.
+type domainState struct {
+	epoch    [16]byte
+	counters map[string]*counterWindow // Keyed by client public key.
+}
+
+func newDomainState() *domainState {
+	d := &domainState{counters: make(map[string]*counterWindow)}
+	if _, err := rand.Read(d.epoch[:]); err != nil {
+		panic(fmt.Sprintf("fleetapitest: generating epoch: %v", err))
+	}
+	return d
+}
+
+func (d *domainState) window(clientKey []byte) *counterWindow {
+	w, ok := d.counters[string(clientKey)]
+	if !ok {
+		w = &counterWindow{}
+		d.counters[string(clientKey)] = w
+	}
+	return w
+}
+
+// counterWindow is the vehicle's anti-replay check. Like the car it accepts counters that arrive
+// slightly out of order, since a client may sign several commands concurrently, but never the
+// same counter twice.
+type counterWindow struct {
+	highest uint32
+	seen    uint64 // Bit i is set if highest-i-1 has been used.
+}
+
+func (w *counterWindow) accept(counter uint32) bool {
+	switch {
+	case counter > w.highest:
+		shift := counter - w.highest
+		w.seen = w.seen<<shift | 1<<(shift-1)
+		w.highest = counter
+		return true
+	case counter == w.highest:
+		return false
+	}
+	age := w.highest - counter
+	if age > 64 || w.seen>>(age-1)&1 == 1 {
+		return false
+	}
+	w.seen |= 1 << (age - 1)
+	return true
+}
+
+// handleMessage answers one routable message the way the addressed domain would. Failures are
+// reported in the message status rather than as HTTP errors, as on a real car.
+func (s *Server) handleMessage(request *universal.RoutableMessage) *universal.RoutableMessage {
+	domain := request.GetToDestination().GetDomain()
+	response := &universal.RoutableMessage{
+		ToDestination: request.GetFromDestination(),
+		FromDestination: &universal.Destination{
+			SubDestination: &universal.Destination_Domain{Domain: domain},
+		},
+		RequestUuid: request.GetUuid(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[domain]
+	if !ok {
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_INVALID_DOMAINS)
+	}
+
+	switch payload := request.GetPayload().(type) {
+	case *universal.RoutableMessage_SessionInfoRequest:
+		return s.sessionInfo(response, session, payload.SessionInfoRequest.GetPublicKey(), request.GetUuid())
+	case *universal.RoutableMessage_ProtobufMessageAsBytes:
+		if fault := s.verify(request, domain, session); fault != universal.MessageFault_E_MESSAGEFAULT_ERROR_NONE {
+			return withFault(response, fault)
+		}
+		if domain == universal.Domain_DOMAIN_VEHICLE_SECURITY {
+			return s.executeVCSEC(response, payload.ProtobufMessageAsBytes)
+		}
+		return s.executeCarServer(response, payload.ProtobufMessageAsBytes)
+	}
+	return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_DECODING)
+}
+
+// sessionInfo answers a handshake with the domain's epoch, clock and the client's last counter,
+// authenticated with an HMAC the client checks against the request UUID.
+func (s *Server) sessionInfo(response *universal.RoutableMessage, session *domainState, clientKey, challenge []byte) *universal.RoutableMessage {
+	if !s.paired[string(clientKey)] {
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_UNKNOWN_KEY_ID)
+	}
+	sessionKey, err := s.sessionKey(clientKey)
+	if err != nil {
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_BAD_PARAMETER)
+	}
+	encoded, err := proto.Marshal(&signatures.SessionInfo{
+		Counter:   session.window(clientKey).highest,
+		PublicKey: s.key.PublicKey().Bytes(),
+		Epoch:     session.epoch[:],
+		ClockTime: s.clockTime(),
+		Status:    signatures.Session_Info_Status_SESSION_INFO_STATUS_OK,
+	})
+	if err != nil {
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_INTERNAL)
+	}
+
+	meta := newMetadata(sessionKey, labelSessionInfo)
+	meta.add(signatures.Tag_TAG_SIGNATURE_TYPE, []byte{byte(signatures.SignatureType_SIGNATURE_TYPE_HMAC)})
+	meta.add(signatures.Tag_TAG_PERSONALIZATION, []byte(s.vin))
+	meta.add(signatures.Tag_TAG_CHALLENGE, challenge)
+
+	response.Payload = &universal.RoutableMessage_SessionInfo{SessionInfo: encoded}
+	response.SubSigData = &universal.RoutableMessage_SignatureData{
+		SignatureData: &signatures.SignatureData{
+			SigType: &signatures.SignatureData_SessionInfoTag{
+				SessionInfoTag: &signatures.HMAC_Signature_Data{Tag: meta.checksum(encoded)},
+			},
+		},
+	}
+	return response
+}
+
+// verify checks the epoch, expiry, HMAC and counter of a signed command, and records the counter
+// so the command cannot be replayed. The SDK signs with HMAC over the Fleet API; AES-GCM signed
+// commands are rejected.
+func (s *Server) verify(request *universal.RoutableMessage, domain universal.Domain, session *domainState) universal.MessageFault_E {
+	signature := request.GetSignatureData()
+	data := signature.GetHMAC_PersonalizedData()
+	if data == nil {
+		return universal.MessageFault_E_MESSAGEFAULT_ERROR_INVALID_SIGNATURE
+	}
+	clientKey := signature.GetSignerIdentity().GetPublicKey()
+	if !s.paired[string(clientKey)] {
+		return universal.MessageFault_E_MESSAGEFAULT_ERROR_UNKNOWN_KEY_ID
+	}
+	if !bytes.Equal(data.GetEpoch(), session.epoch[:]) {
+		return universal.MessageFault_E_MESSAGEFAULT_ERROR_INCORRECT_EPOCH
+	}
+	if data.GetExpiresAt() < s.clockTime() {
+		return universal.MessageFault_E_MESSAGEFAULT_ERROR_TIME_EXPIRED
+	}
+	sessionKey, err := s.sessionKey(clientKey)
+	if err != nil {
+		return universal.MessageFault_E_MESSAGEFAULT_ERROR_BAD_PARAMETER
+	}
+
+	meta := newMetadata(sessionKey, labelCommand)
+	meta.add(signatures.Tag_TAG_SIGNATURE_TYPE, []byte{byte(signatures.SignatureType_SIGNATURE_TYPE_HMAC_PERSONALIZED)})
+	meta.add(signatures.Tag_TAG_DOMAIN, []byte{byte(domain)})
+	meta.add(signatures.Tag_TAG_PERSONALIZATION, []byte(s.vin))
+	meta.add(signatures.Tag_TAG_EPOCH, session.epoch[:])
+	meta.addUint32(signatures.Tag_TAG_EXPIRES_AT, data.GetExpiresAt())
+	meta.addUint32(signatures.Tag_TAG_COUNTER, data.GetCounter())
+	if request.GetFlags() > 0 {
+		meta.addUint32(signatures.Tag_TAG_FLAGS, request.GetFlags())
+	}
+	if !hmac.Equal(meta.checksum(request.GetProtobufMessageAsBytes()), data.GetTag()) {
+		return universal.MessageFault_E_MESSAGEFAULT_ERROR_INVALID_SIGNATURE
+	}
+	if !session.window(clientKey).accept(data.GetCounter()) {
+		return universal.MessageFault_E_MESSAGEFAULT_ERROR_INVALID_TOKEN_OR_COUNTER
+	}
+	return universal.MessageFault_E_MESSAGEFAULT_ERROR_NONE
+}
+
+// executeCarServer runs an infotainment action. Vehicle data requests are answered with only the
+// categories they ask for, like the car does.
+func (s *Server) executeCarServer(response *universal.RoutableMessage, payload []byte) *universal.RoutableMessage {
+	var action carserver.Action
+	if err := proto.Unmarshal(payload, &action); err != nil {
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_DECODING)
+	}
+	result := &carserver.Response{
+		ActionStatus: &carserver.ActionStatus{Result: carserver.OperationStatus_E_OPERATIONSTATUS_OK},
+	}
+	msg := action.GetVehicleAction().GetVehicleActionMsg()
+	name := strings.TrimPrefix(fmt.Sprintf("%T", msg), "*carserver.VehicleAction_")
+
+	switch msg := msg.(type) {
+	case *carserver.VehicleAction_GetVehicleData:
+		result.ResponseMsg = &carserver.Response_VehicleData{VehicleData: selectCategories(msg.GetVehicleData, s.data)}
+	case *carserver.VehicleAction_Ping:
+		result.ResponseMsg = &carserver.Response_Ping{Ping: msg.Ping}
+	case *carserver.VehicleAction_HvacAutoAction:
+		climate := s.data.GetClimateState()
+		if climate == nil {
+			climate = &carserver.ClimateState{}
+			s.data.ClimateState = climate
+		}
+		climate.OptionalIsClimateOn = &carserver.ClimateState_IsClimateOn{IsClimateOn: msg.HvacAutoAction.GetPowerOn()}
+	case *carserver.VehicleAction_ChargePortDoorOpen:
+		charge := s.data.GetChargeState()
+		if charge == nil {
+			charge = &carserver.ChargeState{}
+			s.data.ChargeState = charge
+		}
+		charge.OptionalChargePortDoorOpen = &carserver.ChargeState_ChargePortDoorOpen{ChargePortDoorOpen: true}
+	case *carserver.VehicleAction_ChargingSetLimitAction:
+		charge := s.data.GetChargeState()
+		if charge == nil {
+			charge = &carserver.ChargeState{}
+			s.data.ChargeState = charge
+		}
+		charge.OptionalChargeLimitSoc = &carserver.ChargeState_ChargeLimitSoc{ChargeLimitSoc: msg.ChargingSetLimitAction.GetPercent()}
+	default:
+		result.ActionStatus = &carserver.ActionStatus{
+			Result: carserver.OperationStatus_E_OPERATIONSTATUS_ERROR,
+			ResultReason: &carserver.ResultReason{
+				Reason: &carserver.ResultReason_PlainText{PlainText: name + " is not supported by fleetapitest"},
+			},
+		}
+	}
+	if result.GetActionStatus().GetResult() == carserver.OperationStatus_E_OPERATIONSTATUS_OK {
+		s.commands = append(s.commands, name)
+	}
+
+	encoded, err := proto.Marshal(result)
+	if err != nil {
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_INTERNAL)
+	}
+	response.Payload = &universal.RoutableMessage_ProtobufMessageAsBytes{ProtobufMessageAsBytes: encoded}
+	return response
+}
+
+// executeVCSEC runs a vehicle security command. Only the RKE actions behind Lock, Unlock and
+// the BLE wake, and tonneau moves, are supported; they complete immediately with an empty
+// response. Like the car, a vehicle whose closures report no tonneau ignores tonneau moves.
+func (s *Server) executeVCSEC(response *universal.RoutableMessage, payload []byte) *universal.RoutableMessage {
+	var msg vcsec.UnsignedMessage
+	if err := proto.Unmarshal(payload, &msg); err != nil {
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_DECODING)
+	}
+	closures := s.data.GetClosuresState()
+	if closures == nil {
+		closures = &carserver.ClosuresState{}
+		s.data.ClosuresState = closures
+	}
+
+	switch sub := msg.GetSubMessage().(type) {
+	case *vcsec.UnsignedMessage_RKEAction:
+		action := sub.RKEAction
+		switch action {
+		case vcsec.RKEAction_E_RKE_ACTION_LOCK, vcsec.RKEAction_E_RKE_ACTION_UNLOCK:
+			closures.OptionalLocked = &carserver.ClosuresState_Locked{Locked: action == vcsec.RKEAction_E_RKE_ACTION_LOCK}
+		case vcsec.RKEAction_E_RKE_ACTION_WAKE_VEHICLE:
+			// Signed commands only reach an awake vehicle, so there is nothing to do.
+		default:
+			return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_INVALID_COMMAND)
+		}
+		s.commands = append(s.commands, action.String())
+	case *vcsec.UnsignedMessage_ClosureMoveRequest:
+		move := sub.ClosureMoveRequest.GetTonneau()
+		if move == vcsec.ClosureMoveType_E_CLOSURE_MOVE_TYPE_NONE {
+			return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_INVALID_COMMAND)
+		}
+		if closures.GetOptionalTonneauState() != nil {
+			switch move {
+			case vcsec.ClosureMoveType_E_CLOSURE_MOVE_TYPE_OPEN:
+				closures.OptionalTonneauState = &carserver.ClosuresState_TonneauState{TonneauState: vcsec.ClosureState_E_CLOSURESTATE_OPEN}
+				closures.OptionalTonneauPercentOpen = &carserver.ClosuresState_TonneauPercentOpen{TonneauPercentOpen: 100}
+			case vcsec.ClosureMoveType_E_CLOSURE_MOVE_TYPE_CLOSE:
+				closures.OptionalTonneauState = &carserver.ClosuresState_TonneauState{TonneauState: vcsec.ClosureState_E_CLOSURESTATE_CLOSED}
+				closures.OptionalTonneauPercentOpen = &carserver.ClosuresState_TonneauPercentOpen{TonneauPercentOpen: 0}
+			}
+		}
+		s.commands = append(s.commands, "TONNEAU_"+strings.TrimPrefix(move.String(), "CLOSURE_MOVE_TYPE_"))
+	default:
+		return withFault(response, universal.MessageFault_E_MESSAGEFAULT_ERROR_INVALID_COMMAND)
+	}
+	return response
+}
+
+// selectCategories copies the categories requested by req out of data.
+func selectCategories(req *carserver.GetVehicleData, data *carserver.VehicleData) *carserver.VehicleData {
+	out := &carserver.VehicleData{}
+	if req.GetGetChargeState() != nil {
+		out.ChargeState = data.GetChargeState()
+	}
+	if req.GetGetClimateState() != nil {
+		out.ClimateState = data.GetClimateState()
+	}
+	if req.GetGetDriveState() != nil {
+		out.DriveState = data.GetDriveState()
+	}
+	if req.GetGetLocationState() != nil {
+		out.LocationState = data.GetLocationState()
+	}
+	if req.GetGetClosuresState() != nil {
+		out.ClosuresState = data.GetClosuresState()
+	}
+	if req.GetGetChargeScheduleState() != nil {
+		out.ChargeScheduleState = data.GetChargeScheduleState()
+	}
+	if req.GetGetPreconditioningScheduleState() != nil {
+		out.PreconditioningScheduleState = data.GetPreconditioningScheduleState()
+	}
+	if req.GetGetTirePressureState() != nil {
+		out.TirePressureState = data.GetTirePressureState()
+	}
+	if req.GetGetMediaState() != nil {
+		out.MediaState = data.GetMediaState()
+	}
+	if req.GetGetMediaDetailState() != nil {
+		out.MediaDetailState = data.GetMediaDetailState()
+	}
+	if req.GetGetSoftwareUpdateState() != nil {
+		out.SoftwareUpdateState = data.GetSoftwareUpdateState()
+	}
+	if req.GetGetParentalControlsState() != nil {
+		out.ParentalControlsState = data.GetParentalControlsState()
+	}
+	return proto.Clone(out).(*carserver.VehicleData)
+}
+
+func withFault(response *universal.RoutableMessage, fault universal.MessageFault_E) *universal.RoutableMessage {
+	response.SignedMessageStatus = &universal.MessageStatus{
+		OperationStatus:    universal.OperationStatus_E_OPERATIONSTATUS_ERROR,
+		SignedMessageFault: fault,
+	}
+	return response
+}
+
+// sessionKey derives the key shared with clientKey: the first 16 bytes of the SHA-1 of the ECDH
+// shared secret.
+func (s *Server) sessionKey(clientKey []byte) ([]byte, error) {
+	public, err := ecdh.P256().NewPublicKey(clientKey)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := s.key.ECDH(public)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha1.Sum(shared)
+	return digest[:16], nil
+}
+
+// clockTime is the vehicle clock reported in session info, in seconds since the server started.
+func (s *Server) clockTime() uint32 {
+	return uint32(time.Since(s.start) / time.Second)
+}
+
+// metadata hashes tag-length-value fields followed by a message, the encoding both ends of the
+// protocol authenticate.
+type metadata struct {
+	hash.Hash
+}
+
+func newMetadata(sessionKey []byte, label string) metadata {
+	kdf := hmac.New(sha256.New, sessionKey)
+	kdf.Write([]byte(label))
+	return metadata{hmac.New(sha256.New, kdf.Sum(nil))}
+}
+
+func (m metadata) add(tag signatures.Tag, value []byte) {
+	m.Write([]byte{byte(tag), byte(len(value))})
+	m.Write(value)
+}
+
+func (m metadata) addUint32(tag signatures.Tag, value uint32) {
+	m.add(tag, binary.BigEndian.AppendUint32(nil, value))
+}
+
+func (m metadata) checksum(message []byte) []byte {
+	m.Write([]byte{byte(signatures.Tag_TAG_END)})
+	m.Write(message)
+	return m.Sum(nil)
+}
@@ -0,0 +1,161 @@
+package tesla
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/keys"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/vcsec"
+)
+
+// ErrKeyNotFound is returned when removing a key that is not enrolled on the vehicle.
+var ErrKeyNotFound = errors.New("key is not enrolled on this vehicle")
+
+// KeyRole is the access a key on the vehicle's keychain grants. Owner keys can also add and
+// remove other keys.
+type KeyRole string
+
+const (
+	KeyRoleOwner  KeyRole = "owner"
+	KeyRoleDriver KeyRole = "driver"
+)
+
+// ParseKeyRole validates the role of a key to enroll. Vehicles report other roles, e.g. for
+// service keys, but only owner and driver keys can be added.
+func ParseKeyRole(s string) (KeyRole, error) {
+	switch role := KeyRole(s); role {
+	case KeyRoleOwner, KeyRoleDriver:
+		return role, nil
+	}
+	return "", fmt.Errorf("unknown key role %q; use owner or driver", s)
+}
+
+// KeyFormFactor is the kind of device holding a key.
+type KeyFormFactor string
+
+const (
+	KeyFormFactorCloudKey      KeyFormFactor = "cloud_key"
+	KeyFormFactorNFCCard       KeyFormFactor = "nfc_card"
+	KeyFormFactorIOSDevice     KeyFormFactor = "ios_device"
+	KeyFormFactorAndroidDevice KeyFormFactor = "android_device"
+	KeyFormFactorUnknown       KeyFormFactor = "unknown"
+)
+
+// ParseKeyFormFactor validates the form factor of a key to enroll. An empty string means a cloud
+// key, as used by servers like this one.
+func ParseKeyFormFactor(s string) (KeyFormFactor, error) {
+	switch ff := KeyFormFactor(s); ff {
+	case "":
+		return KeyFormFactorCloudKey, nil
+	case KeyFormFactorCloudKey, KeyFormFactorNFCCard, KeyFormFactorIOSDevice, KeyFormFactorAndroidDevice:
+		return ff, nil
+	}
+	return "", fmt.Errorf("unknown key form factor %q", s)
+}
+
+// VehicleKey is one entry of the vehicle's keychain.
+type VehicleKey struct {
+	Slot       int           `json:"slot"`
+	PublicKey  string        `json:"public_key"` // Hex-encoded uncompressed P-256 point.
+	Role       KeyRole       `json:"role"`       // owner, driver, or another role such as service.
+	FormFactor KeyFormFactor `json:"form_factor"`
+}
+
+// ParsePublicKey reads a P-256 public key given as PEM, as written by tesla-control, or as the hex
+// or base64 encoding of its 65-byte uncompressed form.
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		ecdsaKey, ok := parsed.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("invalid public key: not an EC key")
+		}
+		key, err := ecdsaKey.ECDH()
+		if err != nil || key.Curve() != ecdh.P256() {
+			return nil, errors.New("invalid public key: not a P-256 key")
+		}
+		return key, nil
+	}
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		if raw, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, errors.New("invalid public key: expected PEM, hex or base64")
+		}
+	}
+	key, err := ecdh.P256().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return key, nil
+}
+
+// keyID formats publicKey the way VehicleKey reports it.
+func keyID(publicKey *ecdh.PublicKey) string {
+	return hex.EncodeToString(publicKey.Bytes())
+}
+
+var sdkKeyRoles = map[keys.Role]KeyRole{
+	keys.Role_ROLE_NONE:             "none",
+	keys.Role_ROLE_SERVICE:          "service",
+	keys.Role_ROLE_OWNER:            KeyRoleOwner,
+	keys.Role_ROLE_DRIVER:           KeyRoleDriver,
+	keys.Role_ROLE_FM:               "fleet_manager",
+	keys.Role_ROLE_VEHICLE_MONITOR:  "vehicle_monitor",
+	keys.Role_ROLE_CHARGING_MANAGER: "charging_manager",
+}
+
+var sdkKeyFormFactors = map[vcsec.KeyFormFactor]KeyFormFactor{
+	vcsec.KeyFormFactor_KEY_FORM_FACTOR_UNKNOWN:        KeyFormFactorUnknown,
+	vcsec.KeyFormFactor_KEY_FORM_FACTOR_NFC_CARD:       KeyFormFactorNFCCard,
+	vcsec.KeyFormFactor_KEY_FORM_FACTOR_IOS_DEVICE:     KeyFormFactorIOSDevice,
+	vcsec.KeyFormFactor_KEY_FORM_FACTOR_ANDROID_DEVICE: KeyFormFactorAndroidDevice,
+	vcsec.KeyFormFactor_KEY_FORM_FACTOR_CLOUD_KEY:      KeyFormFactorCloudKey,
+}
+
+// vehicleKeyFromEntry converts a keychain entry reported by the vehicle.
+func vehicleKeyFromEntry(entry *vcsec.WhitelistEntryInfo) VehicleKey {
+	role, ok := sdkKeyRoles[entry.GetKeyRole()]
+	if !ok {
+		role = KeyRole(strings.ToLower(strings.TrimPrefix(entry.GetKeyRole().String(), "ROLE_")))
+	}
+	formFactor, ok := sdkKeyFormFactors[entry.GetMetadataForKey().GetKeyFormFactor()]
+	if !ok {
+		formFactor = KeyFormFactorUnknown
+	}
+	return VehicleKey{
+		Slot:       int(entry.GetSlot()),
+		PublicKey:  hex.EncodeToString(entry.GetPublicKey().GetPublicKeyRaw()),
+		Role:       role,
+		FormFactor: formFactor,
+	}
+}
+
+// sdkKeyRole and sdkKeyFormFactor convert the settings of a key to enroll.
+func sdkKeyRole(role KeyRole) (keys.Role, error) {
+	for sdkRole, r := range sdkKeyRoles {
+		if r == role && (role == KeyRoleOwner || role == KeyRoleDriver) {
+			return sdkRole, nil
+		}
+	}
+	return keys.Role_ROLE_NONE, fmt.Errorf("unknown key role %q", role)
+}
+
+func sdkKeyFormFactor(formFactor KeyFormFactor) (vcsec.KeyFormFactor, error) {
+	for sdkFormFactor, ff := range sdkKeyFormFactors {
+		if ff == formFactor {
+			return sdkFormFactor, nil
+		}
+	}
+	return vcsec.KeyFormFactor_KEY_FORM_FACTOR_UNKNOWN, fmt.Errorf("unknown key form factor %q", formFactor)
+}
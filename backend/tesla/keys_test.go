@@ -0,0 +1,120 @@
+package tesla
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/keys"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/vcsec"
+)
+
+func testPublicKey(t *testing.T, n byte) *ecdh.PublicKey {
+	t.Helper()
+	key, err := ParsePublicKey(mockPublicKey(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParsePublicKey(t *testing.T) {
+	want := testPublicKey(t, 7)
+	der, err := x509.MarshalPKIXPublicKey(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodings := map[string]string{
+		"pem":    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		"hex":    hex.EncodeToString(want.Bytes()),
+		"base64": base64.StdEncoding.EncodeToString(want.Bytes()),
+	}
+	for name, s := range encodings {
+		got, err := ParsePublicKey(s)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParsePublicKey(%s) = %v, %v; want %x", name, got, err, want.Bytes())
+		}
+	}
+	for _, s := range []string{"", "not a key", hex.EncodeToString(want.Bytes()[:33])} {
+		if _, err := ParsePublicKey(s); err == nil {
+			t.Errorf("ParsePublicKey(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestParseKeyRoleAndFormFactor(t *testing.T) {
+	if role, err := ParseKeyRole("driver"); role != KeyRoleDriver || err != nil {
+		t.Errorf("ParseKeyRole(driver) = %q, %v", role, err)
+	}
+	if _, err := ParseKeyRole("service"); err == nil {
+		t.Error("ParseKeyRole(service) succeeded, want an error")
+	}
+	if ff, err := ParseKeyFormFactor(""); ff != KeyFormFactorCloudKey || err != nil {
+		t.Errorf("ParseKeyFormFactor(\"\") = %q, %v; want cloud_key", ff, err)
+	}
+	if _, err := ParseKeyFormFactor("unknown"); err == nil {
+		t.Error("ParseKeyFormFactor(unknown) succeeded, want an error")
+	}
+}
+
+func TestVehicleKeyFromEntry(t *testing.T) {
+	pub := testPublicKey(t, 9)
+	got := vehicleKeyFromEntry(&vcsec.WhitelistEntryInfo{
+		PublicKey:      &vcsec.PublicKey{PublicKeyRaw: pub.Bytes()},
+		MetadataForKey: &vcsec.KeyMetadata{KeyFormFactor: vcsec.KeyFormFactor_KEY_FORM_FACTOR_ANDROID_DEVICE},
+		Slot:           4,
+		KeyRole:        keys.Role_ROLE_FM,
+	})
+	want := VehicleKey{Slot: 4, PublicKey: keyID(pub), Role: "fleet_manager", FormFactor: KeyFormFactorAndroidDevice}
+	if got != want {
+		t.Errorf("vehicleKeyFromEntry() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMockClient_Keys(t *testing.T) {
+	ctx := context.Background()
+	mc := NewMockClient()
+
+	driver := testPublicKey(t, 10)
+	if ok, err := mc.AddKey(ctx, driver, KeyRoleDriver, KeyFormFactorAndroidDevice); !ok || err != nil {
+		t.Fatalf("AddKey() = %v, %v", ok, err)
+	}
+	got, err := mc.ListKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VehicleKey{Slot: 3, PublicKey: keyID(driver), Role: KeyRoleDriver, FormFactor: KeyFormFactorAndroidDevice}
+	if len(got) != 4 || got[3] != want {
+		t.Fatalf("ListKeys() = %+v, want the driver key in slot 3", got)
+	}
+
+	// Adding it again updates the entry in place.
+	if ok, err := mc.AddKey(ctx, driver, KeyRoleOwner, KeyFormFactorAndroidDevice); !ok || err != nil {
+		t.Fatalf("AddKey() again = %v, %v", ok, err)
+	}
+	if got, _ := mc.ListKeys(ctx); len(got) != 4 || got[3].Role != KeyRoleOwner {
+		t.Errorf("ListKeys() after re-adding = %+v, want the key promoted to owner in slot 3", got)
+	}
+
+	if _, err := mc.RemoveKey(ctx, testPublicKey(t, 11)); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("RemoveKey() of an unknown key = %v, want ErrKeyNotFound", err)
+	}
+	for _, n := range []byte{1, 2, 3} {
+		if ok, err := mc.RemoveKey(ctx, testPublicKey(t, n)); !ok || err != nil {
+			t.Fatalf("RemoveKey(%d) = %v, %v", n, ok, err)
+		}
+	}
+	if ok, err := mc.RemoveKey(ctx, driver); ok || err == nil {
+		t.Errorf("RemoveKey() of the last owner key = %v, %v; want an error", ok, err)
+	}
+
+	mc.Sleep()
+	if _, err := mc.ListKeys(ctx); !errors.Is(err, ErrVehicleAsleep) {
+		t.Errorf("ListKeys() while asleep = %v, want ErrVehicleAsleep", err)
+	}
+}
@@ -1,40 +1,637 @@
 package tesla
 
-import "fmt"
+import (
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
 
-// MockClient is a mock implementation of the Tesla Client interface.
-type MockClient struct{}
+// MockClient is a mock implementation of the Tesla Client interface backed by a vehicle
+// simulator. Commands update the vehicle state it reports, and as the simulated clock runs the
+// battery drains while driving or running the HVAC, fills while charging, the cabin warms or cools
+// and the car moves along its heading. Scenarios (see LoadScenario) script the starting state and
+// later events. The mock starts parked and plugged in to a home charger with charging stopped,
+// and has a sunroof but no tonneau cover. A tire can be made to leak slowly with SimAction.
+type MockClient struct {
+	mu     sync.Mutex
+	state  *VehicleState
+	asleep bool
 
-// NewMockClient creates a new instance of MockClient.
+	clock        *SimClock
+	simulatedAt  time.Time        // The state reflects the vehicle at this simulated time.
+	energyKWh    float64          // Battery content; BatteryLevelPercent is derived from it.
+	cabinCelsius float64          // Unrounded InsideTempCelsius.
+	addedKWh     float64          // Unrounded EnergyAddedKWh.
+	tireLeaks    map[Tire]float64 // Bar per hour lost by each leaking tire.
+	tireBar      map[Tire]float64 // Unrounded pressure of each leaking tire.
+	pending      []scheduledStep  // Scenario steps not yet run, in time order.
+
+	keys []VehicleKey // The keychain, in slot order; scenarios leave it alone.
+}
+
+// NewMockClient creates a new instance of MockClient whose simulation runs in real time.
 func NewMockClient() *MockClient {
-	return &MockClient{}
+	return NewMockClientWithClock(NewSimClock(time.Now(), 1))
 }
 
-// GetVehicleStats returns dummy vehicle statistics.
-func (mc *MockClient) GetVehicleStats() (map[string]interface{}, error) {
-	return map[string]interface{}{
-		"vehicle_name": "DevTesla",
-		"battery_level": 75,
-		"range_miles":   200,
-		"locked":        true,
-		"location":      "123 Mock St, Dev City",
-		"charging":      false,
-	}, nil
+// NewMockClientWithClock creates a MockClient whose simulation follows clock.
+func NewMockClientWithClock(clock *SimClock) *MockClient {
+	mc := &MockClient{clock: clock, keys: newMockKeys()}
+	mc.reset(newMockVehicleState())
+	return mc
 }
 
-// LockVehicle simulates locking the vehicle.
-func (mc *MockClient) LockVehicle() (bool, error) {
-	fmt.Println("MockClient: Vehicle locked")
+// reset replaces the simulated vehicle with state and drops pending scenario steps.
+func (mc *MockClient) reset(state *VehicleState) {
+	mc.state = state
+	mc.asleep = false
+	mc.pending = nil
+	mc.tireLeaks, mc.tireBar = nil, nil
+	mc.simulatedAt = mc.clock.Now()
+	mc.syncFromState()
+	mc.publish()
+}
+
+// MockVIN is the VIN reported by MockClient.
+const MockVIN = "5YJ3E1EA0MF000000"
+
+// newMockVehicleState returns the state a fresh MockClient starts from.
+func newMockVehicleState() *VehicleState {
+	return &VehicleState{
+		Version:     VehicleStateVersion,
+		VIN:         MockVIN,
+		DisplayName: "DevTesla",
+		FetchedAt:   time.Now().UTC(),
+		Charge: &ChargeState{
+			BatteryLevelPercent:       ptr(75),
+			UsableBatteryLevelPercent: ptr(74),
+			RangeMiles:                ptr(225.0),
+			EstimatedRangeMiles:       ptr(207.0),
+			ChargingState:             ptr("stopped"),
+			ChargeLimitPercent:        ptr(80),
+			ChargerPowerKW:            ptr(0),
+			ChargerVoltageVolts:       ptr(0),
+			ChargerCurrentAmps:        ptr(0),
+			ChargingAmpsRequested:     ptr(32),
+			ChargeRateMPH:             ptr(0.0),
+			EnergyAddedKWh:            ptr(0.0),
+			MinutesToFullCharge:       ptr(0),
+			ChargePortDoorOpen:        ptr(true),
+			ChargePortLatch:           ptr("engaged"),
+			ChargeCable:               ptr("SAE"),
+			FastChargerPresent:        ptr(false),
+			ScheduledChargingMode:     ptr("off"),
+			ScheduledChargingStartMin: ptr(0),
+			ScheduledChargingPending:  ptr(false),
+		},
+		Climate: &ClimateState{
+			InsideTempCelsius:           ptr(21.5),
+			OutsideTempCelsius:          ptr(14.0),
+			DriverTempSettingCelsius:    ptr(21.0),
+			PassengerTempSettingCelsius: ptr(21.0),
+			IsClimateOn:                 ptr(false),
+			FanLevel:                    ptr(0),
+			IsFrontDefrosterOn:          ptr(false),
+			IsRearDefrosterOn:           ptr(false),
+			IsPreconditioning:           ptr(false),
+			DefrostMode:                 ptr("off"),
+			ClimateKeeperMode:           ptr(string(ClimateKeeperOff)),
+			SeatHeaterLeftLevel:         ptr(0),
+			SeatHeaterRightLevel:        ptr(0),
+			SeatHeaterRearLeftLevel:     ptr(0),
+			SeatHeaterRearCenterLevel:   ptr(0),
+			SeatHeaterRearRightLevel:    ptr(0),
+			SteeringWheelHeaterOn:       ptr(false),
+		},
+		Drive: &DriveState{
+			ShiftState:    ptr("P"),
+			SpeedMPH:      ptr(0.0),
+			PowerKW:       ptr(0),
+			OdometerMiles: ptr(12345.6),
+		},
+		Closures: &ClosuresState{
+			DoorDriverFrontOpen:      ptr(false),
+			DoorDriverRearOpen:       ptr(false),
+			DoorPassengerFrontOpen:   ptr(false),
+			DoorPassengerRearOpen:    ptr(false),
+			FrunkOpen:                ptr(false),
+			TrunkOpen:                ptr(false),
+			WindowDriverFrontOpen:    ptr(false),
+			WindowDriverRearOpen:     ptr(false),
+			WindowPassengerFrontOpen: ptr(false),
+			WindowPassengerRearOpen:  ptr(false),
+			SunroofPercentOpen:       ptr(0),
+		},
+		Location: &LocationState{
+			Latitude:       ptr(37.4925),
+			Longitude:      ptr(-121.9447),
+			HeadingDegrees: ptr(90),
+			LocationName:   ptr("123 Mock St, Dev City"),
+		},
+		Security: &SecurityState{
+			Locked:              ptr(true),
+			SentryMode:          ptr("off"),
+			SentryModeAvailable: ptr(true),
+			ValetMode:           ptr(false),
+			UserPresent:         ptr(false),
+		},
+		TirePressure: &TirePressureState{
+			FrontLeftBar:        ptr(2.9),
+			FrontRightBar:       ptr(2.9),
+			RearLeftBar:         ptr(2.9),
+			RearRightBar:        ptr(2.9),
+			RecommendedFrontBar: ptr(2.9),
+			RecommendedRearBar:  ptr(2.9),
+			FrontLeftWarning:    ptr("none"),
+			FrontRightWarning:   ptr("none"),
+			RearLeftWarning:     ptr("none"),
+			RearRightWarning:    ptr("none"),
+		},
+		Media: &MediaState{
+			PlaybackStatus:       ptr("stopped"),
+			Source:               ptr("Spotify"),
+			Volume:               ptr(3.3),
+			VolumeMax:            ptr(11.0),
+			RemoteControlEnabled: ptr(true),
+		},
+		SoftwareUpdate: &SoftwareUpdateState{
+			Status:          ptr("unknown"),
+			DownloadPercent: ptr(0),
+			InstallPercent:  ptr(0),
+		},
+	}
+}
+
+// GetVehicleStats returns dummy vehicle statistics, limited to categories if any are given.
+func (mc *MockClient) GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	for _, c := range categories {
+		if !c.Valid() {
+			return nil, fmt.Errorf("getting vehicle data: unknown state category %q", c)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(ctx, "getting vehicle data", err)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.asleep {
+		return nil, fmt.Errorf("getting vehicle data: %w", ErrVehicleAsleep)
+	}
+	state := mc.snapshot()
+	state.keepOnly(categories)
+	return state, nil
+}
+
+// update applies change to the simulated state, failing like a real command if ctx is done.
+func (mc *MockClient) update(ctx context.Context, op string, change func(s *VehicleState)) (bool, error) {
+	return mc.tryUpdate(ctx, op, func(s *VehicleState) error {
+		change(s)
+		return nil
+	})
+}
+
+// tryUpdate is update for commands the vehicle can refuse; change must not modify the state
+// before returning an error.
+func (mc *MockClient) tryUpdate(ctx context.Context, op string, change func(s *VehicleState) error) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, contextError(ctx, op, err)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.asleep {
+		return false, fmt.Errorf("%s: %w", op, ErrVehicleAsleep)
+	}
+	if err := mc.change(change); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	fmt.Printf("MockClient: %s\n", op)
 	return true, nil
 }
 
+// LockVehicle simulates locking the vehicle.
+func (mc *MockClient) LockVehicle(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "locking vehicle", func(s *VehicleState) {
+		lock(s, true)
+	})
+}
+
 // UnlockVehicle simulates unlocking the vehicle.
-func (mc *MockClient) UnlockVehicle() (bool, error) {
-	fmt.Println("MockClient: Vehicle unlocked")
+func (mc *MockClient) UnlockVehicle(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "unlocking vehicle", func(s *VehicleState) {
+		lock(s, false)
+	})
+}
+
+// lock locks or unlocks the doors. Locking means the driver walked away.
+func lock(s *VehicleState, locked bool) {
+	s.Security.Locked = ptr(locked)
+	if locked {
+		s.Security.UserPresent = ptr(false)
+	}
+}
+
+// Sleep puts the simulated vehicle to sleep; it rejects everything but Wake and IsOnline until woken.
+func (mc *MockClient) Sleep() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.asleep = true
+}
+
+// Wake simulates a wake-up request. The mock comes online immediately.
+func (mc *MockClient) Wake(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, contextError(ctx, "waking vehicle", err)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.asleep = false
+	fmt.Println("MockClient: waking vehicle")
 	return true, nil
 }
 
+// IsOnline reports whether the simulated vehicle is awake.
+func (mc *MockClient) IsOnline(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, contextError(ctx, "pinging vehicle", err)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return !mc.asleep, nil
+}
+
 // GetCameraFeed returns a dummy camera feed URL.
-func (mc *MockClient) GetCameraFeed() (string, error) {
+func (mc *MockClient) GetCameraFeed(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", contextError(ctx, "getting camera feed", err)
+	}
 	return "https://via.placeholder.com/1280x720.png?text=Mock+Camera+Feed", nil
 }
+
+// StartClimate simulates turning on the HVAC system.
+func (mc *MockClient) StartClimate(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "starting climate", startClimate)
+}
+
+func startClimate(s *VehicleState) {
+	s.Climate.IsClimateOn = ptr(true)
+	s.Climate.FanLevel = ptr(3)
+}
+
+// StopClimate simulates turning off the HVAC system, which also ends max defrost.
+func (mc *MockClient) StopClimate(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "stopping climate", stopClimate)
+}
+
+func stopClimate(s *VehicleState) {
+	s.Climate.IsClimateOn = ptr(false)
+	s.Climate.FanLevel = ptr(0)
+	s.Climate.IsPreconditioning = ptr(false)
+	s.Climate.DefrostMode = ptr("off")
+	s.Climate.IsFrontDefrosterOn = ptr(false)
+	s.Climate.IsRearDefrosterOn = ptr(false)
+}
+
+// SetTemperatures simulates changing the cabin temperature settings.
+func (mc *MockClient) SetTemperatures(ctx context.Context, driverCelsius, passengerCelsius float64) (bool, error) {
+	return mc.update(ctx, "setting temperatures", func(s *VehicleState) {
+		s.Climate.DriverTempSettingCelsius = ptr(driverCelsius)
+		s.Climate.PassengerTempSettingCelsius = ptr(passengerCelsius)
+	})
+}
+
+// SetSeatHeater simulates changing one seat heater level.
+func (mc *MockClient) SetSeatHeater(ctx context.Context, seat Seat, level HeaterLevel) (bool, error) {
+	return mc.update(ctx, "setting seat heater", func(s *VehicleState) {
+		switch seat {
+		case SeatFrontLeft:
+			s.Climate.SeatHeaterLeftLevel = ptr(int(level))
+		case SeatFrontRight:
+			s.Climate.SeatHeaterRightLevel = ptr(int(level))
+		case SeatRearLeft:
+			s.Climate.SeatHeaterRearLeftLevel = ptr(int(level))
+		case SeatRearCenter:
+			s.Climate.SeatHeaterRearCenterLevel = ptr(int(level))
+		case SeatRearRight:
+			s.Climate.SeatHeaterRearRightLevel = ptr(int(level))
+		}
+	})
+}
+
+// SetSteeringWheelHeater simulates toggling the steering wheel heater.
+func (mc *MockClient) SetSteeringWheelHeater(ctx context.Context, on bool) (bool, error) {
+	return mc.update(ctx, "setting steering wheel heater", func(s *VehicleState) {
+		s.Climate.SteeringWheelHeaterOn = ptr(on)
+	})
+}
+
+// SetMaxDefrost simulates toggling max defrost; turning it on also starts the HVAC.
+func (mc *MockClient) SetMaxDefrost(ctx context.Context, on bool) (bool, error) {
+	return mc.update(ctx, "setting max defrost", func(s *VehicleState) {
+		s.Climate.IsFrontDefrosterOn = ptr(on)
+		s.Climate.IsRearDefrosterOn = ptr(on)
+		s.Climate.IsPreconditioning = ptr(on)
+		if on {
+			s.Climate.DefrostMode = ptr("max")
+			s.Climate.IsClimateOn = ptr(true)
+			s.Climate.FanLevel = ptr(11)
+		} else {
+			s.Climate.DefrostMode = ptr("off")
+		}
+	})
+}
+
+// SetClimateKeeperMode simulates selecting Keep, Dog or Camp mode; any mode but off keeps the HVAC running.
+func (mc *MockClient) SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) (bool, error) {
+	return mc.update(ctx, "setting climate keeper mode", func(s *VehicleState) {
+		s.Climate.ClimateKeeperMode = ptr(string(mode))
+		if mode != ClimateKeeperOff {
+			s.Climate.IsClimateOn = ptr(true)
+		}
+	})
+}
+
+// mockChargerVoltage is the supply voltage of the simulated home charger.
+const mockChargerVoltage = 240
+
+var (
+	errCableNotConnected = errors.New("charge cable not connected")
+	errCableConnected    = errors.New("charge cable connected")
+)
+
+// setCharging starts or stops the simulated charging session.
+func setCharging(c *ChargeState, on bool) {
+	if !on {
+		c.ChargingState = ptr("stopped")
+		c.ChargerPowerKW = ptr(0)
+		c.ChargerVoltageVolts = ptr(0)
+		c.ChargerCurrentAmps = ptr(0)
+		return
+	}
+	amps := *c.ChargingAmpsRequested
+	c.ChargingState = ptr("charging")
+	c.ChargerVoltageVolts = ptr(mockChargerVoltage)
+	c.ChargerCurrentAmps = ptr(amps)
+	c.ChargerPowerKW = ptr(amps * mockChargerVoltage / 1000)
+	c.ScheduledChargingPending = ptr(false)
+}
+
+// StartCharging simulates starting a charging session; it fails when no cable is plugged in.
+// A battery already at the charge limit completes immediately.
+func (mc *MockClient) StartCharging(ctx context.Context) (bool, error) {
+	return mc.tryUpdate(ctx, "starting charging", startCharging)
+}
+
+func startCharging(s *VehicleState) error {
+	if s.Charge.ChargeCable == nil {
+		return errCableNotConnected
+	}
+	setCharging(s.Charge, true)
+	s.Charge.EnergyAddedKWh = ptr(0.0)
+	return nil
+}
+
+// StopCharging simulates stopping the charging session.
+func (mc *MockClient) StopCharging(ctx context.Context) (bool, error) {
+	return mc.tryUpdate(ctx, "stopping charging", stopCharging)
+}
+
+func stopCharging(s *VehicleState) error {
+	if s.Charge.ChargeCable == nil {
+		return errCableNotConnected
+	}
+	setCharging(s.Charge, false)
+	return nil
+}
+
+// SetChargeLimit simulates changing the charge limit. Raising it above the battery level after
+// charging completed resumes charging.
+func (mc *MockClient) SetChargeLimit(ctx context.Context, percent int) (bool, error) {
+	return mc.update(ctx, "setting charge limit", func(s *VehicleState) {
+		s.Charge.ChargeLimitPercent = ptr(percent)
+		if *s.Charge.ChargingState == "complete" && percent > *s.Charge.BatteryLevelPercent {
+			setCharging(s.Charge, true)
+		}
+	})
+}
+
+// SetChargingAmps simulates changing the requested charging current, which applies immediately
+// to a running session.
+func (mc *MockClient) SetChargingAmps(ctx context.Context, amps int) (bool, error) {
+	return mc.update(ctx, "setting charging amps", func(s *VehicleState) {
+		s.Charge.ChargingAmpsRequested = ptr(amps)
+		if *s.Charge.ChargingState == "charging" {
+			setCharging(s.Charge, true)
+		}
+	})
+}
+
+// OpenChargePort simulates opening the charge port door.
+func (mc *MockClient) OpenChargePort(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "opening charge port", func(s *VehicleState) {
+		s.Charge.ChargePortDoorOpen = ptr(true)
+	})
+}
+
+// CloseChargePort simulates closing the charge port door; it fails while a cable is plugged in.
+func (mc *MockClient) CloseChargePort(ctx context.Context) (bool, error) {
+	return mc.tryUpdate(ctx, "closing charge port", func(s *VehicleState) error {
+		if s.Charge.ChargeCable != nil {
+			return errCableConnected
+		}
+		s.Charge.ChargePortDoorOpen = ptr(false)
+		return nil
+	})
+}
+
+// UnlockChargeCable simulates releasing the charge cable latch and the driver unplugging it,
+// which ends any charging session.
+func (mc *MockClient) UnlockChargeCable(ctx context.Context) (bool, error) {
+	return mc.tryUpdate(ctx, "unlocking charge cable", func(s *VehicleState) error {
+		if s.Charge.ChargeCable == nil {
+			return errCableNotConnected
+		}
+		unplug(s.Charge)
+		return nil
+	})
+}
+
+// unplug simulates removing the charge cable.
+func unplug(c *ChargeState) {
+	setCharging(c, false)
+	c.ChargingState = ptr("disconnected")
+	c.ChargePortLatch = ptr("disengaged")
+	c.ChargeCable = nil
+	c.ScheduledChargingPending = ptr(false)
+}
+
+// ScheduleCharging simulates configuring a scheduled charging start time.
+func (mc *MockClient) ScheduleCharging(ctx context.Context, enabled bool, startAfterMidnight time.Duration) (bool, error) {
+	return mc.update(ctx, "scheduling charging", func(s *VehicleState) {
+		if !enabled {
+			s.Charge.ScheduledChargingMode = ptr("off")
+			s.Charge.ScheduledChargingPending = ptr(false)
+			return
+		}
+		s.Charge.ScheduledChargingMode = ptr("start_at")
+		s.Charge.ScheduledChargingStartMin = ptr(int(startAfterMidnight / time.Minute))
+		s.Charge.ScheduledChargingPending = ptr(s.Charge.ChargeCable != nil && *s.Charge.ChargingState != "charging")
+	})
+}
+
+// setWindows opens or closes every window.
+func setWindows(c *ClosuresState, open bool) {
+	c.WindowDriverFrontOpen = ptr(open)
+	c.WindowDriverRearOpen = ptr(open)
+	c.WindowPassengerFrontOpen = ptr(open)
+	c.WindowPassengerRearOpen = ptr(open)
+}
+
+// ActuateFrunk simulates opening the frunk.
+func (mc *MockClient) ActuateFrunk(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "opening frunk", func(s *VehicleState) {
+		s.Closures.FrunkOpen = ptr(true)
+	})
+}
+
+// ActuateTrunk simulates a powered liftgate: it opens a closed trunk and closes an open one.
+func (mc *MockClient) ActuateTrunk(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "actuating trunk", func(s *VehicleState) {
+		s.Closures.TrunkOpen = ptr(!*s.Closures.TrunkOpen)
+	})
+}
+
+// VentWindows simulates venting all windows.
+func (mc *MockClient) VentWindows(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "venting windows", func(s *VehicleState) {
+		setWindows(s.Closures, true)
+	})
+}
+
+// CloseWindows simulates closing all windows.
+func (mc *MockClient) CloseWindows(ctx context.Context) (bool, error) {
+	return mc.update(ctx, "closing windows", func(s *VehicleState) {
+		setWindows(s.Closures, false)
+	})
+}
+
+// SetSunroof simulates moving the sunroof.
+func (mc *MockClient) SetSunroof(ctx context.Context, percentOpen int) (bool, error) {
+	return mc.tryUpdate(ctx, "moving sunroof", func(s *VehicleState) error {
+		if s.Closures.SunroofPercentOpen == nil {
+			return ErrNotSupported
+		}
+		s.Closures.SunroofPercentOpen = ptr(percentOpen)
+		return nil
+	})
+}
+
+// SetTonneau simulates moving the tonneau cover, which the mock only has if its state reports one.
+func (mc *MockClient) SetTonneau(ctx context.Context, action TonneauAction) (bool, error) {
+	return mc.tryUpdate(ctx, "moving tonneau", func(s *VehicleState) error {
+		if s.Closures.TonneauPercentOpen == nil {
+			return ErrNotSupported
+		}
+		switch action {
+		case TonneauOpen:
+			s.Closures.TonneauPercentOpen = ptr(100)
+		case TonneauClose:
+			s.Closures.TonneauPercentOpen = ptr(0)
+		}
+		return nil
+	})
+}
+
+// mockKeySlots is the size of the simulated keychain.
+const mockKeySlots = 32
+
+var errLastOwnerKey = errors.New("cannot remove the last owner key")
+
+// newMockKeys returns the keychain a fresh MockClient starts with: the owner's key card and phone,
+// and the cloud key this backend signs commands with.
+func newMockKeys() []VehicleKey {
+	return []VehicleKey{
+		{Slot: 0, PublicKey: mockPublicKey(1), Role: KeyRoleOwner, FormFactor: KeyFormFactorNFCCard},
+		{Slot: 1, PublicKey: mockPublicKey(2), Role: KeyRoleOwner, FormFactor: KeyFormFactorIOSDevice},
+		{Slot: 2, PublicKey: mockPublicKey(3), Role: KeyRoleOwner, FormFactor: KeyFormFactorCloudKey},
+	}
+}
+
+// mockPublicKey returns the public key for the private scalar n, so the mock's keys are valid
+// P-256 points that stay the same between runs.
+func mockPublicKey(n byte) string {
+	scalar := make([]byte, 32)
+	scalar[31] = n
+	key, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		panic(fmt.Sprintf("tesla: deriving mock key: %v", err))
+	}
+	return keyID(key.PublicKey())
+}
+
+// ListKeys returns the simulated keychain.
+func (mc *MockClient) ListKeys(ctx context.Context) ([]VehicleKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(ctx, "listing keys", err)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.asleep {
+		return nil, fmt.Errorf("listing keys: %w", ErrVehicleAsleep)
+	}
+	return append([]VehicleKey(nil), mc.keys...), nil
+}
+
+// AddKey simulates enrolling a key in the first free slot. Adding a key that is already enrolled
+// changes its role and form factor.
+func (mc *MockClient) AddKey(ctx context.Context, publicKey *ecdh.PublicKey, role KeyRole, formFactor KeyFormFactor) (bool, error) {
+	return mc.tryUpdate(ctx, "adding key", func(*VehicleState) error {
+		if _, err := sdkKeyRole(role); err != nil {
+			return err
+		}
+		if _, err := sdkKeyFormFactor(formFactor); err != nil {
+			return err
+		}
+		id := keyID(publicKey)
+		used := make(map[int]bool)
+		for i, key := range mc.keys {
+			if key.PublicKey == id {
+				mc.keys[i].Role, mc.keys[i].FormFactor = role, formFactor
+				return nil
+			}
+			used[key.Slot] = true
+		}
+		for slot := 0; slot < mockKeySlots; slot++ {
+			if !used[slot] {
+				mc.keys = append(mc.keys, VehicleKey{Slot: slot, PublicKey: id, Role: role, FormFactor: formFactor})
+				slices.SortFunc(mc.keys, func(a, b VehicleKey) int { return a.Slot - b.Slot })
+				return nil
+			}
+		}
+		return errors.New("keychain is full")
+	})
+}
+
+// RemoveKey simulates removing a key. Like the vehicle, the mock keeps at least one owner key.
+func (mc *MockClient) RemoveKey(ctx context.Context, publicKey *ecdh.PublicKey) (bool, error) {
+	return mc.tryUpdate(ctx, "removing key", func(*VehicleState) error {
+		i := slices.IndexFunc(mc.keys, func(key VehicleKey) bool { return key.PublicKey == keyID(publicKey) })
+		if i < 0 {
+			return ErrKeyNotFound
+		}
+		owners := 0
+		for _, key := range mc.keys {
+			if key.Role == KeyRoleOwner {
+				owners++
+			}
+		}
+		if mc.keys[i].Role == KeyRoleOwner && owners == 1 {
+			return errLastOwnerKey
+		}
+		mc.keys = slices.Delete(mc.keys, i, i+1)
+		return nil
+	})
+}
@@ -0,0 +1,345 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// PollSchedule sets how often a Poller polls one vehicle. Vehicle-data requests keep a vehicle
+// awake, so once it has been parked and idle for IdleBeforeSleep the poller stops making them for
+// SleepWindow and only checks whether the vehicle is online, giving it the chance to fall asleep.
+type PollSchedule struct {
+	Driving         time.Duration // Between vehicle-data polls while driving.
+	Charging        time.Duration // Between vehicle-data polls while charging.
+	Parked          time.Duration // Between vehicle-data polls while parked and awake.
+	Online          time.Duration // Between online checks while asleep or falling asleep.
+	IdleBeforeSleep time.Duration // How long parked and idle before vehicle data is paused; zero never pauses.
+	SleepWindow     time.Duration // How long vehicle data stays paused while the vehicle is awake.
+}
+
+// DefaultPollSchedule returns the schedule used when nothing else is configured.
+func DefaultPollSchedule() PollSchedule {
+	return PollSchedule{
+		Driving:         15 * time.Second,
+		Charging:        30 * time.Second,
+		Parked:          2 * time.Minute,
+		Online:          time.Minute,
+		IdleBeforeSleep: 10 * time.Minute,
+		SleepWindow:     20 * time.Minute,
+	}
+}
+
+// FixedPollSchedule returns a schedule that polls vehicle data every interval and never pauses
+// it.
+func FixedPollSchedule(interval time.Duration) PollSchedule {
+	return PollSchedule{Driving: interval, Charging: interval, Parked: interval, Online: interval}
+}
+
+// PollScheduleFromEnvironment returns the schedule of the vehicle vin: DefaultPollSchedule
+// overridden by TESLA_POLL_DRIVING, TESLA_POLL_CHARGING, TESLA_POLL_PARKED, TESLA_POLL_ONLINE,
+// TESLA_POLL_IDLE_BEFORE_SLEEP and TESLA_POLL_SLEEP_WINDOW, each overridden in turn for this
+// vehicle by the same variable suffixed with _<vin>, e.g. TESLA_POLL_PARKED_5YJ3E1EA7KF000001.
+// Values use time.ParseDuration syntax (e.g. "5m"); invalid values are logged and ignored.
+func PollScheduleFromEnvironment(vin string) PollSchedule {
+	p := DefaultPollSchedule()
+	for _, setting := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"TESLA_POLL_DRIVING", &p.Driving},
+		{"TESLA_POLL_CHARGING", &p.Charging},
+		{"TESLA_POLL_PARKED", &p.Parked},
+		{"TESLA_POLL_ONLINE", &p.Online},
+		{"TESLA_POLL_IDLE_BEFORE_SLEEP", &p.IdleBeforeSleep},
+		{"TESLA_POLL_SLEEP_WINDOW", &p.SleepWindow},
+	} {
+		readDurationEnv(setting.name, setting.dst)
+		readDurationEnv(setting.name+"_"+vin, setting.dst)
+	}
+	return p
+}
+
+// PollMode is what a Poller believes the vehicle is doing, which sets how often it polls.
+type PollMode string
+
+const (
+	PollDriving       PollMode = "driving"
+	PollCharging      PollMode = "charging"
+	PollParked        PollMode = "parked"
+	PollFallingAsleep PollMode = "falling_asleep" // Vehicle data is paused so the vehicle can sleep.
+	PollAsleep        PollMode = "asleep"
+)
+
+// interval returns how long to wait in mode before polling again.
+func (p PollSchedule) interval(mode PollMode) time.Duration {
+	switch mode {
+	case PollDriving:
+		return p.Driving
+	case PollCharging:
+		return p.Charging
+	case PollParked:
+		return p.Parked
+	default:
+		return p.Online
+	}
+}
+
+// Poller polls one vehicle in the background on its PollSchedule, caching the last state it
+// fetched and passing every vehicle-data result to its subscribers. While the vehicle is asleep or
+// falling asleep it only checks whether it is online, and resumes vehicle-data polls when it is.
+type Poller struct {
+	client   Client
+	schedule PollSchedule
+	timeout  time.Duration // Of each call to the client.
+
+	mu          sync.Mutex
+	mode        PollMode
+	state       *VehicleState // The last state fetched; nil before the first.
+	err         error         // Of the last vehicle-data poll.
+	stale       bool          // A command may have changed the vehicle since state was fetched.
+	active      bool          // Someone has acted on the vehicle since the last poll.
+	idleSince   time.Time     // When the vehicle was first seen parked and idle; zero if it is not.
+	sleepUntil  time.Time     // End of the window in PollFallingAsleep.
+	subscribers map[int]func(*VehicleState, error)
+	nextID      int
+
+	nudge     chan struct{} // Asks run to poll vehicle data now.
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPoller starts polling client on schedule, bounding each call by timeout. Stop it with
+// Close.
+func NewPoller(client Client, schedule PollSchedule, timeout time.Duration) *Poller {
+	p := &Poller{
+		client:      client,
+		schedule:    schedule,
+		timeout:     timeout,
+		mode:        PollParked,
+		subscribers: map[int]func(*VehicleState, error){},
+		nudge:       make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Close stops polling and waits for a poll in flight to finish.
+func (p *Poller) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+}
+
+// Mode returns what the poller believes the vehicle is doing.
+func (p *Poller) Mode() PollMode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mode
+}
+
+// Last returns the last state fetched, nil if there is none yet, and the error of the last
+// vehicle-data poll. The state must not be modified.
+func (p *Poller) Last() (*VehicleState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state, p.err
+}
+
+// Subscribe calls fn with the result of every vehicle-data poll until the returned function is
+// called. fn is called from the polling goroutine and must not modify the state.
+func (p *Poller) Subscribe(fn func(*VehicleState, error)) (unsubscribe func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.nextID
+	p.nextID++
+	p.subscribers[id] = fn
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.subscribers, id)
+	}
+}
+
+// Stats returns the cached state limited to categories, without waking the vehicle. It fetches the
+// state first, as Refresh does, if there is none yet or a command may have changed it.
+func (p *Poller) Stats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	p.mu.Lock()
+	state := p.state
+	if p.stale {
+		state = nil
+	}
+	p.mu.Unlock()
+	if state == nil {
+		return p.Refresh(ctx, categories...)
+	}
+	state = state.Clone()
+	state.keepOnly(categories)
+	return state, nil
+}
+
+// Refresh fetches the state now, updating the cache and the subscribers, and returns it limited
+// to categories.
+func (p *Poller) Refresh(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	state, err := p.client.GetVehicleStats(ctx)
+	p.mu.Lock()
+	p.active = true
+	p.mu.Unlock()
+	p.update(state, err)
+	if err != nil {
+		return nil, err
+	}
+	state = state.Clone()
+	state.keepOnly(categories)
+	return state, nil
+}
+
+// Nudge tells the poller someone acted on the vehicle, e.g. sent it a command: the cached state
+// is no longer served by Stats, and vehicle data is polled right away even if it was paused.
+func (p *Poller) Nudge() {
+	p.mu.Lock()
+	p.stale, p.active = true, true
+	p.mu.Unlock()
+	select {
+	case p.nudge <- struct{}{}:
+	default:
+	}
+}
+
+// run polls until Close.
+func (p *Poller) run() {
+	defer close(p.done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		timer := time.NewTimer(p.poll(ctx))
+		select {
+		case <-p.stop:
+			timer.Stop()
+			return
+		case <-p.nudge:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// poll polls the vehicle once, as its mode allows, and returns how long to wait for the next poll.
+func (p *Poller) poll(ctx context.Context) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	p.mu.Lock()
+	mode, active, sleepUntil := p.mode, p.active, p.sleepUntil
+	p.mu.Unlock()
+	if !active && (mode == PollAsleep || mode == PollFallingAsleep) {
+		online, err := p.client.IsOnline(ctx)
+		if err != nil {
+			return p.schedule.Online
+		}
+		if !online {
+			p.mu.Lock()
+			if !p.active {
+				p.mode, p.idleSince = PollAsleep, time.Time{}
+			}
+			p.mu.Unlock()
+			return p.schedule.Online
+		}
+		if mode == PollFallingAsleep && time.Now().Before(sleepUntil) {
+			return p.schedule.Online
+		}
+		// Woken up, or still awake when the window closed: back to vehicle data.
+	}
+
+	state, err := p.client.GetVehicleStats(ctx)
+	select {
+	case <-p.stop:
+		return 0 // Closed while polling.
+	default:
+	}
+	return p.update(state, err)
+}
+
+// update records the result of a vehicle-data poll, tells the subscribers, and returns how long
+// to wait for the next poll.
+func (p *Poller) update(state *VehicleState, err error) time.Duration {
+	p.mu.Lock()
+	now := time.Now()
+	active := p.active
+	p.active = false
+	p.err = err
+	switch {
+	case errors.Is(err, ErrVehicleAsleep):
+		p.mode, p.idleSince = PollAsleep, time.Time{}
+	case err != nil:
+		// Keep the mode: the vehicle is presumably still doing what it was.
+	default:
+		p.state, p.stale = state, false
+		p.mode = pollMode(state)
+		if p.mode != PollParked || !idle(state) {
+			p.idleSince = time.Time{}
+		} else if p.idleSince.IsZero() || active {
+			p.idleSince = now
+		}
+		if !p.idleSince.IsZero() && p.schedule.IdleBeforeSleep > 0 && now.Sub(p.idleSince) >= p.schedule.IdleBeforeSleep {
+			// Should the vehicle still be awake when the window closes, it gets another
+			// IdleBeforeSleep of vehicle data before the next try.
+			p.mode, p.sleepUntil = PollFallingAsleep, now.Add(p.schedule.SleepWindow)
+			p.idleSince = p.sleepUntil
+		}
+	}
+	delay := p.schedule.interval(p.mode)
+	subscribers := make([]func(*VehicleState, error), 0, len(p.subscribers))
+	for _, fn := range p.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	p.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(state, err)
+	}
+	return delay
+}
+
+// pollMode returns the mode to poll a vehicle in state in while it is awake.
+func pollMode(state *VehicleState) PollMode {
+	if d := state.Drive; d != nil {
+		if d.ShiftState != nil && *d.ShiftState != "" && *d.ShiftState != "P" || d.SpeedMPH != nil && *d.SpeedMPH > 0 {
+			return PollDriving
+		}
+	}
+	if c := state.Charge; c != nil && c.ChargingState != nil {
+		switch *c.ChargingState {
+		case "charging", "starting":
+			return PollCharging
+		}
+	}
+	return PollParked
+}
+
+// idle reports whether nothing in state keeps the vehicle awake or suggests anyone is watching:
+// climate and sentry mode are off and nobody is in it.
+func idle(state *VehicleState) bool {
+	if c := state.Climate; c != nil && c.IsClimateOn != nil && *c.IsClimateOn {
+		return false
+	}
+	if s := state.Security; s != nil {
+		if s.SentryMode != nil && *s.SentryMode != "off" || s.UserPresent != nil && *s.UserPresent {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package tesla
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pollCounter counts the vehicle-data requests and online checks made of a Client.
+type pollCounter struct {
+	Client
+	data, online atomic.Int32
+}
+
+func (c *pollCounter) GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	c.data.Add(1)
+	return c.Client.GetVehicleStats(ctx, categories...)
+}
+
+func (c *pollCounter) IsOnline(ctx context.Context) (bool, error) {
+	c.online.Add(1)
+	return c.Client.IsOnline(ctx)
+}
+
+// waitForFirstPoll waits until p has polled the vehicle once.
+func waitForFirstPoll(p *Poller) {
+	for state, err := p.Last(); state == nil && err == nil; state, err = p.Last() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForMode waits until p is in mode.
+func waitForMode(t *testing.T, p *Poller, mode PollMode) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Mode() != mode {
+		if time.Now().After(deadline) {
+			t.Fatalf("poller mode = %s, want %s", p.Mode(), mode)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPollScheduleFromEnvironment(t *testing.T) {
+	t.Setenv("TESLA_POLL_PARKED", "5m")
+	t.Setenv("TESLA_POLL_DRIVING", "5s")
+	t.Setenv("TESLA_POLL_DRIVING_VIN1", "1s")
+	t.Setenv("TESLA_POLL_ONLINE_VIN1", "soon")
+
+	want := DefaultPollSchedule()
+	want.Parked, want.Driving = 5*time.Minute, time.Second
+	if got := PollScheduleFromEnvironment("VIN1"); got != want {
+		t.Errorf("PollScheduleFromEnvironment(VIN1) = %+v, want %+v", got, want)
+	}
+	want.Driving = 5 * time.Second
+	if got := PollScheduleFromEnvironment("VIN2"); got != want {
+		t.Errorf("PollScheduleFromEnvironment(VIN2) = %+v, want %+v", got, want)
+	}
+}
+
+func TestPoller_Modes(t *testing.T) {
+	mock := NewMockClient()
+	p := NewPoller(mock, DefaultPollSchedule(), time.Second)
+	t.Cleanup(p.Close)
+	waitForFirstPoll(p)
+
+	if _, err := p.Refresh(t.Context()); err != nil || p.Mode() != PollParked {
+		t.Fatalf("parked vehicle: mode %s (%v), want %s", p.Mode(), err, PollParked)
+	}
+	mock.StartCharging(t.Context())
+	if p.Refresh(t.Context()); p.Mode() != PollCharging {
+		t.Errorf("charging vehicle: mode %s, want %s", p.Mode(), PollCharging)
+	}
+	mock.Sleep()
+	if _, err := p.Refresh(t.Context()); err == nil || p.Mode() != PollAsleep {
+		t.Errorf("sleeping vehicle: mode %s (%v), want %s", p.Mode(), err, PollAsleep)
+	}
+	if got := pollMode(&VehicleState{Drive: &DriveState{ShiftState: ptr("D")}}); got != PollDriving {
+		t.Errorf("pollMode in drive = %s, want %s", got, PollDriving)
+	}
+}
+
+func TestPoller_Sleep(t *testing.T) {
+	mock := NewMockClient()
+	client := &pollCounter{Client: mock}
+	p := NewPoller(client, PollSchedule{
+		Driving:         time.Millisecond,
+		Charging:        time.Millisecond,
+		Parked:          time.Millisecond,
+		Online:          time.Millisecond,
+		IdleBeforeSleep: 20 * time.Millisecond,
+		SleepWindow:     time.Hour,
+	}, time.Second)
+	t.Cleanup(p.Close)
+
+	// Parked and idle, the vehicle is left to fall asleep: only online checks are made.
+	waitForMode(t, p, PollFallingAsleep)
+	data, online := client.data.Load(), client.online.Load()
+	time.Sleep(30 * time.Millisecond)
+	if n := client.data.Load(); n != data {
+		t.Errorf("%d vehicle-data polls while falling asleep, want none", n-data)
+	}
+	if client.online.Load() == online {
+		t.Error("no online checks while falling asleep")
+	}
+
+	mock.Sleep()
+	waitForMode(t, p, PollAsleep)
+	if n := client.data.Load(); n != data {
+		t.Errorf("%d vehicle-data polls while asleep, want none", n-data)
+	}
+
+	// Woken by someone else, the vehicle is polled again.
+	mock.Wake(t.Context())
+	deadline := time.Now().Add(5 * time.Second)
+	for client.data.Load() == data {
+		if time.Now().After(deadline) {
+			t.Fatal("no vehicle-data poll after waking")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoller_Stats(t *testing.T) {
+	mock := NewMockClient()
+	client := &pollCounter{Client: mock}
+	p := NewPoller(client, FixedPollSchedule(time.Hour), time.Second)
+	t.Cleanup(p.Close)
+	waitForFirstPoll(p)
+
+	state, err := p.Stats(t.Context(), CategoryCharge)
+	if err != nil || state.Charge == nil || state.Security != nil {
+		t.Fatalf("Stats(charge) = %+v, %v; want only the charge state", state, err)
+	}
+	polls := client.data.Load()
+	if polls != 1 {
+		t.Errorf("%d vehicle-data polls, want Stats to answer from the first", polls)
+	}
+	mock.UnlockVehicle(t.Context())
+	if state, _ := p.Stats(t.Context()); client.data.Load() != polls || !*state.Security.Locked {
+		t.Errorf("Stats polled the vehicle again or missed the cache")
+	}
+
+	// After a command the cache is refreshed.
+	p.Nudge()
+	if state, _ := p.Stats(t.Context()); *state.Security.Locked {
+		t.Error("Stats after Nudge served the state from before the command")
+	}
+}
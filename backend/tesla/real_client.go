@@ -2,24 +2,48 @@ package tesla
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/ecdh"
 	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/teslamotors/vehicle-command/pkg/cache"
 	"github.com/teslamotors/vehicle-command/pkg/cli"
+	"github.com/teslamotors/vehicle-command/pkg/connector/inet"
+	"github.com/teslamotors/vehicle-command/pkg/protocol"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/vcsec"
 	"github.com/teslamotors/vehicle-command/pkg/vehicle"
-	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver" // For VehicleData and StateCategory
+	"google.golang.org/protobuf/proto"
 )
 
 // RealClient is the implementation for interacting with the actual Tesla API.
+//
+// It keeps the cli.Config it connected with so the signed-command sessions negotiated with the
+// vehicle can be written back to TESLA_CACHE_FILE, letting the next start skip the handshake.
+// Sessions are saved every TESLA_CACHE_SAVE_INTERVAL and by Close.
 type RealClient struct {
 	vehicle *vehicle.Vehicle
+	config  *cli.Config
+
+	stop      chan struct{} // Closed by Close to end the periodic save.
+	done      chan struct{} // Closed when the periodic save has exited.
+	closeOnce sync.Once
 }
 
 // NewRealClient creates a new instance of RealClient using pkg/cli for setup.
 // Environment variables like TESLA_VIN, TESLA_KEY_NAME, TESLA_TOKEN_NAME, TESLA_CACHE_FILE are expected.
-func NewRealClient(vehicleID string) (*RealClient, error) {
+// TESLA_FLEET_API_URL optionally points the client at another Fleet API server, such as the fake
+// in package fleetapitest.
+// ctx bounds the connection handshake; the returned client is not tied to it afterwards.
+func NewRealClient(ctx context.Context, vehicleID string) (*RealClient, error) {
 	if vehicleID == "" {
 		return nil, errors.New("vehicle ID (VIN) is required for RealClient")
 	}
@@ -40,104 +64,534 @@ func NewRealClient(vehicleID string) (*RealClient, error) {
 	// It also reads TESLA_KEY_NAME, TESLA_TOKEN_NAME for keyring identification.
 	cliCfg.ReadFromEnvironment()
 
+	// A corrupt cache file would make LoadCredentials fail; start from an empty cache instead.
+	if err := recoverSessionCache(cliCfg.CacheFilename); err != nil {
+		return nil, err
+	}
+
 	// 4. Load Credentials (potentially from keyring if filenames aren't set or found)
 	if err := cliCfg.LoadCredentials(); err != nil {
 		// This step prompts for keyring password if needed.
-		return nil, fmt.Errorf("failed to load credentials via cli config: %w", err)
+		return nil, fmt.Errorf("%w: failed to load credentials via cli config: %w", ErrUnauthorized, err)
 	}
 
 	// 5. Connect to the vehicle using the cli.Config
 	// This handles obtaining private key, OAuth token, and establishing the connection.
 	// It returns an account object (which we don't use directly here) and the vehicle object.
-	ctx := context.Background() // Or a more specific context
-	_, car, err := cliCfg.Connect(ctx)
+	// TESLA_FLEET_API_URL replaces the server the token would otherwise select.
+	host, err := FleetAPIHostFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	var car *vehicle.Vehicle
+	if host != "" {
+		car, err = connectToHost(ctx, cliCfg, host)
+	} else {
+		_, car, err = cliCfg.Connect(ctx)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to vehicle via cli config: %w", err)
+		return nil, sdkError(ctx, "connecting to vehicle", fmt.Errorf("failed to connect to vehicle via cli config: %w", err))
 	}
 	if car == nil {
 		return nil, errors.New("cli config connected but returned a nil car object")
 	}
 
-	// The cli.Config.Connect should handle waking the vehicle if necessary,
-	// and also manage session caching via UpdateCachedSessions if set up.
-	// We might want to call `defer cliCfg.UpdateCachedSessions(car)` if we make cliCfg part of RealClient or manage its lifecycle.
-	// For now, the session cache is updated when `tesla-control` (the CLI tool) exits.
-	// In a long-running server, this might need more explicit management if cliCfg is not persisted.
+	// cli.Config.Connect does not wake the vehicle; commands sent while it sleeps fail with
+	// ErrVehicleAsleep until Wake (or WaitUntilOnline) brings it online.
 
-	return &RealClient{vehicle: car}, nil
+	rc := &RealClient{
+		vehicle: car,
+		config:  cliCfg,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := rc.SaveSessions(); err != nil {
+		log.Printf("Saving session cache for %s: %v", vehicleID, err)
+	}
+	go rc.saveSessionsPeriodically(SessionCacheIntervalFromEnvironment())
+	return rc, nil
 }
 
-// GetVehicleStats fetches real vehicle statistics using the Tesla SDK.
-func (rc *RealClient) GetVehicleStats() (map[string]interface{}, error) {
-	if rc.vehicle == nil {
-		return nil, errors.New("Tesla client not initialized")
+// FleetAPIHostFromEnvironment returns the host[:port] of TESLA_FLEET_API_URL, or "" if it is
+// unset. The SDK only speaks HTTPS, so the URL may omit the scheme but must not use another one.
+func FleetAPIHostFromEnvironment() (string, error) {
+	raw := strings.TrimSpace(os.Getenv("TESLA_FLEET_API_URL"))
+	if raw == "" {
+		return "", nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+		return "", fmt.Errorf("invalid TESLA_FLEET_API_URL %q: want https://host[:port]", os.Getenv("TESLA_FLEET_API_URL"))
 	}
+	return u.Host, nil
+}
 
-	ctx := context.Background()
-	// Fetch the comprehensive VehicleData object.
-	// The category given here might influence what data is prioritized or ensured,
-	// but when connected via Fleet API (as cli.Connect likely does),
-	// the returned VehicleData object is often populated with most available states.
-	vehicleData, err := rc.vehicle.GetState(ctx, vehicle.StateCategoryCharge) // Using StateCategoryCharge as a starting point.
+// connectToHost does what cli.Config.Connect does for an Internet connection, but against host
+// instead of the Fleet API server named in the OAuth token.
+func connectToHost(ctx context.Context, cliCfg *cli.Config, host string) (*vehicle.Vehicle, error) {
+	acct, err := cliCfg.Account()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	acct.Host = host
+	skey, err := cliCfg.PrivateKey()
 	if err != nil {
-		return nil, fmt.Errorf("SDK error getting vehicle data: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
 	}
 
-	if vehicleData == nil {
-		return nil, errors.New("SDK returned nil vehicle data")
+	sessions := cache.New(0)
+	if cliCfg.CacheFilename != "" && !cliCfg.DisableCache {
+		sessionCacheMu.Lock()
+		if cached, err := readSessionCache(cliCfg.CacheFilename); err == nil {
+			sessions = cached
+		}
+		sessionCacheMu.Unlock()
 	}
 
-	// Marshal the entire carserver.VehicleData object to JSON, then to map.
-	var statsMap map[string]interface{}
-	jsonData, err := json.Marshal(vehicleData)
+	car, err := acct.GetVehicle(ctx, cliCfg.VIN, skey, sessions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal vehicle data to JSON: %w", err)
+		return nil, err
 	}
-	err = json.Unmarshal(jsonData, &statsMap)
+	if err := car.Connect(ctx); err != nil {
+		return nil, err
+	}
+	if err := car.StartSession(ctx, cliCfg.Domains); err != nil {
+		car.Disconnect()
+		return nil, err
+	}
+	return car, nil
+}
+
+// SaveSessions writes the vehicle's current session state to TESLA_CACHE_FILE. It does nothing
+// when no cache file is configured.
+func (rc *RealClient) SaveSessions() error {
+	if rc.vehicle == nil || rc.config == nil || rc.config.CacheFilename == "" || rc.config.DisableCache {
+		return nil
+	}
+	return updateSessionCache(rc.config.CacheFilename, rc.vehicle.UpdateCachedSessions)
+}
+
+func (rc *RealClient) saveSessionsPeriodically(interval time.Duration) {
+	defer close(rc.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rc.stop:
+			return
+		case <-ticker.C:
+			if err := rc.SaveSessions(); err != nil {
+				log.Printf("Saving session cache for %s: %v", rc.vehicle.VIN(), err)
+			}
+		}
+	}
+}
+
+// Close stops the periodic save, persists the session cache one last time and disconnects from
+// the vehicle. It is safe to call more than once.
+func (rc *RealClient) Close() error {
+	var err error
+	rc.closeOnce.Do(func() {
+		if rc.stop != nil {
+			close(rc.stop)
+			<-rc.done
+		}
+		err = rc.SaveSessions()
+		if rc.vehicle != nil {
+			rc.vehicle.Disconnect()
+		}
+	})
+	return err
+}
+
+// sdkStateCategories maps each StateCategory to the SDK category whose response carries it.
+// Closures and security share one request.
+var sdkStateCategories = map[StateCategory]vehicle.StateCategory{
+	CategoryCharge:         vehicle.StateCategoryCharge,
+	CategoryClimate:        vehicle.StateCategoryClimate,
+	CategoryDrive:          vehicle.StateCategoryDrive,
+	CategoryClosures:       vehicle.StateCategoryClosures,
+	CategoryLocation:       vehicle.StateCategoryLocation,
+	CategorySecurity:       vehicle.StateCategoryClosures,
+	CategoryTirePressure:   vehicle.StateCategoryTirePressure,
+	CategoryMedia:          vehicle.StateCategoryMedia,
+	CategorySoftwareUpdate: vehicle.StateCategorySoftwareUpdate,
+}
+
+// GetVehicleStats fetches real vehicle statistics using the Tesla SDK. The vehicle answers one
+// category per request, so the categories are fetched concurrently and merged.
+func (rc *RealClient) GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	vehicleData, failed, err := rc.GetVehicleData(ctx, categories...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal vehicle data JSON to map: %w", err)
+		return nil, err
 	}
+	state := newVehicleState(rc.vehicle.VIN(), vehicleData, time.Now())
+	state.keepOnly(categories)
+	state.Errors = failed
+	return state, nil
+}
+
+// GetVehicleData returns the raw SDK vehicle data that GetVehicleStats is built from, merged from
+// one request per category, along with the reason each failed category could not be fetched.
+// It returns an error only if every category failed.
+func (rc *RealClient) GetVehicleData(ctx context.Context, categories ...StateCategory) (*carserver.VehicleData, map[StateCategory]string, error) {
+	if rc.vehicle == nil {
+		return nil, nil, errors.New("Tesla client not initialized")
+	}
+	categories = requestedCategories(categories)
 
-	// Add VIN to the map as it's a key identifier, accessible from the vehicle object.
-	statsMap["vin"] = rc.vehicle.VIN()
+	var requests []vehicle.StateCategory
+	for _, c := range categories {
+		sdkCategory, ok := sdkStateCategories[c]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown state category %q", c)
+		}
+		if !slices.Contains(requests, sdkCategory) {
+			requests = append(requests, sdkCategory)
+		}
+	}
+
+	responses := make([]*carserver.VehicleData, len(requests))
+	errs := make([]error, len(requests))
+	var wg sync.WaitGroup
+	for i, category := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i], errs[i] = rc.vehicle.GetState(ctx, category)
+			if errs[i] == nil && responses[i] == nil {
+				errs[i] = errors.New("SDK returned nil vehicle data")
+			}
+		}()
+	}
+	wg.Wait()
 
-	return statsMap, nil
+	vehicleData := &carserver.VehicleData{}
+	failed := make(map[StateCategory]string)
+	var firstErr error
+	for i, category := range requests {
+		if errs[i] == nil {
+			proto.Merge(vehicleData, responses[i])
+			continue
+		}
+		err := sdkError(ctx, "getting vehicle data", errs[i])
+		if firstErr == nil {
+			firstErr = err
+		}
+		for _, c := range categories {
+			if sdkStateCategories[c] == category {
+				failed[c] = err.Error()
+			}
+		}
+	}
+	if len(failed) == len(categories) {
+		return nil, nil, firstErr
+	}
+	if len(failed) == 0 {
+		failed = nil
+	}
+	return vehicleData, failed, nil
 }
 
 // LockVehicle sends a command to lock the vehicle using the Tesla SDK.
-func (rc *RealClient) LockVehicle() (bool, error) {
+func (rc *RealClient) LockVehicle(ctx context.Context) (bool, error) {
 	if rc.vehicle == nil {
 		return false, errors.New("Tesla client not initialized")
 	}
-	ctx := context.Background()
 	err := rc.vehicle.Lock(ctx)
 	if err != nil {
-		return false, fmt.Errorf("SDK error locking vehicle: %w", err)
+		return false, sdkError(ctx, "locking vehicle", err)
 	}
 	return true, nil
 }
 
 // UnlockVehicle sends a command to unlock the vehicle using the Tesla SDK.
-func (rc *RealClient) UnlockVehicle() (bool, error) {
+func (rc *RealClient) UnlockVehicle(ctx context.Context) (bool, error) {
 	if rc.vehicle == nil {
 		return false, errors.New("Tesla client not initialized")
 	}
-	ctx := context.Background()
 	err := rc.vehicle.Unlock(ctx)
 	if err != nil {
-		return false, fmt.Errorf("SDK error unlocking vehicle: %w", err)
+		return false, sdkError(ctx, "unlocking vehicle", err)
 	}
 	return true, nil
 }
 
-// GetCameraFeed fetches the real camera feed using the Tesla SDK.
-func (rc *RealClient) GetCameraFeed() (string, error) {
+// GetCameraFeed reports ErrNotSupported: vehicles do not stream their cameras over the Fleet API.
+// Recorded footage is served from the TeslaCam archive instead; see package teslacam.
+func (rc *RealClient) GetCameraFeed(ctx context.Context) (string, error) {
 	if rc.vehicle == nil {
 		return "", errors.New("Tesla client not initialized")
 	}
-	// TODO: Camera feed functionality is not directly available in pkg/vehicle's high-level API.
-	// This might require using v.Send() with specific protobuf messages for the carserver domain,
-	// or it might be part of a different API/package not yet explored.
-	// For now, returning not implemented.
-	return "", errors.New("GetCameraFeed not yet fully implemented with SDK")
+	return "", fmt.Errorf("live camera feed: %w", ErrNotSupported)
+}
+
+// command runs one SDK command against the vehicle, translating failures the same way as
+// LockVehicle. op describes the command in error messages, e.g. "starting climate".
+func (rc *RealClient) command(ctx context.Context, op string, send func(ctx context.Context) error) (bool, error) {
+	if rc.vehicle == nil {
+		return false, errors.New("Tesla client not initialized")
+	}
+	if err := send(ctx); err != nil {
+		return false, sdkError(ctx, op, err)
+	}
+	return true, nil
+}
+
+// sdkError wraps an error returned by the SDK while running op, translating a sleeping vehicle
+// into ErrVehicleAsleep, rejected credentials into ErrUnauthorized, throttling into ErrRateLimited
+// and an expired ctx into ErrTimeout.
+func sdkError(ctx context.Context, op string, err error) error {
+	if errors.Is(err, inet.ErrVehicleNotAwake) {
+		return fmt.Errorf("%s: %w", op, ErrVehicleAsleep)
+	}
+	var httpErr *inet.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.Code {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%s: %w: %w", op, ErrUnauthorized, err)
+		case http.StatusTooManyRequests:
+			return fmt.Errorf("%s: %w: %w", op, ErrRateLimited, err)
+		}
+	}
+	return contextError(ctx, op, fmt.Errorf("SDK error %s: %w", op, err))
+}
+
+// Wake sends a wake-up request. The vehicle typically takes 10-30 seconds to come online.
+func (rc *RealClient) Wake(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "waking vehicle", func(ctx context.Context) error {
+		return rc.vehicle.Wakeup(ctx)
+	})
+}
+
+// IsOnline pings the vehicle's infotainment system, which only answers while the car is awake.
+func (rc *RealClient) IsOnline(ctx context.Context) (bool, error) {
+	_, err := rc.command(ctx, "pinging vehicle", func(ctx context.Context) error {
+		return rc.vehicle.Ping(ctx)
+	})
+	if errors.Is(err, ErrVehicleAsleep) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// StartClimate turns on the HVAC system.
+func (rc *RealClient) StartClimate(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "starting climate", func(ctx context.Context) error {
+		return rc.vehicle.ClimateOn(ctx)
+	})
+}
+
+// StopClimate turns off the HVAC system.
+func (rc *RealClient) StopClimate(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "stopping climate", func(ctx context.Context) error {
+		return rc.vehicle.ClimateOff(ctx)
+	})
+}
+
+// SetTemperatures sets the driver and passenger cabin temperatures.
+func (rc *RealClient) SetTemperatures(ctx context.Context, driverCelsius, passengerCelsius float64) (bool, error) {
+	return rc.command(ctx, "setting temperatures", func(ctx context.Context) error {
+		return rc.vehicle.ChangeClimateTemp(ctx, float32(driverCelsius), float32(passengerCelsius))
+	})
+}
+
+// SetSeatHeater sets the heater level of one seat.
+func (rc *RealClient) SetSeatHeater(ctx context.Context, seat Seat, level HeaterLevel) (bool, error) {
+	return rc.command(ctx, "setting seat heater", func(ctx context.Context) error {
+		return rc.vehicle.SetSeatHeater(ctx, map[vehicle.SeatPosition]vehicle.Level{seat.sdkPosition(): level.sdkLevel()})
+	})
+}
+
+// SetSteeringWheelHeater turns the steering wheel heater on or off.
+func (rc *RealClient) SetSteeringWheelHeater(ctx context.Context, on bool) (bool, error) {
+	return rc.command(ctx, "setting steering wheel heater", func(ctx context.Context) error {
+		return rc.vehicle.SetSteeringWheelHeater(ctx, on)
+	})
+}
+
+// SetMaxDefrost turns max defrost (maximum preconditioning) on or off.
+func (rc *RealClient) SetMaxDefrost(ctx context.Context, on bool) (bool, error) {
+	return rc.command(ctx, "setting max defrost", func(ctx context.Context) error {
+		return rc.vehicle.SetPreconditioningMax(ctx, on, false)
+	})
+}
+
+// SetClimateKeeperMode selects Keep, Dog or Camp mode, or turns climate keeper off.
+func (rc *RealClient) SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) (bool, error) {
+	return rc.command(ctx, "setting climate keeper mode", func(ctx context.Context) error {
+		return rc.vehicle.SetClimateKeeperMode(ctx, mode.sdkMode(), false)
+	})
+}
+
+// StartCharging starts charging if a cable is plugged in.
+func (rc *RealClient) StartCharging(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "starting charging", func(ctx context.Context) error {
+		return rc.vehicle.ChargeStart(ctx)
+	})
+}
+
+// StopCharging stops the current charging session.
+func (rc *RealClient) StopCharging(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "stopping charging", func(ctx context.Context) error {
+		return rc.vehicle.ChargeStop(ctx)
+	})
+}
+
+// SetChargeLimit sets the state of charge, in percent, at which charging stops.
+func (rc *RealClient) SetChargeLimit(ctx context.Context, percent int) (bool, error) {
+	return rc.command(ctx, "setting charge limit", func(ctx context.Context) error {
+		return rc.vehicle.ChangeChargeLimit(ctx, int32(percent))
+	})
+}
+
+// SetChargingAmps sets the current the vehicle requests from the charger.
+func (rc *RealClient) SetChargingAmps(ctx context.Context, amps int) (bool, error) {
+	return rc.command(ctx, "setting charging amps", func(ctx context.Context) error {
+		return rc.vehicle.SetChargingAmps(ctx, int32(amps))
+	})
+}
+
+// OpenChargePort opens the charge port door.
+func (rc *RealClient) OpenChargePort(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "opening charge port", func(ctx context.Context) error {
+		return rc.vehicle.OpenChargePort(ctx)
+	})
+}
+
+// CloseChargePort closes the charge port door.
+func (rc *RealClient) CloseChargePort(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "closing charge port", func(ctx context.Context) error {
+		return rc.vehicle.CloseChargePort(ctx)
+	})
+}
+
+// UnlockChargeCable releases the charge cable latch. The protocol has no separate unlatch
+// message: it sends ChargePortDoorOpen, the same message as OpenChargePort, because on Tesla
+// vehicles opening the port door is what releases a latched cable (the door is already open while
+// a cable is plugged in). The route is kept apart from OpenChargePort to say what the caller
+// means, and so the simulator can model the two.
+func (rc *RealClient) UnlockChargeCable(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "unlocking charge cable", func(ctx context.Context) error {
+		return rc.vehicle.ChargePortOpen(ctx)
+	})
+}
+
+// ScheduleCharging enables or disables starting to charge at startAfterMidnight, local time.
+func (rc *RealClient) ScheduleCharging(ctx context.Context, enabled bool, startAfterMidnight time.Duration) (bool, error) {
+	return rc.command(ctx, "scheduling charging", func(ctx context.Context) error {
+		return rc.vehicle.ScheduleCharging(ctx, enabled, startAfterMidnight)
+	})
+}
+
+// ActuateFrunk opens the frunk. It cannot be closed remotely.
+func (rc *RealClient) ActuateFrunk(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "opening frunk", func(ctx context.Context) error {
+		return rc.vehicle.OpenFrunk(ctx)
+	})
+}
+
+// ActuateTrunk opens the trunk, or closes it on vehicles with a powered liftgate.
+func (rc *RealClient) ActuateTrunk(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "actuating trunk", func(ctx context.Context) error {
+		return rc.vehicle.ActuateTrunk(ctx)
+	})
+}
+
+// VentWindows lowers all windows slightly.
+func (rc *RealClient) VentWindows(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "venting windows", func(ctx context.Context) error {
+		return rc.vehicle.VentWindows(ctx)
+	})
+}
+
+// CloseWindows closes all windows.
+func (rc *RealClient) CloseWindows(ctx context.Context) (bool, error) {
+	return rc.command(ctx, "closing windows", func(ctx context.Context) error {
+		return rc.vehicle.CloseWindows(ctx)
+	})
+}
+
+// SetSunroof moves the sunroof to percentOpen; 0 closes it.
+func (rc *RealClient) SetSunroof(ctx context.Context, percentOpen int) (bool, error) {
+	return rc.command(ctx, "moving sunroof", func(ctx context.Context) error {
+		return rc.vehicle.ChangeSunroofState(ctx, int32(percentOpen))
+	})
+}
+
+// SetTonneau opens, closes or stops the tonneau cover. Vehicles without one accept the command
+// and ignore it, so the closures state is read first: if it reports no tonneau, SetTonneau returns
+// an ErrNotSupported error without sending anything.
+func (rc *RealClient) SetTonneau(ctx context.Context, action TonneauAction) (bool, error) {
+	data, _, err := rc.GetVehicleData(ctx, CategoryClosures)
+	if err != nil {
+		return false, err
+	}
+	if closures := data.GetClosuresState(); closures.GetOptionalTonneauState() == nil && closures.GetOptionalTonneauPercentOpen() == nil {
+		return false, fmt.Errorf("moving tonneau: %w", ErrNotSupported)
+	}
+	return rc.command(ctx, "moving tonneau", func(ctx context.Context) error {
+		switch action {
+		case TonneauOpen:
+			return rc.vehicle.OpenTonneau(ctx)
+		case TonneauClose:
+			return rc.vehicle.CloseTonneau(ctx)
+		case TonneauStop:
+			return rc.vehicle.StopTonneau(ctx)
+		}
+		return fmt.Errorf("unknown tonneau action %q", action)
+	})
+}
+
+// ListKeys reads the vehicle's keychain: a summary of the occupied slots, then each entry.
+func (rc *RealClient) ListKeys(ctx context.Context) ([]VehicleKey, error) {
+	if rc.vehicle == nil {
+		return nil, errors.New("Tesla client not initialized")
+	}
+	summary, err := rc.vehicle.KeySummary(ctx)
+	if err != nil {
+		return nil, sdkError(ctx, "listing keys", err)
+	}
+	var keys []VehicleKey
+	for slot := uint32(0); slot < 32; slot++ {
+		if summary.GetSlotMask()&(1<<slot) == 0 {
+			continue
+		}
+		entry, err := rc.vehicle.KeyInfoBySlot(ctx, slot)
+		if err != nil {
+			return nil, sdkError(ctx, fmt.Sprintf("reading key in slot %d", slot), err)
+		}
+		keys = append(keys, vehicleKeyFromEntry(entry))
+	}
+	return keys, nil
+}
+
+// AddKey enrolls publicKey with role. The client's own key must be an owner key.
+func (rc *RealClient) AddKey(ctx context.Context, publicKey *ecdh.PublicKey, role KeyRole, formFactor KeyFormFactor) (bool, error) {
+	sdkRole, err := sdkKeyRole(role)
+	if err != nil {
+		return false, err
+	}
+	sdkFormFactor, err := sdkKeyFormFactor(formFactor)
+	if err != nil {
+		return false, err
+	}
+	return rc.command(ctx, "adding key", func(ctx context.Context) error {
+		return rc.vehicle.AddKeyWithRole(ctx, publicKey, sdkRole, sdkFormFactor)
+	})
+}
+
+// RemoveKey removes publicKey from the keychain. The client's own key must be an owner key.
+func (rc *RealClient) RemoveKey(ctx context.Context, publicKey *ecdh.PublicKey) (bool, error) {
+	return rc.command(ctx, "removing key", func(ctx context.Context) error {
+		err := rc.vehicle.RemoveKey(ctx, publicKey)
+		var keychainErr *protocol.KeychainError
+		if errors.As(err, &keychainErr) && keychainErr.Code == vcsec.WhitelistOperationInformation_E_WHITELISTOPERATION_INFORMATION_PUBLIC_KEY_NOT_ON_WHITELIST {
+			return fmt.Errorf("%w: %w", ErrKeyNotFound, err)
+		}
+		return err
+	})
 }
@@ -1,8 +1,20 @@
 package tesla
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ameena3/tesla/backend/tesla/fleetapitest"
+	"github.com/teslamotors/vehicle-command/pkg/connector/inet"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/vcsec"
 )
 
 func TestNewRealClient_MissingEnvVars(t *testing.T) {
@@ -43,7 +55,7 @@ func TestNewRealClient_MissingEnvVars(t *testing.T) {
 	// which relies on environment variables (TESLA_KEY_NAME, TESLA_TOKEN_NAME etc.)
 	// for loading credentials and establishing a connection.
 	// Without these, cliCfg.Connect() is expected to fail.
-	client, err := NewRealClient("test-vin-arg")
+	client, err := NewRealClient(context.Background(), "test-vin-arg")
 
 	if err == nil {
 		t.Errorf("NewRealClient() was expected to return an error when crucial environment variables are missing, but it did not")
@@ -56,3 +68,254 @@ func TestNewRealClient_MissingEnvVars(t *testing.T) {
 	// Further check on error message if desired, e.g. strings.Contains(err.Error(), "TESLA_KEY_NAME")
 	// For now, just checking for any error is sufficient for this basic test.
 }
+
+const fleetTestVIN = "5YJ3E1EA7KF000316"
+
+// startFleetAPI runs a fake Fleet API and points the environment at it with freshly paired
+// credentials, so NewRealClient connects to it.
+func startFleetAPI(t *testing.T) *fleetapitest.Server {
+	t.Helper()
+	srv := fleetapitest.NewServer(fleetTestVIN)
+	t.Cleanup(srv.Close)
+	t.Cleanup(srv.TrustCertificate())
+
+	dir := t.TempDir()
+	keyFile, tokenFile, err := srv.WriteCredentials(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TESLA_FLEET_API_URL", srv.URL)
+	t.Setenv("TESLA_KEY_FILE", keyFile)
+	t.Setenv("TESLA_TOKEN_FILE", tokenFile)
+	t.Setenv("TESLA_KEY_NAME", "")
+	t.Setenv("TESLA_TOKEN_NAME", "")
+	t.Setenv("TESLA_CACHE_FILE", filepath.Join(dir, "cache.json"))
+	return srv
+}
+
+func newFleetAPIClient(t *testing.T) *RealClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rc, err := NewRealClient(ctx, fleetTestVIN)
+	if err != nil {
+		t.Fatalf("NewRealClient() = %v", err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc
+}
+
+func TestRealClient_FleetAPI(t *testing.T) {
+	srv := startFleetAPI(t)
+	rc := newFleetAPIClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// One category is one GetVehicleData request, which keeps the command log below predictable.
+	state, err := rc.GetVehicleStats(ctx, CategoryCharge)
+	if err != nil {
+		t.Fatalf("GetVehicleStats() = %v", err)
+	}
+	if state.VIN != fleetTestVIN {
+		t.Errorf("VIN = %q, want %q", state.VIN, fleetTestVIN)
+	}
+	if state.Charge == nil || state.Charge.BatteryLevelPercent == nil || *state.Charge.BatteryLevelPercent != 80 {
+		t.Errorf("Charge = %+v, want battery level 80", state.Charge)
+	}
+
+	if ok, err := rc.UnlockVehicle(ctx); !ok || err != nil {
+		t.Fatalf("UnlockVehicle() = %v, %v", ok, err)
+	}
+	if srv.Locked() {
+		t.Error("vehicle still locked after UnlockVehicle")
+	}
+	if ok, err := rc.LockVehicle(ctx); !ok || err != nil {
+		t.Fatalf("LockVehicle() = %v, %v", ok, err)
+	}
+	if !srv.Locked() {
+		t.Error("vehicle not locked after LockVehicle")
+	}
+
+	want := []string{"GetVehicleData", "RKE_ACTION_UNLOCK", "RKE_ACTION_LOCK"}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands() = %v, want %v", got, want)
+	}
+}
+
+func TestRealClient_FleetAPICategories(t *testing.T) {
+	srv := startFleetAPI(t)
+	rc := newFleetAPIClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Closures and security come from the same vehicle category, so they cost one request.
+	state, err := rc.GetVehicleStats(ctx, CategoryClimate, CategoryClosures, CategorySecurity)
+	if err != nil {
+		t.Fatalf("GetVehicleStats() = %v", err)
+	}
+	if state.Climate == nil || state.Closures == nil || state.Security == nil || state.Charge != nil || len(state.Errors) != 0 {
+		t.Errorf("GetVehicleStats(climate, closures, security) = %+v", state)
+	}
+	if got := srv.Commands(); len(got) != 2 {
+		t.Errorf("Commands() = %v, want two GetVehicleData requests", got)
+	}
+
+	state, err = rc.GetVehicleStats(ctx)
+	if err != nil {
+		t.Fatalf("GetVehicleStats() = %v", err)
+	}
+	if state.Charge == nil || state.Climate == nil || state.Security == nil || *state.Security.Locked != true {
+		t.Errorf("GetVehicleStats() = %+v, want every category the vehicle reports", state)
+	}
+}
+
+func TestRealClient_FleetAPIReusesCachedSessions(t *testing.T) {
+	srv := startFleetAPI(t)
+	if err := newFleetAPIClient(t).Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	sessions, err := readSessionCache(os.Getenv("TESLA_CACHE_FILE"))
+	if err != nil {
+		t.Fatalf("reading session cache: %v", err)
+	}
+	if _, ok := sessions.GetEntry(fleetTestVIN); !ok {
+		t.Fatalf("session cache has no entry for %s", fleetTestVIN)
+	}
+
+	// The second client skips the handshake; its commands must still verify.
+	rc := newFleetAPIClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if ok, err := rc.UnlockVehicle(ctx); !ok || err != nil {
+		t.Fatalf("UnlockVehicle() with cached session = %v, %v", ok, err)
+	}
+	if srv.Locked() {
+		t.Error("vehicle still locked after UnlockVehicle")
+	}
+}
+
+func TestRealClient_FleetAPIAsleep(t *testing.T) {
+	srv := startFleetAPI(t)
+	rc := newFleetAPIClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	srv.SetAsleep(true)
+	if _, err := rc.GetVehicleStats(ctx); !errors.Is(err, ErrVehicleAsleep) {
+		t.Fatalf("GetVehicleStats() while asleep = %v, want ErrVehicleAsleep", err)
+	}
+	if online, err := rc.IsOnline(ctx); online || err != nil {
+		t.Fatalf("IsOnline() while asleep = %v, %v, want false, nil", online, err)
+	}
+	if ok, err := rc.Wake(ctx); !ok || err != nil {
+		t.Fatalf("Wake() = %v, %v", ok, err)
+	}
+	if srv.Asleep() {
+		t.Fatal("vehicle still asleep after Wake")
+	}
+	if _, err := rc.GetVehicleStats(ctx); err != nil {
+		t.Fatalf("GetVehicleStats() after Wake = %v", err)
+	}
+}
+
+func TestRealClient_FleetAPIUnlockChargeCable(t *testing.T) {
+	srv := startFleetAPI(t)
+	rc := newFleetAPIClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The vehicle has no unlatch message: opening the port door releases the cable.
+	if ok, err := rc.UnlockChargeCable(ctx); !ok || err != nil {
+		t.Fatalf("UnlockChargeCable() = %v, %v", ok, err)
+	}
+	if ok, err := rc.OpenChargePort(ctx); !ok || err != nil {
+		t.Fatalf("OpenChargePort() = %v, %v", ok, err)
+	}
+	want := []string{"ChargePortDoorOpen", "ChargePortDoorOpen"}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands() = %v, want %v", got, want)
+	}
+}
+
+func TestRealClient_FleetAPITonneau(t *testing.T) {
+	srv := startFleetAPI(t)
+	rc := newFleetAPIClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The default vehicle reports no tonneau, so nothing is sent.
+	if ok, err := rc.SetTonneau(ctx, TonneauOpen); ok || !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("SetTonneau() without a tonneau = %v, %v, want ErrNotSupported", ok, err)
+	}
+	if got, want := srv.Commands(), []string{"GetVehicleData"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands() = %v, want %v", got, want)
+	}
+
+	data := fleetapitest.DefaultVehicleData()
+	data.ClosuresState.OptionalTonneauState = &carserver.ClosuresState_TonneauState{TonneauState: vcsec.ClosureState_E_CLOSURESTATE_CLOSED}
+	srv.SetVehicleData(data)
+	if ok, err := rc.SetTonneau(ctx, TonneauOpen); !ok || err != nil {
+		t.Fatalf("SetTonneau() = %v, %v", ok, err)
+	}
+	state, err := rc.GetVehicleStats(ctx, CategoryClosures)
+	if err != nil || state.Closures.TonneauPercentOpen == nil || *state.Closures.TonneauPercentOpen != 100 {
+		t.Errorf("closures after opening the tonneau = %+v, %v", state.Closures, err)
+	}
+}
+
+func TestRealClient_FleetAPIUnauthorized(t *testing.T) {
+	srv := startFleetAPI(t)
+	forged := strings.Replace(srv.Token(), "unsigned", "forged", 1)
+	if err := os.WriteFile(os.Getenv("TESLA_TOKEN_FILE"), []byte(forged), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rc, err := NewRealClient(ctx, fleetTestVIN)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("NewRealClient() with a rejected token = %v, want ErrUnauthorized", err)
+	}
+	if rc != nil {
+		rc.Close()
+	}
+}
+
+func TestFleetAPIHostFromEnvironment(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: "https://127.0.0.1:8443", want: "127.0.0.1:8443"},
+		{value: "127.0.0.1:8443", want: "127.0.0.1:8443"},
+		{value: "https://fleet.example.com/", want: "fleet.example.com"},
+		{value: "http://127.0.0.1:8443", wantErr: true},
+		{value: "https://fleet.example.com/api/1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("TESLA_FLEET_API_URL", tt.value)
+		got, err := FleetAPIHostFromEnvironment()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("FleetAPIHostFromEnvironment() with %q = %q, %v; want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSDKError(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		err  error
+		want error
+	}{
+		{inet.ErrVehicleNotAwake, ErrVehicleAsleep},
+		{&inet.HTTPError{Code: http.StatusUnauthorized}, ErrUnauthorized},
+		{&inet.HTTPError{Code: http.StatusTooManyRequests}, ErrRateLimited},
+	} {
+		if err := sdkError(ctx, "locking vehicle", tt.err); !errors.Is(err, tt.want) {
+			t.Errorf("sdkError(%v) = %v, want %v", tt.err, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package tesla
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ScrubbedVIN replaces the real VIN in recorded fixtures.
+const ScrubbedVIN = "5YJ3E1EA0SCRUBBED"
+
+// Recorded coordinates are all moved to this point.
+const (
+	scrubbedLatitude  = 37.4925
+	scrubbedLongitude = -121.9447
+)
+
+// Interaction is one recorded Client call: a line of a fixture file.
+type Interaction struct {
+	Operation string          `json:"operation"`        // The Client method, e.g. "SetChargeLimit".
+	Args      json.RawMessage `json:"args,omitempty"`   // The arguments after ctx, as a JSON object.
+	Result    json.RawMessage `json:"result,omitempty"` // The first return value, when there was no error.
+
+	// VehicleData is the raw carserver.VehicleData behind a GetVehicleStats result, as protojson.
+	// Replaying it goes through the same conversion as a live vehicle.
+	VehicleData json.RawMessage `json:"vehicle_data,omitempty"`
+
+	Error      *FixtureError `json:"error,omitempty"`
+	RecordedAt time.Time     `json:"recorded_at"`
+}
+
+// FixtureError is a recorded error. Kind names the sentinel error it wrapped, if any, so a replay
+// fails in the way the handlers expect.
+type FixtureError struct {
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+// fixtureErrorKinds are the sentinel errors preserved across a recording, by kind.
+var fixtureErrorKinds = map[string]error{
+	"asleep":          ErrVehicleAsleep,
+	"offline":         ErrVehicleOffline,
+	"timeout":         ErrTimeout,
+	"unauthorized":    ErrUnauthorized,
+	"not_supported":   ErrNotSupported,
+	"not_connected":   ErrVehicleNotConnected,
+	"rate_limited":    ErrRateLimited,
+	"key_not_found":   ErrKeyNotFound,
+	"unknown_vehicle": ErrUnknownVehicle,
+}
+
+func newFixtureError(err error) *FixtureError {
+	fe := &FixtureError{Message: err.Error()}
+	for kind, sentinel := range fixtureErrorKinds {
+		if errors.Is(err, sentinel) && (fe.Kind == "" || kind < fe.Kind) {
+			fe.Kind = kind // The first in name order, for repeatable fixtures.
+		}
+	}
+	return fe
+}
+
+// Err recreates the recorded error.
+func (fe *FixtureError) Err() error {
+	return replayedError{message: fe.Message, kind: fixtureErrorKinds[fe.Kind]}
+}
+
+type replayedError struct {
+	message string
+	kind    error
+}
+
+func (e replayedError) Error() string { return e.message }
+func (e replayedError) Unwrap() error { return e.kind }
+
+// VehicleDataSource is implemented by clients that can return the raw SDK vehicle data behind
+// GetVehicleStats, such as RealClient.
+type VehicleDataSource interface {
+	GetVehicleData(ctx context.Context, categories ...StateCategory) (*carserver.VehicleData, map[StateCategory]string, error)
+}
+
+// RecordingClient wraps a Client, typically a RealClient, and appends every call and its outcome
+// to a fixture file as JSON lines, for ReplayClient to serve back later. The VIN and any
+// coordinates, addresses or destinations are scrubbed before they are written.
+type RecordingClient struct {
+	client Client
+	vin    string
+
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewRecordingClient records the calls made to client, the vehicle identified by vin, to the
+// fixture file at path. An existing file is appended to.
+func NewRecordingClient(vin string, client Client, path string) (*RecordingClient, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening fixture file: %w", err)
+	}
+	return &RecordingClient{client: client, vin: vin, file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Unwrap returns the recorded client.
+func (rc *RecordingClient) Unwrap() Client { return rc.client }
+
+// Close closes the fixture file and, if it is an io.Closer, the recorded client.
+func (rc *RecordingClient) Close() error {
+	closeClient(rc.vin, rc.client)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return errors.Join(rc.w.Flush(), rc.file.Close())
+}
+
+// write scrubs and appends one interaction. Recording is best effort: a failure is returned to
+// nobody, so it is logged instead.
+func (rc *RecordingClient) write(in Interaction) {
+	line, err := json.Marshal(in)
+	if err == nil {
+		line, err = scrubJSON(line, rc.vin)
+	}
+	if err == nil {
+		rc.mu.Lock()
+		_, err = rc.w.Write(append(line, '\n'))
+		if err == nil {
+			err = rc.w.Flush()
+		}
+		rc.mu.Unlock()
+	}
+	if err != nil {
+		log.Printf("RecordingClient: failed to record %s: %v", in.Operation, err)
+	}
+}
+
+// record calls f and records the call to op with args.
+func record[T any](rc *RecordingClient, op string, args map[string]any, f func() (T, error)) (T, error) {
+	v, err := f()
+	in := Interaction{Operation: op, RecordedAt: time.Now().UTC()}
+	if args != nil {
+		in.Args = mustMarshal(args)
+	}
+	if err != nil {
+		in.Error = newFixtureError(err)
+	} else {
+		in.Result = mustMarshal(v)
+	}
+	rc.write(in)
+	return v, err
+}
+
+func mustMarshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err) // Only called with JSON-safe values.
+	}
+	return data
+}
+
+// scrubJSON replaces vin throughout a JSON document, moves every latitude and longitude to a
+// fixed point and drops location names and navigation destinations.
+func scrubJSON(data []byte, vin string) ([]byte, error) {
+	if vin != "" {
+		data = bytes.ReplaceAll(data, []byte(vin), []byte(ScrubbedVIN))
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(scrubValue(doc))
+}
+
+func scrubValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			lower := strings.ToLower(key)
+			switch {
+			case strings.Contains(lower, "latitude"):
+				v[key] = scrubbedLatitude
+			case strings.Contains(lower, "longitude"):
+				v[key] = scrubbedLongitude
+			case strings.Contains(lower, "location_name"), strings.Contains(lower, "locationname"),
+				strings.Contains(lower, "destination"):
+				delete(v, key)
+			default:
+				v[key] = scrubValue(value)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = scrubValue(v[i])
+		}
+	}
+	return v
+}
+
+// GetVehicleStats implements Client. When the recorded client is a VehicleDataSource the raw
+// vehicle data is recorded too.
+func (rc *RecordingClient) GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	args := categoryArgs(categories)
+	source, ok := rc.client.(VehicleDataSource)
+	if !ok {
+		return record(rc, "GetVehicleStats", args, func() (*VehicleState, error) { return rc.client.GetVehicleStats(ctx, categories...) })
+	}
+
+	data, failed, err := source.GetVehicleData(ctx, categories...)
+	in := Interaction{Operation: "GetVehicleStats", RecordedAt: time.Now().UTC()}
+	if args != nil {
+		in.Args = mustMarshal(args)
+	}
+	if err != nil {
+		in.Error = newFixtureError(err)
+		rc.write(in)
+		return nil, err
+	}
+	state := newVehicleState(rc.vin, data, time.Now())
+	state.keepOnly(categories)
+	state.Errors = failed
+	in.Result = mustMarshal(state)
+	if in.VehicleData, err = (protojson.MarshalOptions{UseProtoNames: true}).Marshal(data); err != nil {
+		in.VehicleData = nil
+		log.Printf("RecordingClient: failed to encode vehicle data: %v", err)
+	}
+	rc.write(in)
+	return state, nil
+}
+
+// categoryArgs returns the recorded arguments of a GetVehicleStats call: none when it asked for
+// every category.
+func categoryArgs(categories []StateCategory) map[string]any {
+	if len(categories) == 0 {
+		return nil
+	}
+	return map[string]any{"categories": categories}
+}
+
+// LockVehicle implements Client.
+func (rc *RecordingClient) LockVehicle(ctx context.Context) (bool, error) {
+	return record(rc, "LockVehicle", nil, func() (bool, error) { return rc.client.LockVehicle(ctx) })
+}
+
+// UnlockVehicle implements Client.
+func (rc *RecordingClient) UnlockVehicle(ctx context.Context) (bool, error) {
+	return record(rc, "UnlockVehicle", nil, func() (bool, error) { return rc.client.UnlockVehicle(ctx) })
+}
+
+// GetCameraFeed implements Client.
+func (rc *RecordingClient) GetCameraFeed(ctx context.Context) (string, error) {
+	return record(rc, "GetCameraFeed", nil, func() (string, error) { return rc.client.GetCameraFeed(ctx) })
+}
+
+// Wake implements Client.
+func (rc *RecordingClient) Wake(ctx context.Context) (bool, error) {
+	return record(rc, "Wake", nil, func() (bool, error) { return rc.client.Wake(ctx) })
+}
+
+// IsOnline implements Client.
+func (rc *RecordingClient) IsOnline(ctx context.Context) (bool, error) {
+	return record(rc, "IsOnline", nil, func() (bool, error) { return rc.client.IsOnline(ctx) })
+}
+
+// StartClimate implements Client.
+func (rc *RecordingClient) StartClimate(ctx context.Context) (bool, error) {
+	return record(rc, "StartClimate", nil, func() (bool, error) { return rc.client.StartClimate(ctx) })
+}
+
+// StopClimate implements Client.
+func (rc *RecordingClient) StopClimate(ctx context.Context) (bool, error) {
+	return record(rc, "StopClimate", nil, func() (bool, error) { return rc.client.StopClimate(ctx) })
+}
+
+// SetTemperatures implements Client.
+func (rc *RecordingClient) SetTemperatures(ctx context.Context, driverCelsius, passengerCelsius float64) (bool, error) {
+	args := map[string]any{"driver_celsius": driverCelsius, "passenger_celsius": passengerCelsius}
+	return record(rc, "SetTemperatures", args, func() (bool, error) {
+		return rc.client.SetTemperatures(ctx, driverCelsius, passengerCelsius)
+	})
+}
+
+// SetSeatHeater implements Client.
+func (rc *RecordingClient) SetSeatHeater(ctx context.Context, seat Seat, level HeaterLevel) (bool, error) {
+	args := map[string]any{"seat": seat, "level": level}
+	return record(rc, "SetSeatHeater", args, func() (bool, error) { return rc.client.SetSeatHeater(ctx, seat, level) })
+}
+
+// SetSteeringWheelHeater implements Client.
+func (rc *RecordingClient) SetSteeringWheelHeater(ctx context.Context, on bool) (bool, error) {
+	return record(rc, "SetSteeringWheelHeater", map[string]any{"on": on}, func() (bool, error) {
+		return rc.client.SetSteeringWheelHeater(ctx, on)
+	})
+}
+
+// SetMaxDefrost implements Client.
+func (rc *RecordingClient) SetMaxDefrost(ctx context.Context, on bool) (bool, error) {
+	return record(rc, "SetMaxDefrost", map[string]any{"on": on}, func() (bool, error) { return rc.client.SetMaxDefrost(ctx, on) })
+}
+
+// SetClimateKeeperMode implements Client.
+func (rc *RecordingClient) SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) (bool, error) {
+	return record(rc, "SetClimateKeeperMode", map[string]any{"mode": mode}, func() (bool, error) {
+		return rc.client.SetClimateKeeperMode(ctx, mode)
+	})
+}
+
+// StartCharging implements Client.
+func (rc *RecordingClient) StartCharging(ctx context.Context) (bool, error) {
+	return record(rc, "StartCharging", nil, func() (bool, error) { return rc.client.StartCharging(ctx) })
+}
+
+// StopCharging implements Client.
+func (rc *RecordingClient) StopCharging(ctx context.Context) (bool, error) {
+	return record(rc, "StopCharging", nil, func() (bool, error) { return rc.client.StopCharging(ctx) })
+}
+
+// SetChargeLimit implements Client.
+func (rc *RecordingClient) SetChargeLimit(ctx context.Context, percent int) (bool, error) {
+	return record(rc, "SetChargeLimit", map[string]any{"percent": percent}, func() (bool, error) {
+		return rc.client.SetChargeLimit(ctx, percent)
+	})
+}
+
+// SetChargingAmps implements Client.
+func (rc *RecordingClient) SetChargingAmps(ctx context.Context, amps int) (bool, error) {
+	return record(rc, "SetChargingAmps", map[string]any{"amps": amps}, func() (bool, error) {
+		return rc.client.SetChargingAmps(ctx, amps)
+	})
+}
+
+// OpenChargePort implements Client.
+func (rc *RecordingClient) OpenChargePort(ctx context.Context) (bool, error) {
+	return record(rc, "OpenChargePort", nil, func() (bool, error) { return rc.client.OpenChargePort(ctx) })
+}
+
+// CloseChargePort implements Client.
+func (rc *RecordingClient) CloseChargePort(ctx context.Context) (bool, error) {
+	return record(rc, "CloseChargePort", nil, func() (bool, error) { return rc.client.CloseChargePort(ctx) })
+}
+
+// UnlockChargeCable implements Client.
+func (rc *RecordingClient) UnlockChargeCable(ctx context.Context) (bool, error) {
+	return record(rc, "UnlockChargeCable", nil, func() (bool, error) { return rc.client.UnlockChargeCable(ctx) })
+}
+
+// ScheduleCharging implements Client.
+func (rc *RecordingClient) ScheduleCharging(ctx context.Context, enabled bool, startAfterMidnight time.Duration) (bool, error) {
+	args := map[string]any{"enabled": enabled, "start_after_midnight": startAfterMidnight.String()}
+	return record(rc, "ScheduleCharging", args, func() (bool, error) {
+		return rc.client.ScheduleCharging(ctx, enabled, startAfterMidnight)
+	})
+}
+
+// ActuateFrunk implements Client.
+func (rc *RecordingClient) ActuateFrunk(ctx context.Context) (bool, error) {
+	return record(rc, "ActuateFrunk", nil, func() (bool, error) { return rc.client.ActuateFrunk(ctx) })
+}
+
+// ActuateTrunk implements Client.
+func (rc *RecordingClient) ActuateTrunk(ctx context.Context) (bool, error) {
+	return record(rc, "ActuateTrunk", nil, func() (bool, error) { return rc.client.ActuateTrunk(ctx) })
+}
+
+// VentWindows implements Client.
+func (rc *RecordingClient) VentWindows(ctx context.Context) (bool, error) {
+	return record(rc, "VentWindows", nil, func() (bool, error) { return rc.client.VentWindows(ctx) })
+}
+
+// CloseWindows implements Client.
+func (rc *RecordingClient) CloseWindows(ctx context.Context) (bool, error) {
+	return record(rc, "CloseWindows", nil, func() (bool, error) { return rc.client.CloseWindows(ctx) })
+}
+
+// SetSunroof implements Client.
+func (rc *RecordingClient) SetSunroof(ctx context.Context, percentOpen int) (bool, error) {
+	return record(rc, "SetSunroof", map[string]any{"percent_open": percentOpen}, func() (bool, error) {
+		return rc.client.SetSunroof(ctx, percentOpen)
+	})
+}
+
+// SetTonneau implements Client.
+func (rc *RecordingClient) SetTonneau(ctx context.Context, action TonneauAction) (bool, error) {
+	return record(rc, "SetTonneau", map[string]any{"action": action}, func() (bool, error) {
+		return rc.client.SetTonneau(ctx, action)
+	})
+}
+
+// ListKeys implements Client.
+func (rc *RecordingClient) ListKeys(ctx context.Context) ([]VehicleKey, error) {
+	return record(rc, "ListKeys", nil, func() ([]VehicleKey, error) { return rc.client.ListKeys(ctx) })
+}
+
+// AddKey implements Client.
+func (rc *RecordingClient) AddKey(ctx context.Context, publicKey *ecdh.PublicKey, role KeyRole, formFactor KeyFormFactor) (bool, error) {
+	args := map[string]any{"public_key": keyID(publicKey), "role": role, "form_factor": formFactor}
+	return record(rc, "AddKey", args, func() (bool, error) { return rc.client.AddKey(ctx, publicKey, role, formFactor) })
+}
+
+// RemoveKey implements Client.
+func (rc *RecordingClient) RemoveKey(ctx context.Context, publicKey *ecdh.PublicKey) (bool, error) {
+	return record(rc, "RemoveKey", map[string]any{"public_key": keyID(publicKey)}, func() (bool, error) {
+		return rc.client.RemoveKey(ctx, publicKey)
+	})
+}
+
+// RecordFromEnvironment wraps client in a RecordingClient when TESLA_RECORD_DIR is set. The
+// fixtures go to <dir>/vehicle-<n>.jsonl, n being the vehicle's position in TESLA_VINS, so the
+// file name doesn't give the VIN away either.
+func RecordFromEnvironment(vin string, n int, client Client) (Client, error) {
+	dir := os.Getenv("TESLA_RECORD_DIR")
+	if dir == "" {
+		return client, nil
+	}
+	return NewRecordingClient(vin, client, filepath.Join(dir, fmt.Sprintf("vehicle-%d.jsonl", n)))
+}
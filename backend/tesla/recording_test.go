@@ -0,0 +1,201 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+)
+
+const recordedVIN = "5YJ3E1EA7KF123456"
+
+// dataSourceClient is a MockClient that also serves raw vehicle data, like RealClient.
+type dataSourceClient struct {
+	*MockClient
+	data *carserver.VehicleData
+}
+
+func (c dataSourceClient) GetVehicleData(ctx context.Context, categories ...StateCategory) (*carserver.VehicleData, map[StateCategory]string, error) {
+	return c.data, nil, nil
+}
+
+func recordSession(t *testing.T, client Client) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vehicle-1.jsonl")
+	rc, err := NewRecordingClient(recordedVIN, client, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := rc.GetVehicleStats(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.SetChargeLimit(ctx, 90); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.SetTonneau(ctx, TonneauOpen); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("SetTonneau() error = %v, want ErrNotSupported", err)
+	}
+	if _, err := rc.SetChargeLimit(ctx, 80); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// chargeLimitErrorClient is a MockClient whose SetChargeLimit fails with err.
+type chargeLimitErrorClient struct {
+	*MockClient
+	err error
+}
+
+func (c *chargeLimitErrorClient) SetChargeLimit(ctx context.Context, percent int) (bool, error) {
+	return false, c.err
+}
+
+func TestRecordingClient_ErrorKinds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vehicle-1.jsonl")
+	client := &chargeLimitErrorClient{MockClient: NewMockClient()}
+	rc, err := NewRecordingClient(recordedVIN, client, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []struct {
+		kind     string
+		sentinel error
+	}{
+		{"asleep", ErrVehicleAsleep},
+		{"offline", ErrVehicleOffline},
+		{"timeout", ErrTimeout},
+		{"unauthorized", ErrUnauthorized},
+		{"not_supported", ErrNotSupported},
+		{"not_connected", ErrVehicleNotConnected},
+		{"rate_limited", ErrRateLimited},
+		{"key_not_found", ErrKeyNotFound},
+		{"unknown_vehicle", ErrUnknownVehicle},
+	}
+	for _, k := range kinds {
+		client.err = fmt.Errorf("setting charge limit: %w", k.sentinel)
+		rc.SetChargeLimit(context.Background(), 80)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	interactions, err := LoadFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := NewReplayClient(MockVIN, interactions, ReplayInOrder)
+	for i, k := range kinds {
+		if got := interactions[i].Error; got == nil || got.Kind != k.kind {
+			t.Errorf("recorded error %+v, want kind %q", got, k.kind)
+		}
+		if _, err := rp.SetChargeLimit(context.Background(), 80); !errors.Is(err, k.sentinel) {
+			t.Errorf("replayed %s error = %v, want it to wrap %v", k.kind, err, k.sentinel)
+		}
+	}
+}
+
+func TestRecordingClient_ScrubsFixtures(t *testing.T) {
+	client := dataSourceClient{MockClient: NewMockClient(), data: &carserver.VehicleData{
+		ChargeState: &carserver.ChargeState{
+			OptionalBatteryLevel: &carserver.ChargeState_BatteryLevel{BatteryLevel: 64},
+		},
+		LocationState: &carserver.LocationState{
+			OptionalLatitude:     &carserver.LocationState_Latitude{Latitude: 51.5007},
+			OptionalLongitude:    &carserver.LocationState_Longitude{Longitude: -0.1246},
+			OptionalLocationName: &carserver.LocationState_LocationName{LocationName: "Home"},
+		},
+	}}
+	path := recordSession(t, client)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{recordedVIN, "51.5", "-0.12", "Home"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("fixture contains %q:\n%s", secret, raw)
+		}
+	}
+
+	interactions, err := LoadFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interactions) != 4 || len(interactions[0].VehicleData) == 0 || interactions[2].Error.Kind != "not_supported" {
+		t.Fatalf("recorded interactions = %+v", interactions)
+	}
+
+	// Raw vehicle data is converted again on replay. The SDK stores coordinates as float32.
+	state, err := NewReplayClient("VIN", interactions, ReplayByMatch).GetVehicleStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.VIN != "VIN" || *state.Charge.BatteryLevelPercent != 64 || *state.Location.Latitude != float64(float32(scrubbedLatitude)) ||
+		state.Location.LocationName != nil {
+		t.Errorf("replayed state = %+v, location %+v", state, state.Location)
+	}
+}
+
+func TestReplayClient_InOrder(t *testing.T) {
+	interactions, err := LoadFixtures(recordSession(t, NewMockClient()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := NewReplayClient(MockVIN, interactions, ReplayInOrder)
+	ctx := context.Background()
+
+	if _, err := rp.SetChargeLimit(ctx, 90); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("out-of-order call: error = %v, want ErrNoFixture", err)
+	}
+	state, err := rp.GetVehicleStats(ctx)
+	if err != nil || *state.Charge.ChargeLimitPercent != 80 || time.Since(state.FetchedAt) > time.Minute {
+		t.Fatalf("GetVehicleStats() = %+v, %v", state, err)
+	}
+	if ok, err := rp.SetChargeLimit(ctx, 90); !ok || err != nil {
+		t.Errorf("SetChargeLimit(90) = %v, %v", ok, err)
+	}
+	if _, err := rp.SetTonneau(ctx, TonneauOpen); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("SetTonneau() error = %v, want recorded ErrNotSupported", err)
+	}
+	if _, err := rp.SetChargeLimit(ctx, 80); err != nil {
+		t.Error(err)
+	}
+	if _, err := rp.GetVehicleStats(ctx); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("call after the last recording: error = %v, want ErrNoFixture", err)
+	}
+}
+
+func TestReplayClient_ByMatch(t *testing.T) {
+	interactions, err := LoadFixtures(recordSession(t, NewMockClient()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := NewReplayClient(MockVIN, interactions, ReplayByMatch)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := rp.SetChargeLimit(ctx, 80); err != nil {
+			t.Errorf("SetChargeLimit(80) = %v", err)
+		}
+		if _, err := rp.GetVehicleStats(ctx); err != nil {
+			t.Errorf("GetVehicleStats() = %v", err)
+		}
+	}
+	if _, err := rp.SetChargeLimit(ctx, 70); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("unrecorded arguments: error = %v, want ErrNoFixture", err)
+	}
+	if _, err := rp.LockVehicle(ctx); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("unrecorded operation: error = %v, want ErrNoFixture", err)
+	}
+}
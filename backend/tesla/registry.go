@@ -0,0 +1,174 @@
+package tesla
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownVehicle is returned by Registry lookups for a VIN that was never registered.
+var ErrUnknownVehicle = errors.New("unknown vehicle")
+
+// ErrVehicleNotConnected is returned for a registered vehicle whose client is not available:
+// a SupervisedClient that has not connected yet or whose last attempt failed, or any client
+// after Registry.Close.
+var ErrVehicleNotConnected = errors.New("vehicle not connected")
+
+// VehicleStatus reports the connection state of one registered vehicle.
+type VehicleStatus struct {
+	VIN         string          `json:"vin"`
+	State       ConnectionState `json:"state,omitempty"` // Set for self-healing clients such as SupervisedClient.
+	Connected   bool            `json:"connected"`
+	ConnectedAt *time.Time      `json:"connected_at"`
+	Error       string          `json:"error,omitempty"`
+}
+
+type registryEntry struct {
+	client Client
+	status VehicleStatus
+}
+
+// Registry holds one Client per VIN. Real vehicles are registered as SupervisedClients, which
+// connect independently, so a car that is out of reach does not prevent the others from being
+// served.
+type Registry struct {
+	mu       sync.RWMutex
+	vehicles map[string]*registryEntry
+	order    []string // Registration order; the first VIN is the default vehicle.
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{vehicles: make(map[string]*registryEntry)}
+}
+
+// VINsFromEnvironment returns the VINs listed in the comma-separated TESLA_VINS variable, falling
+// back to the single TESLA_VIN. Blank entries and duplicates are dropped.
+func VINsFromEnvironment() []string {
+	raw := os.Getenv("TESLA_VINS")
+	if raw == "" {
+		raw = os.Getenv("TESLA_VIN")
+	}
+	var vins []string
+	seen := make(map[string]bool)
+	for _, vin := range strings.Split(raw, ",") {
+		vin = strings.ToUpper(strings.TrimSpace(vin))
+		if vin == "" || seen[vin] {
+			continue
+		}
+		seen[vin] = true
+		vins = append(vins, vin)
+	}
+	return vins
+}
+
+// Set registers vin with client: a SupervisedClient that connects itself, or a client that needs
+// no connection, e.g. a MockClient. Setting a VIN again replaces its client.
+func (r *Registry) Set(vin string, client Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.reset(vin)
+	now := time.Now()
+	e.client = client
+	e.status.Connected = true
+	e.status.ConnectedAt = &now
+}
+
+// reset installs a fresh entry for vin, keeping its position if it was already registered.
+// r.mu must be held for writing.
+func (r *Registry) reset(vin string) *registryEntry {
+	vin = strings.ToUpper(vin)
+	if _, ok := r.vehicles[vin]; !ok {
+		r.order = append(r.order, vin)
+	}
+	e := &registryEntry{status: VehicleStatus{VIN: vin}}
+	r.vehicles[vin] = e
+	return e
+}
+
+// Client returns the client for vin. It wraps ErrUnknownVehicle or, after Close,
+// ErrVehicleNotConnected when none is available.
+func (r *Registry) Client(vin string) (Client, error) {
+	vin = strings.ToUpper(vin)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.vehicles[vin]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVehicle, vin)
+	}
+	if e.client == nil {
+		return nil, fmt.Errorf("%w: %s", ErrVehicleNotConnected, vin)
+	}
+	return e.client, nil
+}
+
+// DefaultVIN returns the first registered VIN, or "" if the registry is empty.
+func (r *Registry) DefaultVIN() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.order) == 0 {
+		return ""
+	}
+	return r.order[0]
+}
+
+// VINs returns the registered VINs in registration order.
+func (r *Registry) VINs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+// connectionStater is implemented by clients that track their own connection, like SupervisedClient.
+type connectionStater interface {
+	ConnectionState() (ConnectionState, error)
+}
+
+// List returns the status of every registered vehicle in registration order. For clients that
+// track their own connection the status reflects that connection rather than the registration.
+func (r *Registry) List() []VehicleStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	statuses := make([]VehicleStatus, 0, len(r.order))
+	for _, vin := range r.order {
+		e := r.vehicles[vin]
+		status := e.status
+		if stater, ok := e.client.(connectionStater); ok {
+			state, err := stater.ConnectionState()
+			status.State = state
+			status.Connected = state == StateOnline || state == StateAsleep
+			if !status.Connected {
+				status.ConnectedAt = nil
+			}
+			if err != nil {
+				status.Error = err.Error()
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Close closes every connected client that implements io.Closer, such as RealClient, and marks
+// the vehicles disconnected. It returns the per-VIN errors of the clients that failed to close.
+func (r *Registry) Close() map[string]error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failures := make(map[string]error)
+	for _, vin := range r.order {
+		e := r.vehicles[vin]
+		if closer, ok := e.client.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				failures[vin] = err
+			}
+		}
+		e.client = nil
+		e.status.Connected = false
+		e.status.ConnectedAt = nil
+	}
+	return failures
+}
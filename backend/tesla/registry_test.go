@@ -0,0 +1,97 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVINsFromEnvironment(t *testing.T) {
+	t.Setenv("TESLA_VIN", "FALLBACKVIN")
+	t.Setenv("TESLA_VINS", " vin1, VIN2 ,,vin1")
+	if got, want := VINsFromEnvironment(), []string{"VIN1", "VIN2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VINsFromEnvironment() = %v, want %v", got, want)
+	}
+
+	t.Setenv("TESLA_VINS", "")
+	if got, want := VINsFromEnvironment(), []string{"FALLBACKVIN"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VINsFromEnvironment() without TESLA_VINS = %v, want %v", got, want)
+	}
+}
+
+func TestRegistry_IndependentConnections(t *testing.T) {
+	reg := NewRegistry()
+	mock := NewMockClient()
+	connectErr := errors.New("vehicle unreachable")
+	unreachable := NewSupervisedClient("VIN2", func(context.Context) (Client, error) { return nil, connectErr }, testSupervisorOptions())
+	defer unreachable.Close()
+	reg.Set("VIN1", mock)
+	reg.Set("VIN2", unreachable)
+	for _, err := unreachable.ConnectionState(); err == nil; _, err = unreachable.ConnectionState() {
+		time.Sleep(time.Millisecond) // The first attempt.
+	}
+
+	if client, err := reg.Client("vin1"); err != nil || client != mock {
+		t.Errorf("Client(VIN1) = %v, %v; want the mock client", client, err)
+	}
+	client, err := reg.Client("VIN2")
+	if err != nil {
+		t.Fatalf("Client(VIN2) = %v, want the supervised client", err)
+	}
+	if _, err := client.LockVehicle(context.Background()); !errors.Is(err, ErrVehicleNotConnected) || !errors.Is(err, connectErr) {
+		t.Errorf("LockVehicle() on VIN2 = %v, want ErrVehicleNotConnected with the connection error", err)
+	}
+	if _, err := reg.Client("VIN3"); !errors.Is(err, ErrUnknownVehicle) {
+		t.Errorf("Client(VIN3) error = %v, want ErrUnknownVehicle", err)
+	}
+
+	statuses := reg.List()
+	if len(statuses) != 2 || statuses[0].VIN != "VIN1" || statuses[1].VIN != "VIN2" {
+		t.Fatalf("List() = %+v, want VIN1 then VIN2", statuses)
+	}
+	if !statuses[0].Connected || statuses[0].ConnectedAt == nil || statuses[0].Error != "" {
+		t.Errorf("VIN1 status = %+v, want connected", statuses[0])
+	}
+	if statuses[1].Connected || statuses[1].State != StateConnecting || statuses[1].Error != connectErr.Error() {
+		t.Errorf("VIN2 status = %+v, want connecting with the connection error", statuses[1])
+	}
+	if got := reg.DefaultVIN(); got != "VIN1" {
+		t.Errorf("DefaultVIN() = %q, want VIN1", got)
+	}
+
+	// Setting a VIN again replaces its client only.
+	reg.Set("VIN2", mock)
+	if statuses := reg.List(); !statuses[1].Connected || statuses[1].Error != "" || statuses[0].VIN != "VIN1" {
+		t.Errorf("statuses after replacing VIN2 = %+v, want VIN2 connected in place", statuses)
+	}
+}
+
+// closingClient records whether Close was called.
+type closingClient struct {
+	*MockClient
+	closed bool
+}
+
+func (c *closingClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRegistry_Close(t *testing.T) {
+	r := NewRegistry()
+	closer := &closingClient{MockClient: NewMockClient()}
+	r.Set("VIN1", closer)
+	r.Set("VIN2", NewMockClient()) // Not an io.Closer.
+
+	if failures := r.Close(); len(failures) != 0 {
+		t.Errorf("Close() failures = %v, want none", failures)
+	}
+	if !closer.closed {
+		t.Error("Close() did not close the client")
+	}
+	if _, err := r.Client("VIN1"); !errors.Is(err, ErrVehicleNotConnected) {
+		t.Errorf("Client() after Close = %v, want ErrVehicleNotConnected", err)
+	}
+}
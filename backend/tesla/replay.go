@@ -0,0 +1,351 @@
+package tesla
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ErrNoFixture is returned by ReplayClient for a call it has no recording of.
+var ErrNoFixture = errors.New("no matching fixture")
+
+// ReplayMode selects how a ReplayClient picks the recording that answers a call.
+type ReplayMode string
+
+const (
+	// ReplayInOrder serves the recordings one after the other; each call must be the next
+	// recorded operation with the same arguments.
+	ReplayInOrder ReplayMode = "order"
+	// ReplayByMatch answers each call with a recording of the same operation and arguments,
+	// cycling through them when there are several, so the fixtures never run out.
+	ReplayByMatch ReplayMode = "match"
+)
+
+// ReplayClient is a Client that serves recorded fixtures, as written by RecordingClient, instead
+// of talking to a vehicle.
+type ReplayClient struct {
+	vin  string
+	mode ReplayMode
+
+	mu           sync.Mutex
+	interactions []Interaction
+	next         int            // ReplayInOrder: index of the next recording.
+	served       map[string]int // ReplayByMatch: calls answered so far per operation and arguments.
+}
+
+// LoadFixtures reads a fixture file of JSON lines, one Interaction each.
+func LoadFixtures(path string) ([]Interaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixtures: %w", err)
+	}
+	defer file.Close()
+
+	var interactions []Interaction
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20) // Vehicle data lines are long.
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var in Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("reading fixtures: %s:%d: %w", path, line, err)
+		}
+		interactions = append(interactions, in)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading fixtures: %w", err)
+	}
+	return interactions, nil
+}
+
+// NewReplayClient serves interactions as the vehicle identified by vin.
+func NewReplayClient(vin string, interactions []Interaction, mode ReplayMode) *ReplayClient {
+	return &ReplayClient{vin: vin, mode: mode, interactions: interactions, served: make(map[string]int)}
+}
+
+// ReplayClientFromEnvironment loads the fixtures named by TESLA_REPLAY_FIXTURES, replayed as
+// TESLA_REPLAY_MODE ("match", the default, or "order") under MockVIN. It returns nil when
+// TESLA_REPLAY_FIXTURES is not set.
+func ReplayClientFromEnvironment() (*ReplayClient, error) {
+	path := os.Getenv("TESLA_REPLAY_FIXTURES")
+	if path == "" {
+		return nil, nil
+	}
+	mode := ReplayMode(os.Getenv("TESLA_REPLAY_MODE"))
+	switch mode {
+	case "":
+		mode = ReplayByMatch
+	case ReplayByMatch, ReplayInOrder:
+	default:
+		return nil, fmt.Errorf("invalid TESLA_REPLAY_MODE %q", mode)
+	}
+	interactions, err := LoadFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayClient(MockVIN, interactions, mode), nil
+}
+
+// canonicalArgs returns args in a form that compares equal for equal arguments however they
+// were encoded.
+func canonicalArgs(args json.RawMessage) string {
+	if len(args) == 0 {
+		return ""
+	}
+	var v any
+	if err := json.Unmarshal(args, &v); err != nil {
+		return string(args)
+	}
+	return string(mustMarshal(v))
+}
+
+// find returns the recording that answers the call to op with args.
+func (rp *ReplayClient) find(op string, args map[string]any) (Interaction, error) {
+	var want string
+	if args != nil {
+		want = canonicalArgs(mustMarshal(args))
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.mode == ReplayInOrder {
+		if rp.next >= len(rp.interactions) {
+			return Interaction{}, fmt.Errorf("%s: %w: all %d recordings used", op, ErrNoFixture, len(rp.interactions))
+		}
+		in := rp.interactions[rp.next]
+		if in.Operation != op || canonicalArgs(in.Args) != want {
+			return Interaction{}, fmt.Errorf("%s %s: %w: recording %d is %s %s", op, want, ErrNoFixture, rp.next+1, in.Operation, in.Args)
+		}
+		rp.next++
+		return in, nil
+	}
+
+	var matches []Interaction
+	for _, in := range rp.interactions {
+		if in.Operation == op && canonicalArgs(in.Args) == want {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		return Interaction{}, fmt.Errorf("%s %s: %w", op, want, ErrNoFixture)
+	}
+	key := op + want
+	in := matches[rp.served[key]%len(matches)]
+	rp.served[key]++
+	return in, nil
+}
+
+// replay answers the call to op with its recorded result or error.
+func replay[T any](ctx context.Context, rp *ReplayClient, op string, args map[string]any) (T, error) {
+	var v T
+	if err := ctx.Err(); err != nil {
+		return v, contextError(ctx, op, err)
+	}
+	in, err := rp.find(op, args)
+	if err != nil {
+		return v, err
+	}
+	if in.Error != nil {
+		return v, in.Error.Err()
+	}
+	if err := json.Unmarshal(in.Result, &v); err != nil {
+		return v, fmt.Errorf("%s: decoding recorded result: %w", op, err)
+	}
+	return v, nil
+}
+
+// GetVehicleStats implements Client. Recorded raw vehicle data is converted afresh, so the replay
+// exercises the same code as a live vehicle. Either way the state is reported as fetched now. In
+// match mode a call for some categories falls back to a recording of all of them.
+func (rp *ReplayClient) GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(ctx, "GetVehicleStats", err)
+	}
+	args := categoryArgs(categories)
+	in, err := rp.find("GetVehicleStats", args)
+	if errors.Is(err, ErrNoFixture) && args != nil && rp.mode == ReplayByMatch {
+		in, err = rp.find("GetVehicleStats", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if in.Error != nil {
+		return nil, in.Error.Err()
+	}
+
+	state := new(VehicleState)
+	if err := json.Unmarshal(in.Result, state); err != nil {
+		return nil, fmt.Errorf("GetVehicleStats: decoding recorded result: %w", err)
+	}
+	if len(in.VehicleData) > 0 {
+		data := new(carserver.VehicleData)
+		if err := protojson.Unmarshal(in.VehicleData, data); err != nil {
+			return nil, fmt.Errorf("GetVehicleStats: decoding recorded vehicle data: %w", err)
+		}
+		failed := state.Errors
+		state = newVehicleState(rp.vin, data, time.Now())
+		state.Errors = failed
+	}
+	state.VIN, state.FetchedAt = rp.vin, time.Now().UTC()
+	state.keepOnly(categories)
+	return state, nil
+}
+
+// LockVehicle implements Client.
+func (rp *ReplayClient) LockVehicle(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "LockVehicle", nil)
+}
+
+// UnlockVehicle implements Client.
+func (rp *ReplayClient) UnlockVehicle(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "UnlockVehicle", nil)
+}
+
+// GetCameraFeed implements Client.
+func (rp *ReplayClient) GetCameraFeed(ctx context.Context) (string, error) {
+	return replay[string](ctx, rp, "GetCameraFeed", nil)
+}
+
+// Wake implements Client.
+func (rp *ReplayClient) Wake(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "Wake", nil)
+}
+
+// IsOnline implements Client.
+func (rp *ReplayClient) IsOnline(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "IsOnline", nil)
+}
+
+// StartClimate implements Client.
+func (rp *ReplayClient) StartClimate(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "StartClimate", nil)
+}
+
+// StopClimate implements Client.
+func (rp *ReplayClient) StopClimate(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "StopClimate", nil)
+}
+
+// SetTemperatures implements Client.
+func (rp *ReplayClient) SetTemperatures(ctx context.Context, driverCelsius, passengerCelsius float64) (bool, error) {
+	args := map[string]any{"driver_celsius": driverCelsius, "passenger_celsius": passengerCelsius}
+	return replay[bool](ctx, rp, "SetTemperatures", args)
+}
+
+// SetSeatHeater implements Client.
+func (rp *ReplayClient) SetSeatHeater(ctx context.Context, seat Seat, level HeaterLevel) (bool, error) {
+	return replay[bool](ctx, rp, "SetSeatHeater", map[string]any{"seat": seat, "level": level})
+}
+
+// SetSteeringWheelHeater implements Client.
+func (rp *ReplayClient) SetSteeringWheelHeater(ctx context.Context, on bool) (bool, error) {
+	return replay[bool](ctx, rp, "SetSteeringWheelHeater", map[string]any{"on": on})
+}
+
+// SetMaxDefrost implements Client.
+func (rp *ReplayClient) SetMaxDefrost(ctx context.Context, on bool) (bool, error) {
+	return replay[bool](ctx, rp, "SetMaxDefrost", map[string]any{"on": on})
+}
+
+// SetClimateKeeperMode implements Client.
+func (rp *ReplayClient) SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) (bool, error) {
+	return replay[bool](ctx, rp, "SetClimateKeeperMode", map[string]any{"mode": mode})
+}
+
+// StartCharging implements Client.
+func (rp *ReplayClient) StartCharging(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "StartCharging", nil)
+}
+
+// StopCharging implements Client.
+func (rp *ReplayClient) StopCharging(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "StopCharging", nil)
+}
+
+// SetChargeLimit implements Client.
+func (rp *ReplayClient) SetChargeLimit(ctx context.Context, percent int) (bool, error) {
+	return replay[bool](ctx, rp, "SetChargeLimit", map[string]any{"percent": percent})
+}
+
+// SetChargingAmps implements Client.
+func (rp *ReplayClient) SetChargingAmps(ctx context.Context, amps int) (bool, error) {
+	return replay[bool](ctx, rp, "SetChargingAmps", map[string]any{"amps": amps})
+}
+
+// OpenChargePort implements Client.
+func (rp *ReplayClient) OpenChargePort(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "OpenChargePort", nil)
+}
+
+// CloseChargePort implements Client.
+func (rp *ReplayClient) CloseChargePort(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "CloseChargePort", nil)
+}
+
+// UnlockChargeCable implements Client.
+func (rp *ReplayClient) UnlockChargeCable(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "UnlockChargeCable", nil)
+}
+
+// ScheduleCharging implements Client.
+func (rp *ReplayClient) ScheduleCharging(ctx context.Context, enabled bool, startAfterMidnight time.Duration) (bool, error) {
+	args := map[string]any{"enabled": enabled, "start_after_midnight": startAfterMidnight.String()}
+	return replay[bool](ctx, rp, "ScheduleCharging", args)
+}
+
+// ActuateFrunk implements Client.
+func (rp *ReplayClient) ActuateFrunk(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "ActuateFrunk", nil)
+}
+
+// ActuateTrunk implements Client.
+func (rp *ReplayClient) ActuateTrunk(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "ActuateTrunk", nil)
+}
+
+// VentWindows implements Client.
+func (rp *ReplayClient) VentWindows(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "VentWindows", nil)
+}
+
+// CloseWindows implements Client.
+func (rp *ReplayClient) CloseWindows(ctx context.Context) (bool, error) {
+	return replay[bool](ctx, rp, "CloseWindows", nil)
+}
+
+// SetSunroof implements Client.
+func (rp *ReplayClient) SetSunroof(ctx context.Context, percentOpen int) (bool, error) {
+	return replay[bool](ctx, rp, "SetSunroof", map[string]any{"percent_open": percentOpen})
+}
+
+// SetTonneau implements Client.
+func (rp *ReplayClient) SetTonneau(ctx context.Context, action TonneauAction) (bool, error) {
+	return replay[bool](ctx, rp, "SetTonneau", map[string]any{"action": action})
+}
+
+// ListKeys implements Client.
+func (rp *ReplayClient) ListKeys(ctx context.Context) ([]VehicleKey, error) {
+	return replay[[]VehicleKey](ctx, rp, "ListKeys", nil)
+}
+
+// AddKey implements Client.
+func (rp *ReplayClient) AddKey(ctx context.Context, publicKey *ecdh.PublicKey, role KeyRole, formFactor KeyFormFactor) (bool, error) {
+	return replay[bool](ctx, rp, "AddKey", map[string]any{"public_key": keyID(publicKey), "role": role, "form_factor": formFactor})
+}
+
+// RemoveKey implements Client.
+func (rp *ReplayClient) RemoveKey(ctx context.Context, publicKey *ecdh.PublicKey) (bool, error) {
+	return replay[bool](ctx, rp, "RemoveKey", map[string]any{"public_key": keyID(publicKey)})
+}
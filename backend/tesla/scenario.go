@@ -0,0 +1,201 @@
+package tesla
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario scripts the simulator: a starting state and a timeline of actions.
+//
+// Initial uses the VehicleState JSON shape and only needs the fields that differ from the mock's
+// defaults. Each step runs After its predecessor (or the scenario start) on the simulated clock:
+//
+//	name: evening-commute
+//	initial:
+//	  charge: {battery_level_percent: 40}
+//	  security: {locked: false}
+//	steps:
+//	  - {action: unplug}
+//	  - {after: 1m, action: drive, speed_mph: 35, heading_degrees: 90}
+//	  - {after: 30m, action: park}
+type Scenario struct {
+	Name    string          `json:"name"`
+	Initial json.RawMessage `json:"initial,omitempty"`
+	Steps   []ScenarioStep  `json:"steps"`
+}
+
+// ScenarioStep is one timed SimAction in a Scenario.
+type ScenarioStep struct {
+	After Duration `json:"after,omitempty"`
+	SimAction
+}
+
+// Duration is a time.Duration that reads from JSON as a string like "90s" or a number of seconds.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\" or a number of seconds: %s", data)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+type scheduledStep struct {
+	at     time.Time
+	action SimAction
+}
+
+// ParseScenario decodes a scenario written in JSON or, if yamlFormat is set, YAML.
+func ParseScenario(data []byte, yamlFormat bool) (*Scenario, error) {
+	if yamlFormat {
+		// YAML is mapped onto the JSON shape so both formats share the json tags.
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parsing scenario: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("parsing scenario: %w", err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	sc := new(Scenario)
+	if err := dec.Decode(sc); err != nil {
+		return nil, fmt.Errorf("parsing scenario: %w", err)
+	}
+	return sc, nil
+}
+
+// LoadScenarioFile reads a scenario from a .json, .yaml or .yml file.
+func LoadScenarioFile(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return ParseScenario(data, false)
+	case ".yaml", ".yml":
+		return ParseScenario(data, true)
+	default:
+		return nil, fmt.Errorf("reading scenario: unsupported file extension %q", ext)
+	}
+}
+
+// LoadScenario resets the simulated vehicle to the scenario's initial state and schedules its
+// steps starting from the current simulated time.
+func (mc *MockClient) LoadScenario(sc *Scenario) error {
+	state := newMockVehicleState()
+	if len(sc.Initial) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(sc.Initial))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(state); err != nil {
+			return fmt.Errorf("scenario %q: initial state: %w", sc.Name, err)
+		}
+	}
+	// A scenario that nulls a field the model can't run without is rejected rather than loaded.
+	if err := validateSimState(state); err != nil {
+		return fmt.Errorf("scenario %q: initial state: %w", sc.Name, err)
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.reset(state)
+	at := mc.simulatedAt
+	for _, step := range sc.Steps {
+		at = at.Add(time.Duration(step.After))
+		mc.pending = append(mc.pending, scheduledStep{at: at, action: step.SimAction})
+	}
+	mc.advance() // Steps without a delay run right away.
+	return nil
+}
+
+// validateSimState checks that the values the simulator and the mock's commands dereference are
+// all present.
+func validateSimState(s *VehicleState) error {
+	if s.Charge == nil || s.Climate == nil || s.Drive == nil || s.Closures == nil || s.Location == nil || s.Security == nil {
+		return fmt.Errorf("every category must be present")
+	}
+	required := map[string]bool{
+		"charge.battery_level_percent":        s.Charge.BatteryLevelPercent != nil,
+		"charge.charge_limit_percent":         s.Charge.ChargeLimitPercent != nil,
+		"charge.charging_state":               s.Charge.ChargingState != nil,
+		"charge.charging_amps_requested":      s.Charge.ChargingAmpsRequested != nil,
+		"charge.energy_added_kwh":             s.Charge.EnergyAddedKWh != nil,
+		"charge.charger_power_kw":             s.Charge.ChargerPowerKW != nil,
+		"climate.inside_temp_celsius":         s.Climate.InsideTempCelsius != nil,
+		"climate.outside_temp_celsius":        s.Climate.OutsideTempCelsius != nil,
+		"climate.driver_temp_setting_celsius": s.Climate.DriverTempSettingCelsius != nil,
+		"climate.is_climate_on":               s.Climate.IsClimateOn != nil,
+		"drive.shift_state":                   s.Drive.ShiftState != nil,
+		"drive.speed_mph":                     s.Drive.SpeedMPH != nil,
+		"drive.odometer_miles":                s.Drive.OdometerMiles != nil,
+		"closures.trunk_open":                 s.Closures.TrunkOpen != nil,
+		"location.latitude":                   s.Location.Latitude != nil,
+		"location.longitude":                  s.Location.Longitude != nil,
+		"location.heading_degrees":            s.Location.HeadingDegrees != nil,
+		"security.locked":                     s.Security.Locked != nil,
+	}
+	for field, ok := range required {
+		if !ok {
+			return fmt.Errorf("%s must not be null", field)
+		}
+	}
+	return nil
+}
+
+// MockClientFromEnvironment creates the MockClient used by the dev routes. TESLA_MOCK_TIME_SCALE
+// speeds up (or, at 0, freezes) the simulated clock, and TESLA_MOCK_SCENARIO names a scenario
+// file to load at start. Invalid settings are logged and ignored.
+func MockClientFromEnvironment() *MockClient {
+	scale := 1.0
+	if value := os.Getenv("TESLA_MOCK_TIME_SCALE"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			log.Printf("Ignoring invalid TESLA_MOCK_TIME_SCALE=%q; using %g", value, scale)
+		} else {
+			scale = parsed
+		}
+	}
+	mc := NewMockClientWithClock(NewSimClock(time.Now(), scale))
+
+	if path := os.Getenv("TESLA_MOCK_SCENARIO"); path != "" {
+		sc, err := LoadScenarioFile(path)
+		if err == nil {
+			err = mc.LoadScenario(sc)
+		}
+		if err != nil {
+			log.Printf("Ignoring TESLA_MOCK_SCENARIO=%q: %v", path, err)
+		} else {
+			log.Printf("MockClient: loaded scenario %q from %s", sc.Name, path)
+		}
+	}
+	return mc
+}
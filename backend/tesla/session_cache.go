@@ -0,0 +1,98 @@
+package tesla
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/cache"
+)
+
+// DefaultSessionCacheInterval is how often a RealClient persists its session state when
+// TESLA_CACHE_SAVE_INTERVAL is not set.
+const DefaultSessionCacheInterval = 5 * time.Minute
+
+// SessionCacheIntervalFromEnvironment returns TESLA_CACHE_SAVE_INTERVAL, or
+// DefaultSessionCacheInterval if it is unset or invalid.
+func SessionCacheIntervalFromEnvironment() time.Duration {
+	interval := DefaultSessionCacheInterval
+	readDurationEnv("TESLA_CACHE_SAVE_INTERVAL", &interval)
+	return interval
+}
+
+// sessionCacheMu serialises access to session cache files. Every RealClient in the process
+// shares TESLA_CACHE_FILE, one entry per VIN.
+var sessionCacheMu sync.Mutex
+
+// recoverSessionCache moves an unreadable cache file aside so the SDK starts with an empty cache
+// instead of refusing to connect. The bad file is kept as <filename>.corrupt for inspection.
+func recoverSessionCache(filename string) error {
+	if filename == "" {
+		return nil
+	}
+	sessionCacheMu.Lock()
+	defer sessionCacheMu.Unlock()
+	_, err := readSessionCache(filename)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	log.Printf("Session cache %s is unreadable (%v); starting with an empty cache", filename, err)
+	if err := os.Rename(filename, filename+".corrupt"); err != nil {
+		return fmt.Errorf("moving aside corrupt session cache: %w", err)
+	}
+	return nil
+}
+
+// readSessionCache loads the cache stored in filename.
+func readSessionCache(filename string) (*cache.SessionCache, error) {
+	sessions, err := cache.ImportFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if sessions.Vehicles == nil {
+		return cache.New(0), nil
+	}
+	return sessions, nil
+}
+
+// updateSessionCache applies update to the cache stored in filename and writes it back. Entries
+// written by other clients are preserved, and the file is replaced atomically so a crash mid-write
+// cannot corrupt it.
+func updateSessionCache(filename string, update func(*cache.SessionCache) error) error {
+	sessionCacheMu.Lock()
+	defer sessionCacheMu.Unlock()
+
+	sessions, err := readSessionCache(filename)
+	if err != nil {
+		sessions = cache.New(0)
+	}
+	if err := update(sessions); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing session cache: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+	if err := sessions.Export(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing session cache: %w", err)
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing session cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing session cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("writing session cache: %w", err)
+	}
+	return nil
+}
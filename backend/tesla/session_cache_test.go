@@ -0,0 +1,59 @@
+package tesla
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/teslamotors/vehicle-command/pkg/cache"
+)
+
+func TestRecoverSessionCache(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "cache.json")
+
+	if err := recoverSessionCache(filename); err != nil {
+		t.Fatalf("recoverSessionCache() on a missing file = %v, want nil", err)
+	}
+
+	if err := os.WriteFile(filename, []byte(`{"vehicles": {"5YJ`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := recoverSessionCache(filename); err != nil {
+		t.Fatalf("recoverSessionCache() on a corrupt file = %v, want nil", err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("corrupt cache file still in place: %v", err)
+	}
+	if _, err := os.Stat(filename + ".corrupt"); err != nil {
+		t.Errorf("corrupt cache file not kept for inspection: %v", err)
+	}
+}
+
+func TestUpdateSessionCache_PreservesOtherVehicles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.json")
+	for _, vin := range []string{"VIN1", "VIN2"} {
+		err := updateSessionCache(filename, func(c *cache.SessionCache) error {
+			return c.Update(vin, nil)
+		})
+		if err != nil {
+			t.Fatalf("updateSessionCache(%s) = %v", vin, err)
+		}
+	}
+
+	sessions, err := readSessionCache(filename)
+	if err != nil {
+		t.Fatalf("reading saved cache: %v", err)
+	}
+	for _, vin := range []string{"VIN1", "VIN2"} {
+		if _, ok := sessions.GetEntry(vin); !ok {
+			t.Errorf("cache lost the entry for %s: %v", vin, sessions.Vehicles)
+		}
+	}
+	if err := recoverSessionCache(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Errorf("valid cache file was moved aside: %v", err)
+	}
+}
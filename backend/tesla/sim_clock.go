@@ -0,0 +1,45 @@
+package tesla
+
+import (
+	"sync"
+	"time"
+)
+
+// SimClock is the controllable clock driving the vehicle simulator. Simulated time runs at scale
+// times wall-clock speed (0 freezes it) and can be moved forward explicitly with Advance.
+type SimClock struct {
+	mu        sync.Mutex
+	scale     float64
+	realStart time.Time
+	simStart  time.Time
+	offset    time.Duration
+	realNow   func() time.Time
+}
+
+// NewSimClock returns a clock that reads start now and then runs at scale times real time.
+func NewSimClock(start time.Time, scale float64) *SimClock {
+	return &SimClock{scale: scale, realStart: time.Now(), simStart: start, realNow: time.Now}
+}
+
+// NewManualClock returns a frozen clock that only moves when Advance is called, for tests.
+func NewManualClock(start time.Time) *SimClock {
+	return NewSimClock(start, 0)
+}
+
+// Now returns the current simulated time.
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed := time.Duration(float64(c.realNow().Sub(c.realStart)) * c.scale)
+	return c.simStart.Add(elapsed + c.offset)
+}
+
+// Advance moves simulated time forward by d.
+func (c *SimClock) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset += d
+}
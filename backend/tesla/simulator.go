@@ -0,0 +1,431 @@
+package tesla
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// Physical model of the simulated vehicle. The numbers are loosely based on a Model 3 Long Range.
+const (
+	simPackKWh           = 75.0 // Usable battery capacity.
+	simKWhPerMile        = 0.25 // Consumption while driving; also defines rated range.
+	simClimateKW         = 2.0  // HVAC draw while the climate is on.
+	simEstimatedRange    = 0.92 // Estimated range as a fraction of rated range.
+	simCabinHVACRate     = 1.0  // °C per minute the cabin approaches the setting with HVAC on.
+	simCabinDriftRate    = 0.05 // °C per minute the cabin approaches the outside temperature otherwise.
+	milesPerDegreeLatLon = 69.0 // Miles per degree of latitude (and of longitude at the equator).
+	simTireSoftWarning   = 0.8  // Fraction of the recommended pressure below which TPMS warns.
+	simTireHardWarning   = 0.6  // Fraction below which the warning becomes severe.
+)
+
+// Door identifies one of the vehicle's doors in simulator actions.
+type Door string
+
+const (
+	DoorDriverFront    Door = "driver_front"
+	DoorDriverRear     Door = "driver_rear"
+	DoorPassengerFront Door = "passenger_front"
+	DoorPassengerRear  Door = "passenger_rear"
+)
+
+func (d Door) field(c *ClosuresState) (**bool, error) {
+	switch d {
+	case DoorDriverFront:
+		return &c.DoorDriverFrontOpen, nil
+	case DoorDriverRear:
+		return &c.DoorDriverRearOpen, nil
+	case DoorPassengerFront:
+		return &c.DoorPassengerFrontOpen, nil
+	case DoorPassengerRear:
+		return &c.DoorPassengerRearOpen, nil
+	}
+	return nil, fmt.Errorf("unknown door %q", d)
+}
+
+// SimAction is something that happens to the simulated vehicle outside of the Client API: the
+// driver gets in and drives, plugs in a cable, the weather changes... A few Client commands
+// (lock, unlock, start_climate, stop_climate, start_charging, stop_charging) are also accepted
+// so scenarios can script them.
+type SimAction struct {
+	Action         string   `json:"action"`
+	SpeedMPH       *float64 `json:"speed_mph,omitempty"`       // drive
+	HeadingDegrees *int     `json:"heading_degrees,omitempty"` // drive
+	Door           Door     `json:"door,omitempty"`            // open_door, close_door
+	Cable          string   `json:"cable,omitempty"`           // plug_in; defaults to SAE
+	Celsius        *float64 `json:"celsius,omitempty"`         // set_outside_temp
+	Tire           Tire     `json:"tire,omitempty"`            // tire_leak, inflate_tire (default all)
+	BarPerHour     *float64 `json:"bar_per_hour,omitempty"`    // tire_leak
+}
+
+var (
+	errVehicleLocked = errors.New("vehicle is locked")
+	errDriving       = errors.New("vehicle is driving")
+	errDoorOpen      = errors.New("a door is open")
+	errBatteryEmpty  = errors.New("battery is empty")
+	errNoTPMS        = errors.New("vehicle reports no tire pressure")
+)
+
+// apply performs a on mc. mc.mu must be held and the simulation advanced to the current time.
+func (a SimAction) apply(mc *MockClient) error {
+	s := mc.state
+	switch a.Action {
+	case "drive":
+		if a.SpeedMPH == nil || *a.SpeedMPH <= 0 {
+			return errors.New("drive needs a positive speed_mph")
+		}
+		if s.Charge.ChargeCable != nil {
+			return errCableConnected
+		}
+		if anyDoorOpen(s.Closures) {
+			return errDoorOpen
+		}
+		if mc.energyKWh <= 0 {
+			return errBatteryEmpty
+		}
+		s.Drive.ShiftState = ptr("D")
+		s.Drive.SpeedMPH = ptr(*a.SpeedMPH)
+		if a.HeadingDegrees != nil {
+			s.Location.HeadingDegrees = ptr(((*a.HeadingDegrees % 360) + 360) % 360)
+		}
+		s.Security.UserPresent = ptr(true)
+	case "park":
+		s.Drive.ShiftState = ptr("P")
+		s.Drive.SpeedMPH = ptr(0.0)
+	case "open_door", "close_door":
+		door, err := a.Door.field(s.Closures)
+		if err != nil {
+			return err
+		}
+		open := a.Action == "open_door"
+		if open && *s.Security.Locked {
+			return errVehicleLocked
+		}
+		if open && *s.Drive.SpeedMPH > 0 {
+			return errDriving
+		}
+		*door = ptr(open)
+		if open {
+			s.Security.UserPresent = ptr(true)
+		}
+	case "plug_in":
+		if *s.Drive.SpeedMPH > 0 {
+			return errDriving
+		}
+		cable := a.Cable
+		if cable == "" {
+			cable = "SAE"
+		}
+		s.Charge.ChargePortDoorOpen = ptr(true)
+		s.Charge.ChargePortLatch = ptr("engaged")
+		s.Charge.ChargeCable = ptr(cable)
+		setCharging(s.Charge, false)
+	case "unplug":
+		if s.Charge.ChargeCable == nil {
+			return errCableNotConnected
+		}
+		unplug(s.Charge)
+	case "set_outside_temp":
+		if a.Celsius == nil {
+			return errors.New("set_outside_temp needs celsius")
+		}
+		s.Climate.OutsideTempCelsius = ptr(*a.Celsius)
+	case "tire_leak":
+		if a.BarPerHour == nil || *a.BarPerHour <= 0 {
+			return errors.New("tire_leak needs a positive bar_per_hour")
+		}
+		if s.TirePressure == nil {
+			return errNoTPMS
+		}
+		pressure, _, _, err := a.Tire.fields(s.TirePressure)
+		if err != nil {
+			return err
+		}
+		if *pressure == nil {
+			return fmt.Errorf("%s tire reports no pressure", a.Tire.name())
+		}
+		if mc.tireLeaks == nil {
+			mc.tireLeaks, mc.tireBar = make(map[Tire]float64), make(map[Tire]float64)
+		}
+		if _, leaking := mc.tireLeaks[a.Tire]; !leaking {
+			mc.tireBar[a.Tire] = **pressure
+		}
+		mc.tireLeaks[a.Tire] = *a.BarPerHour
+	case "inflate_tire":
+		if s.TirePressure == nil {
+			return errNoTPMS
+		}
+		tires := Tires
+		if a.Tire != "" {
+			tires = []Tire{a.Tire}
+		}
+		for _, tire := range tires {
+			pressure, recommended, warning, err := tire.fields(s.TirePressure)
+			if err != nil {
+				return err
+			}
+			if recommended == nil {
+				return fmt.Errorf("%s tire has no recommended pressure", tire.name())
+			}
+			*pressure, *warning = ptr(*recommended), ptr("none")
+			delete(mc.tireLeaks, tire)
+			delete(mc.tireBar, tire)
+		}
+	case "sleep":
+		mc.asleep = true
+	case "wake":
+		mc.asleep = false
+	case "lock":
+		lock(s, true)
+	case "unlock":
+		lock(s, false)
+	case "start_climate":
+		startClimate(s)
+	case "stop_climate":
+		stopClimate(s)
+	case "start_charging":
+		return startCharging(s)
+	case "stop_charging":
+		return stopCharging(s)
+	default:
+		return fmt.Errorf("unknown simulator action %q", a.Action)
+	}
+	return nil
+}
+
+func anyDoorOpen(c *ClosuresState) bool {
+	for _, open := range []*bool{c.DoorDriverFrontOpen, c.DoorDriverRearOpen, c.DoorPassengerFrontOpen, c.DoorPassengerRearOpen} {
+		if open != nil && *open {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply performs a simulator action, such as driving off or plugging in, at the current
+// simulated time.
+func (mc *MockClient) Apply(a SimAction) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if err := mc.change(func(*VehicleState) error { return a.apply(mc) }); err != nil {
+		return fmt.Errorf("%s: %w", a.Action, err)
+	}
+	return nil
+}
+
+// change brings the simulation up to date and then applies f to the reported state. mc.mu must
+// be held.
+func (mc *MockClient) change(f func(s *VehicleState) error) error {
+	mc.advance()
+	if err := mc.mutate(f); err != nil {
+		return err
+	}
+	mc.publish()
+	return nil
+}
+
+// mutate applies f to the reported state, carrying edits of derived quantities over to the model.
+func (mc *MockClient) mutate(f func(s *VehicleState) error) error {
+	added := *mc.state.Charge.EnergyAddedKWh
+	if err := f(mc.state); err != nil {
+		return err
+	}
+	if *mc.state.Charge.EnergyAddedKWh != added {
+		mc.addedKWh = *mc.state.Charge.EnergyAddedKWh // A new charging session started.
+	}
+	return nil
+}
+
+// Snapshot returns the simulated vehicle's current state, even while it is asleep.
+func (mc *MockClient) Snapshot() *VehicleState {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.snapshot()
+}
+
+// snapshot brings the simulation up to date and copies the state. mc.mu must be held.
+func (mc *MockClient) snapshot() *VehicleState {
+	mc.advance()
+	state := mc.state.Clone()
+	state.FetchedAt = mc.simulatedAt.UTC()
+	return state
+}
+
+// Advance moves the simulation forward by d, running any scenario steps that fall due.
+func (mc *MockClient) Advance(d time.Duration) {
+	mc.clock.Advance(d)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.advance()
+}
+
+// advance brings the simulation up to the clock's current time. mc.mu must be held.
+func (mc *MockClient) advance() {
+	now := mc.clock.Now()
+	for len(mc.pending) > 0 && !mc.pending[0].at.After(now) {
+		step := mc.pending[0]
+		mc.pending = mc.pending[1:]
+		mc.simulate(step.at.Sub(mc.simulatedAt))
+		mc.simulatedAt = step.at
+		if err := mc.mutate(func(*VehicleState) error { return step.action.apply(mc) }); err != nil {
+			log.Printf("MockClient: scenario step %q failed: %v", step.action.Action, err)
+		}
+	}
+	mc.simulate(now.Sub(mc.simulatedAt))
+	mc.simulatedAt = now
+	mc.publish()
+}
+
+// simulate runs the physical model for dt, splitting it wherever charging completes or the
+// battery runs flat. mc.mu must be held.
+func (mc *MockClient) simulate(dt time.Duration) {
+	for dt > 0 {
+		dt = mc.simulateUntilEvent(dt)
+	}
+}
+
+// simulateUntilEvent runs the model for at most dt and returns how much of dt is left if it had
+// to stop early because the vehicle's behaviour changed.
+func (mc *MockClient) simulateUntilEvent(dt time.Duration) time.Duration {
+	s := mc.state
+	hours := dt.Hours()
+
+	chargeKW, driveKW, climateKW := mc.powerFlows()
+	net := chargeKW - driveKW - climateKW
+	limitKWh := float64(*s.Charge.ChargeLimitPercent) / 100 * simPackKWh
+
+	event := ""
+	switch {
+	case chargeKW > 0 && net > 0 && mc.energyKWh+net*hours >= limitKWh:
+		hours, event = math.Max(0, (limitKWh-mc.energyKWh)/net), "charge_complete"
+	case net < 0 && mc.energyKWh+net*hours <= 0:
+		hours, event = mc.energyKWh/-net, "battery_empty"
+	}
+
+	mc.energyKWh = math.Min(simPackKWh, math.Max(0, mc.energyKWh+net*hours))
+	if chargeKW > 0 {
+		mc.addedKWh += chargeKW * hours
+	}
+	if driveKW > 0 {
+		mc.move(*s.Drive.SpeedMPH * hours)
+	}
+	target, rate := *s.Climate.OutsideTempCelsius, simCabinDriftRate
+	if *s.Climate.IsClimateOn {
+		target, rate = *s.Climate.DriverTempSettingCelsius, simCabinHVACRate
+	}
+	mc.cabinCelsius = approach(mc.cabinCelsius, target, rate*hours*60)
+	for tire, barPerHour := range mc.tireLeaks {
+		mc.tireBar[tire] = math.Max(0, mc.tireBar[tire]-barPerHour*hours)
+	}
+
+	switch event {
+	case "charge_complete":
+		setCharging(s.Charge, false)
+		s.Charge.ChargingState = ptr("complete")
+	case "battery_empty":
+		s.Drive.SpeedMPH = ptr(0.0)
+		stopClimate(s)
+	default:
+		return 0
+	}
+	return dt - time.Duration(hours*float64(time.Hour))
+}
+
+// powerFlows returns the charger's output and the power used for driving and climate, in kW.
+func (mc *MockClient) powerFlows() (chargeKW, driveKW, climateKW float64) {
+	s := mc.state
+	if *s.Charge.ChargingState == "charging" {
+		chargeKW = float64(*s.Charge.ChargerPowerKW)
+	}
+	if *s.Drive.SpeedMPH > 0 {
+		driveKW = *s.Drive.SpeedMPH * simKWhPerMile
+	}
+	if *s.Climate.IsClimateOn {
+		climateKW = simClimateKW
+	}
+	return chargeKW, driveKW, climateKW
+}
+
+// move drives miles along the current heading.
+func (mc *MockClient) move(miles float64) {
+	l := mc.state.Location
+	heading := float64(*l.HeadingDegrees) * math.Pi / 180
+	lat := *l.Latitude + miles*math.Cos(heading)/milesPerDegreeLatLon
+	lon := *l.Longitude + miles*math.Sin(heading)/(milesPerDegreeLatLon*math.Cos(*l.Latitude*math.Pi/180))
+	l.Latitude, l.Longitude = ptr(lat), ptr(lon)
+	l.LocationName = nil // No longer at the named address.
+	*mc.state.Drive.OdometerMiles += miles
+}
+
+// approach moves from toward target by at most step.
+func approach(from, target, step float64) float64 {
+	if math.Abs(target-from) <= step {
+		return target
+	}
+	if target > from {
+		return from + step
+	}
+	return from - step
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// publish writes the quantities derived from the model into the reported state. mc.mu must be held.
+func (mc *MockClient) publish() {
+	s := mc.state
+	level := int(math.Round(mc.energyKWh / simPackKWh * 100))
+	rangeMiles := mc.energyKWh / simKWhPerMile
+	s.Charge.BatteryLevelPercent = ptr(level)
+	s.Charge.UsableBatteryLevelPercent = ptr(max(0, level-1))
+	s.Charge.RangeMiles = ptr(round(rangeMiles, 1))
+	s.Charge.EstimatedRangeMiles = ptr(round(rangeMiles*simEstimatedRange, 1))
+	s.Charge.EnergyAddedKWh = ptr(round(mc.addedKWh, 2))
+	s.Climate.InsideTempCelsius = ptr(round(mc.cabinCelsius, 1))
+
+	chargeKW, driveKW, _ := mc.powerFlows()
+	s.Charge.ChargeRateMPH = ptr(round(chargeKW/simKWhPerMile, 1))
+	s.Charge.MinutesToFullCharge = ptr(0)
+	if chargeKW > 0 {
+		limitKWh := float64(*s.Charge.ChargeLimitPercent) / 100 * simPackKWh
+		s.Charge.MinutesToFullCharge = ptr(int(math.Ceil(math.Max(0, limitKWh-mc.energyKWh) / chargeKW * 60)))
+	}
+	s.Drive.PowerKW = ptr(int(math.Round(driveKW)))
+	for tire := range mc.tireLeaks {
+		mc.publishTire(tire)
+	}
+	if *s.Drive.SpeedMPH == 0 && *s.Drive.ShiftState == "D" {
+		s.Drive.ShiftState = ptr("P") // Rolled to a stop with a flat battery.
+	}
+}
+
+// publishTire writes a leaking tire's pressure into the reported state and sets its TPMS warning
+// the way the vehicle would. mc.mu must be held.
+func (mc *MockClient) publishTire(tire Tire) {
+	pressure, recommended, warning, _ := tire.fields(mc.state.TirePressure)
+	bar := mc.tireBar[tire]
+	*pressure = ptr(round(bar, 2))
+	if recommended == nil {
+		return
+	}
+	switch {
+	case bar < *recommended*simTireHardWarning:
+		*warning = ptr("hard")
+	case bar < *recommended*simTireSoftWarning:
+		*warning = ptr("soft")
+	default:
+		*warning = ptr("none")
+	}
+}
+
+// syncFromState re-reads the model's precise quantities from the reported state, after the
+// state was replaced wholesale (e.g. by a scenario). mc.mu must be held.
+func (mc *MockClient) syncFromState() {
+	mc.energyKWh = float64(*mc.state.Charge.BatteryLevelPercent) / 100 * simPackKWh
+	mc.cabinCelsius = *mc.state.Climate.InsideTempCelsius
+	mc.addedKWh = *mc.state.Charge.EnergyAddedKWh
+}
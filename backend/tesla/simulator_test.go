@@ -0,0 +1,252 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var simStart = time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC)
+
+func newSimulatedMock(t *testing.T) *MockClient {
+	t.Helper()
+	return NewMockClientWithClock(NewManualClock(simStart))
+}
+
+func TestSimulator_ChargesUpToLimit(t *testing.T) {
+	mc := newSimulatedMock(t)
+	ctx := context.Background()
+	if _, err := mc.SetChargeLimit(ctx, 90); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.StartCharging(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mc.Advance(time.Hour)
+	s := mc.Snapshot()
+	// 32 A at 240 V adds 7 kW, a little over 9% of the pack per hour.
+	if *s.Charge.BatteryLevelPercent != 84 || *s.Charge.ChargingState != "charging" {
+		t.Errorf("after 1h: level %d%%, state %s; want 84%%, charging", *s.Charge.BatteryLevelPercent, *s.Charge.ChargingState)
+	}
+	if *s.Charge.EnergyAddedKWh != 7 || *s.Charge.MinutesToFullCharge == 0 {
+		t.Errorf("after 1h: energy added %v kWh, %d min to full", *s.Charge.EnergyAddedKWh, *s.Charge.MinutesToFullCharge)
+	}
+	if !s.FetchedAt.Equal(simStart.Add(time.Hour)) {
+		t.Errorf("FetchedAt = %v, want simulated time", s.FetchedAt)
+	}
+
+	mc.Advance(2 * time.Hour)
+	s = mc.Snapshot()
+	if *s.Charge.BatteryLevelPercent != 90 || *s.Charge.ChargingState != "complete" || *s.Charge.ChargerPowerKW != 0 {
+		t.Errorf("after 3h: level %d%%, state %s, power %d kW; want 90%%, complete, 0",
+			*s.Charge.BatteryLevelPercent, *s.Charge.ChargingState, *s.Charge.ChargerPowerKW)
+	}
+
+	// Raising the limit resumes the session.
+	if _, err := mc.SetChargeLimit(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	if s = mc.Snapshot(); *s.Charge.ChargingState != "charging" {
+		t.Errorf("charging state after raising limit = %s, want charging", *s.Charge.ChargingState)
+	}
+}
+
+func TestSimulator_DrivingDrainsBatteryAndMoves(t *testing.T) {
+	mc := newSimulatedMock(t)
+	before := mc.Snapshot()
+
+	drive := SimAction{Action: "drive", SpeedMPH: ptr(60.0), HeadingDegrees: ptr(0)}
+	if err := mc.Apply(drive); !errors.Is(err, errCableConnected) {
+		t.Fatalf("driving while plugged in: err = %v, want %v", err, errCableConnected)
+	}
+	for _, a := range []SimAction{{Action: "unplug"}, drive} {
+		if err := mc.Apply(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mc.Advance(30 * time.Minute)
+	s := mc.Snapshot()
+	// 30 miles at 0.25 kWh/mile is 7.5 kWh, 10% of the pack.
+	if *s.Charge.BatteryLevelPercent != *before.Charge.BatteryLevelPercent-10 {
+		t.Errorf("battery level = %d%%, want %d%%", *s.Charge.BatteryLevelPercent, *before.Charge.BatteryLevelPercent-10)
+	}
+	if got := *s.Drive.OdometerMiles - *before.Drive.OdometerMiles; math.Abs(got-30) > 1e-6 {
+		t.Errorf("odometer advanced %v miles, want 30", got)
+	}
+	if *s.Location.Latitude <= *before.Location.Latitude || *s.Location.Longitude != *before.Location.Longitude {
+		t.Errorf("heading north moved from (%v, %v) to (%v, %v)", *before.Location.Latitude, *before.Location.Longitude,
+			*s.Location.Latitude, *s.Location.Longitude)
+	}
+	if s.Location.LocationName != nil || *s.Drive.ShiftState != "D" || *s.Drive.PowerKW != 15 {
+		t.Errorf("driving state: location %v, shift %s, power %d", s.Location.LocationName, *s.Drive.ShiftState, *s.Drive.PowerKW)
+	}
+
+	// Driving until the battery is flat brings the car to a stop.
+	mc.Advance(10 * time.Hour)
+	s = mc.Snapshot()
+	if *s.Charge.BatteryLevelPercent != 0 || *s.Drive.SpeedMPH != 0 || *s.Drive.ShiftState != "P" {
+		t.Errorf("flat battery: level %d%%, speed %v, shift %s", *s.Charge.BatteryLevelPercent, *s.Drive.SpeedMPH, *s.Drive.ShiftState)
+	}
+	if err := mc.Apply(drive); !errors.Is(err, errBatteryEmpty) {
+		t.Errorf("driving on a flat battery: err = %v, want %v", err, errBatteryEmpty)
+	}
+}
+
+func TestSimulator_CabinTemperature(t *testing.T) {
+	mc := newSimulatedMock(t)
+	ctx := context.Background()
+	if _, err := mc.SetTemperatures(ctx, 22, 22); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Apply(SimAction{Action: "set_outside_temp", Celsius: ptr(35.0)}); err != nil {
+		t.Fatal(err)
+	}
+	inside := *mc.Snapshot().Climate.InsideTempCelsius
+
+	// Without HVAC the cabin drifts slowly towards the outside temperature.
+	mc.Advance(20 * time.Minute)
+	if got := *mc.Snapshot().Climate.InsideTempCelsius; got != inside+1 {
+		t.Errorf("cabin after 20 min parked = %v, want %v", got, inside+1)
+	}
+
+	if _, err := mc.StartClimate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mc.Advance(time.Hour)
+	if got := *mc.Snapshot().Climate.InsideTempCelsius; got != 22 {
+		t.Errorf("cabin after 1h of climate = %v, want 22", got)
+	}
+}
+
+func TestSimulator_Doors(t *testing.T) {
+	mc := newSimulatedMock(t)
+	ctx := context.Background()
+	open := SimAction{Action: "open_door", Door: DoorDriverFront}
+
+	if _, err := mc.LockVehicle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Apply(open); !errors.Is(err, errVehicleLocked) {
+		t.Fatalf("opening a locked door: err = %v, want %v", err, errVehicleLocked)
+	}
+	if _, err := mc.UnlockVehicle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Apply(open); err != nil {
+		t.Fatal(err)
+	}
+	s := mc.Snapshot()
+	if !*s.Closures.DoorDriverFrontOpen || !*s.Security.UserPresent {
+		t.Errorf("after opening: door open %v, user present %v", *s.Closures.DoorDriverFrontOpen, *s.Security.UserPresent)
+	}
+	if err := mc.Apply(SimAction{Action: "open_door", Door: "boot"}); err == nil {
+		t.Error("opening an unknown door succeeded")
+	}
+}
+
+const testScenarioYAML = `
+name: commute
+initial:
+  charge: {battery_level_percent: 50, charge_cable: null, charging_state: disconnected}
+  security: {locked: false}
+steps:
+  - {action: drive, speed_mph: 30, heading_degrees: 90}
+  - {after: 20m, action: park}
+  - {after: 90, action: lock}
+`
+
+const testScenarioJSON = `{
+  "name": "commute",
+  "initial": {"charge": {"battery_level_percent": 50, "charge_cable": null, "charging_state": "disconnected"}, "security": {"locked": false}},
+  "steps": [
+    {"action": "drive", "speed_mph": 30, "heading_degrees": 90},
+    {"after": "20m", "action": "park"},
+    {"after": 90, "action": "lock"}
+  ]
+}`
+
+func TestScenario(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []struct{ name, content string }{
+		{"commute.yaml", testScenarioYAML},
+		{"commute.json", testScenarioJSON},
+	} {
+		t.Run(file.name, func(t *testing.T) {
+			path := filepath.Join(dir, file.name)
+			if err := os.WriteFile(path, []byte(file.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			sc, err := LoadScenarioFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sc.Name != "commute" || len(sc.Steps) != 3 || time.Duration(sc.Steps[2].After) != 90*time.Second {
+				t.Fatalf("parsed scenario = %+v", sc)
+			}
+
+			mc := newSimulatedMock(t)
+			if err := mc.LoadScenario(sc); err != nil {
+				t.Fatal(err)
+			}
+			s := mc.Snapshot()
+			if *s.Drive.SpeedMPH != 30 || *s.Location.HeadingDegrees != 90 || *s.Charge.BatteryLevelPercent != 50 {
+				t.Errorf("first step should run at load: speed %v, heading %d, level %d%%",
+					*s.Drive.SpeedMPH, *s.Location.HeadingDegrees, *s.Charge.BatteryLevelPercent)
+			}
+
+			mc.Advance(21 * time.Minute)
+			s = mc.Snapshot()
+			if *s.Drive.SpeedMPH != 0 || *s.Security.Locked || math.Abs(*s.Drive.OdometerMiles-(12345.6+10)) > 1e-6 {
+				t.Errorf("after 21 min: speed %v, locked %v, odometer %v", *s.Drive.SpeedMPH, *s.Security.Locked, *s.Drive.OdometerMiles)
+			}
+			mc.Advance(time.Minute)
+			if !*mc.Snapshot().Security.Locked {
+				t.Error("lock step did not run")
+			}
+		})
+	}
+}
+
+func TestScenario_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown field": `{"name": "x", "steps": [{"action": "park", "speed": 3}]}`,
+		"bad duration":  `{"name": "x", "steps": [{"after": "soon", "action": "park"}]}`,
+		"null required": `{"name": "x", "initial": {"security": {"locked": null}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			sc, err := ParseScenario([]byte(data), false)
+			if err == nil {
+				err = newSimulatedMock(t).LoadScenario(sc)
+			}
+			if err == nil {
+				t.Error("invalid scenario accepted")
+			}
+		})
+	}
+}
+
+func TestScenario_NullTrunkOpen(t *testing.T) {
+	sc, err := ParseScenario([]byte(`{"name": "x", "initial": {"closures": {"trunk_open": null}}}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := newSimulatedMock(t)
+	if err := mc.LoadScenario(sc); err == nil || !strings.Contains(err.Error(), "closures.trunk_open") {
+		t.Errorf("LoadScenario() with a null trunk_open = %v, want it rejected", err)
+	}
+
+	// The rejected scenario left the state alone, so actuating the trunk still works.
+	if ok, err := mc.ActuateTrunk(context.Background()); !ok || err != nil {
+		t.Fatalf("ActuateTrunk() = %v, %v", ok, err)
+	}
+	if !*mc.Snapshot().Closures.TrunkOpen {
+		t.Error("trunk not opened")
+	}
+}
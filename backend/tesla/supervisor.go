@@ -0,0 +1,456 @@
+package tesla
+
+import (
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/connector/inet"
+	"github.com/teslamotors/vehicle-command/pkg/protocol"
+)
+
+// ConnectionState is the health of a SupervisedClient's vehicle connection.
+type ConnectionState string
+
+const (
+	StateConnecting ConnectionState = "connecting"  // No usable connection yet; retrying in the background.
+	StateOnline     ConnectionState = "online"      // Connected and the vehicle is answering.
+	StateAsleep     ConnectionState = "asleep"      // Connected, but the vehicle is asleep.
+	StateAuthFailed ConnectionState = "auth_failed" // Credentials were rejected; retrying at the slowest backoff.
+	StateClosed     ConnectionState = "closed"      // Close was called.
+)
+
+// ConnectFunc creates the Client for one vehicle. A SupervisedClient calls it to connect and to
+// replace a failing connection.
+type ConnectFunc func(ctx context.Context) (Client, error)
+
+// SupervisorOptions configures a SupervisedClient.
+type SupervisorOptions struct {
+	Backoff        Backoff       // Delay between connection attempts.
+	ConnectTimeout time.Duration // Bound on a single connection attempt.
+	MaxFailures    int           // Consecutive failed calls after which the connection is replaced.
+}
+
+// DefaultSupervisorOptions returns the options used by the server.
+func DefaultSupervisorOptions() SupervisorOptions {
+	return SupervisorOptions{
+		Backoff:        Backoff{Initial: 5 * time.Second, Max: 5 * time.Minute, Multiplier: 2},
+		ConnectTimeout: DefaultTimeouts().Connect,
+		MaxFailures:    3,
+	}
+}
+
+// SupervisedClient is a Client that keeps itself connected. It connects in the background,
+// retrying with backoff until it succeeds, and replaces the underlying client with a fresh
+// connection after MaxFailures consecutive failed calls. Calls made while no connection is
+// available fail with an error wrapping ErrVehicleNotConnected.
+type SupervisedClient struct {
+	vin     string
+	connect ConnectFunc
+	opts    SupervisorOptions
+
+	mu       sync.RWMutex
+	client   Client
+	state    ConnectionState
+	lastErr  error
+	failures int
+
+	reconnect chan struct{} // Buffered; a pending value asks run to replace the connection.
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSupervisedClient creates a SupervisedClient for vin and starts connecting it with connect.
+func NewSupervisedClient(vin string, connect ConnectFunc, opts SupervisorOptions) *SupervisedClient {
+	s := &SupervisedClient{
+		vin:       vin,
+		connect:   connect,
+		opts:      opts,
+		state:     StateConnecting,
+		reconnect: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// ConnectionState returns the current state and, unless online, the error that caused it.
+func (s *SupervisedClient) ConnectionState() (ConnectionState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state, s.lastErr
+}
+
+// run owns the connection: it connects with backoff, then waits until the connection has to be
+// replaced or the client is closed.
+func (s *SupervisedClient) run() {
+	defer close(s.done)
+	for attempt := 0; ; {
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.ConnectTimeout)
+		client, err := s.connectUnlessStopped(ctx)
+		cancel()
+
+		if err == nil {
+			attempt = 0
+			s.install(client)
+			select {
+			case <-s.stop:
+				return
+			case <-s.reconnect:
+				log.Printf("Tesla client for %s: replacing connection after repeated failures", s.vin)
+				s.uninstall(client)
+				continue
+			}
+		}
+
+		delay := s.opts.Backoff.Delay(attempt)
+		if errors.Is(err, ErrUnauthorized) {
+			delay = s.opts.Backoff.Max // Retrying won't help until the credentials are fixed.
+		}
+		s.setError(err)
+		log.Printf("Tesla client for %s: connecting failed (%v); retrying in %s", s.vin, err, delay)
+		attempt++
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// connectUnlessStopped runs connect, giving up early if the client is closed meanwhile.
+func (s *SupervisedClient) connectUnlessStopped(ctx context.Context) (Client, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return s.connect(ctx)
+}
+
+func (s *SupervisedClient) install(client Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.client = client
+	s.state = StateOnline
+	s.lastErr = nil
+	s.failures = 0
+	log.Printf("Tesla client for %s: connected", s.vin)
+}
+
+func (s *SupervisedClient) uninstall(client Client) {
+	s.mu.Lock()
+	s.client = nil
+	s.state = StateConnecting
+	s.mu.Unlock()
+	closeClient(s.vin, client)
+}
+
+func (s *SupervisedClient) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+	if errors.Is(err, ErrUnauthorized) {
+		s.state = StateAuthFailed
+	} else {
+		s.state = StateConnecting
+	}
+}
+
+func closeClient(vin string, client Client) {
+	if closer, ok := client.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Tesla client for %s: closing connection: %v", vin, err)
+		}
+	}
+}
+
+// current returns the connected client, or an error describing why there is none.
+func (s *SupervisedClient) current() (Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	if s.lastErr != nil {
+		return nil, fmt.Errorf("%w: %s (%s): %w", ErrVehicleNotConnected, s.vin, s.state, s.lastErr)
+	}
+	return nil, fmt.Errorf("%w: %s (%s)", ErrVehicleNotConnected, s.vin, s.state)
+}
+
+// observe updates the state from the outcome of a call made through client and asks for a new
+// connection once MaxFailures calls in a row have failed with a transport or session error. Other
+// errors, such as invalid arguments or the vehicle refusing a command, do not count.
+func (s *SupervisedClient) observe(client Client, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != client {
+		return // The connection was replaced while the call was running.
+	}
+	switch {
+	case err == nil:
+		s.state, s.lastErr, s.failures = StateOnline, nil, 0
+		return
+	case errors.Is(err, ErrVehicleAsleep):
+		s.state, s.lastErr, s.failures = StateAsleep, nil, 0
+		return
+	case errors.Is(err, ErrUnauthorized):
+		s.state, s.lastErr = StateAuthFailed, err
+		s.failures = s.opts.MaxFailures // Reconnect right away; the new connection reloads credentials.
+	case connectionError(err):
+		s.lastErr = err
+		s.failures++
+	default:
+		// A new connection would not help; when rate limited, its handshake would only add
+		// requests.
+		return
+	}
+	if s.failures >= s.opts.MaxFailures {
+		select {
+		case s.reconnect <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// connectionError reports whether err means the connection to the vehicle is broken: the network
+// or the Fleet API failed, or the vehicle rejected or lost our session. Timeouts, rate limits and
+// the vehicle declining a command it authenticated are not.
+func connectionError(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrRateLimited) || vehicleRefused(err) {
+		return false
+	}
+	var netErr net.Error
+	var httpErr *inet.HTTPError
+	var faultErr *protocol.RoutableMessageError
+	switch {
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.As(err, &httpErr):
+		return httpErr.Code >= http.StatusInternalServerError
+	case errors.As(err, &faultErr):
+		return true
+	}
+	for _, sessionErr := range []error{
+		protocol.ErrNotConnected, protocol.ErrNoSession, protocol.ErrNoDecryptionContext,
+		protocol.ErrBadResponse, protocol.ErrUnpexpectedPublicKey,
+	} {
+		if errors.Is(err, sessionErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// vehicleRefused reports whether err is the vehicle declining a command it received and
+// authenticated, e.g. closing the charge port with a cable plugged in.
+func vehicleRefused(err error) bool {
+	var vcsecErr *protocol.NominalVCSECError
+	var keychainErr *protocol.KeychainError
+	return protocol.IsNominalError(err) || errors.As(err, &vcsecErr) || errors.As(err, &keychainErr)
+}
+
+// call runs f against the current connection and records its outcome.
+func call[T any](s *SupervisedClient, f func(Client) (T, error)) (T, error) {
+	client, err := s.current()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	v, err := f(client)
+	s.observe(client, err)
+	return v, err
+}
+
+// Close stops reconnecting and closes the current connection. It is safe to call more than once.
+func (s *SupervisedClient) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		s.mu.Lock()
+		client := s.client
+		s.client, s.state = nil, StateClosed
+		s.mu.Unlock()
+		if client != nil {
+			closeClient(s.vin, client)
+		}
+	})
+	return nil
+}
+
+// GetVehicleStats implements Client.
+func (s *SupervisedClient) GetVehicleStats(ctx context.Context, categories ...StateCategory) (*VehicleState, error) {
+	return call(s, func(c Client) (*VehicleState, error) { return c.GetVehicleStats(ctx, categories...) })
+}
+
+// LockVehicle implements Client.
+func (s *SupervisedClient) LockVehicle(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.LockVehicle(ctx) })
+}
+
+// UnlockVehicle implements Client.
+func (s *SupervisedClient) UnlockVehicle(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.UnlockVehicle(ctx) })
+}
+
+// GetCameraFeed implements Client.
+func (s *SupervisedClient) GetCameraFeed(ctx context.Context) (string, error) {
+	return call(s, func(c Client) (string, error) { return c.GetCameraFeed(ctx) })
+}
+
+// Wake implements Client.
+func (s *SupervisedClient) Wake(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.Wake(ctx) })
+}
+
+// IsOnline implements Client. A vehicle that reports being offline puts the client in StateAsleep.
+func (s *SupervisedClient) IsOnline(ctx context.Context) (bool, error) {
+	client, err := s.current()
+	if err != nil {
+		return false, err
+	}
+	online, err := client.IsOnline(ctx)
+	if err == nil && !online {
+		s.observe(client, ErrVehicleAsleep)
+	} else {
+		s.observe(client, err)
+	}
+	return online, err
+}
+
+// StartClimate implements Client.
+func (s *SupervisedClient) StartClimate(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.StartClimate(ctx) })
+}
+
+// StopClimate implements Client.
+func (s *SupervisedClient) StopClimate(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.StopClimate(ctx) })
+}
+
+// SetTemperatures implements Client.
+func (s *SupervisedClient) SetTemperatures(ctx context.Context, driverCelsius, passengerCelsius float64) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetTemperatures(ctx, driverCelsius, passengerCelsius) })
+}
+
+// SetSeatHeater implements Client.
+func (s *SupervisedClient) SetSeatHeater(ctx context.Context, seat Seat, level HeaterLevel) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetSeatHeater(ctx, seat, level) })
+}
+
+// SetSteeringWheelHeater implements Client.
+func (s *SupervisedClient) SetSteeringWheelHeater(ctx context.Context, on bool) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetSteeringWheelHeater(ctx, on) })
+}
+
+// SetMaxDefrost implements Client.
+func (s *SupervisedClient) SetMaxDefrost(ctx context.Context, on bool) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetMaxDefrost(ctx, on) })
+}
+
+// SetClimateKeeperMode implements Client.
+func (s *SupervisedClient) SetClimateKeeperMode(ctx context.Context, mode ClimateKeeperMode) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetClimateKeeperMode(ctx, mode) })
+}
+
+// StartCharging implements Client.
+func (s *SupervisedClient) StartCharging(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.StartCharging(ctx) })
+}
+
+// StopCharging implements Client.
+func (s *SupervisedClient) StopCharging(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.StopCharging(ctx) })
+}
+
+// SetChargeLimit implements Client.
+func (s *SupervisedClient) SetChargeLimit(ctx context.Context, percent int) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetChargeLimit(ctx, percent) })
+}
+
+// SetChargingAmps implements Client.
+func (s *SupervisedClient) SetChargingAmps(ctx context.Context, amps int) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetChargingAmps(ctx, amps) })
+}
+
+// OpenChargePort implements Client.
+func (s *SupervisedClient) OpenChargePort(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.OpenChargePort(ctx) })
+}
+
+// CloseChargePort implements Client.
+func (s *SupervisedClient) CloseChargePort(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.CloseChargePort(ctx) })
+}
+
+// UnlockChargeCable implements Client.
+func (s *SupervisedClient) UnlockChargeCable(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.UnlockChargeCable(ctx) })
+}
+
+// ScheduleCharging implements Client.
+func (s *SupervisedClient) ScheduleCharging(ctx context.Context, enabled bool, startAfterMidnight time.Duration) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.ScheduleCharging(ctx, enabled, startAfterMidnight) })
+}
+
+// ActuateFrunk implements Client.
+func (s *SupervisedClient) ActuateFrunk(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.ActuateFrunk(ctx) })
+}
+
+// ActuateTrunk implements Client.
+func (s *SupervisedClient) ActuateTrunk(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.ActuateTrunk(ctx) })
+}
+
+// VentWindows implements Client.
+func (s *SupervisedClient) VentWindows(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.VentWindows(ctx) })
+}
+
+// CloseWindows implements Client.
+func (s *SupervisedClient) CloseWindows(ctx context.Context) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.CloseWindows(ctx) })
+}
+
+// SetSunroof implements Client.
+func (s *SupervisedClient) SetSunroof(ctx context.Context, percentOpen int) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetSunroof(ctx, percentOpen) })
+}
+
+// SetTonneau implements Client.
+func (s *SupervisedClient) SetTonneau(ctx context.Context, action TonneauAction) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.SetTonneau(ctx, action) })
+}
+
+// ListKeys implements Client.
+func (s *SupervisedClient) ListKeys(ctx context.Context) ([]VehicleKey, error) {
+	return call(s, func(c Client) ([]VehicleKey, error) { return c.ListKeys(ctx) })
+}
+
+// AddKey implements Client.
+func (s *SupervisedClient) AddKey(ctx context.Context, publicKey *ecdh.PublicKey, role KeyRole, formFactor KeyFormFactor) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.AddKey(ctx, publicKey, role, formFactor) })
+}
+
+// RemoveKey implements Client.
+func (s *SupervisedClient) RemoveKey(ctx context.Context, publicKey *ecdh.PublicKey) (bool, error) {
+	return call(s, func(c Client) (bool, error) { return c.RemoveKey(ctx, publicKey) })
+}
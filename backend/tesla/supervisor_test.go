@@ -0,0 +1,184 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol"
+)
+
+// flakyClient fails every call with err while err is set.
+type flakyClient struct {
+	*MockClient
+	mu  sync.Mutex
+	err error
+}
+
+func (f *flakyClient) LockVehicle(ctx context.Context) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return false, f.err
+	}
+	return true, nil
+}
+
+func waitForState(t *testing.T, s *SupervisedClient, want ConnectionState) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if state, _ := s.ConnectionState(); state == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	state, err := s.ConnectionState()
+	t.Fatalf("state = %s (%v), want %s", state, err, want)
+}
+
+func testSupervisorOptions() SupervisorOptions {
+	return SupervisorOptions{
+		Backoff:        Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2},
+		ConnectTimeout: time.Second,
+		MaxFailures:    2,
+	}
+}
+
+func TestSupervisedClient_RetriesUntilConnected(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	s := NewSupervisedClient("VIN1", func(ctx context.Context) (Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("vehicle unreachable")
+		}
+		return NewMockClient(), nil
+	}, testSupervisorOptions())
+	defer s.Close()
+
+	waitForState(t, s, StateOnline)
+	if _, err := s.LockVehicle(context.Background()); err != nil {
+		t.Errorf("LockVehicle() after reconnecting = %v", err)
+	}
+}
+
+func TestSupervisedClient_NotConnected(t *testing.T) {
+	s := NewSupervisedClient("VIN1", func(ctx context.Context) (Client, error) {
+		return nil, errors.New("vehicle unreachable")
+	}, testSupervisorOptions())
+	defer s.Close()
+
+	if _, err := s.LockVehicle(context.Background()); !errors.Is(err, ErrVehicleNotConnected) {
+		t.Errorf("LockVehicle() without a connection = %v, want ErrVehicleNotConnected", err)
+	}
+}
+
+func TestSupervisedClient_ReplacesFailingConnection(t *testing.T) {
+	var mu sync.Mutex
+	var clients []*flakyClient
+	s := NewSupervisedClient("VIN1", func(ctx context.Context) (Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		c := &flakyClient{MockClient: NewMockClient()}
+		clients = append(clients, c)
+		return c, nil
+	}, testSupervisorOptions())
+	defer s.Close()
+	waitForState(t, s, StateOnline)
+
+	mu.Lock()
+	first := clients[0]
+	mu.Unlock()
+	first.mu.Lock()
+	first.err = fmt.Errorf("SDK error locking vehicle: %w", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")})
+	first.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.LockVehicle(context.Background()); err == nil {
+			t.Fatal("LockVehicle() on a failing connection succeeded")
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := s.LockVehicle(context.Background()); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(clients) < 2 {
+		t.Fatalf("connection was not replaced after repeated failures (%d connections)", len(clients))
+	}
+}
+
+func TestSupervisedClient_KeepsConnection(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+	}{
+		{"rate limited", fmt.Errorf("locking: %w", ErrRateLimited)},
+		{"invalid argument", fmt.Errorf("unknown tonneau action %q", "ajar")},
+		{"refused", fmt.Errorf("SDK error locking vehicle: %w", &protocol.NominalError{Details: errors.New("cable connected")})},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			connections := 0
+			flaky := &flakyClient{MockClient: NewMockClient(), err: tt.err}
+			s := NewSupervisedClient("VIN1", func(ctx context.Context) (Client, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				connections++
+				return flaky, nil
+			}, testSupervisorOptions())
+			defer s.Close()
+			waitForState(t, s, StateOnline)
+
+			for i := 0; i < 5; i++ {
+				if _, err := s.LockVehicle(context.Background()); !errors.Is(err, tt.err) {
+					t.Fatalf("LockVehicle() = %v, want %v", err, tt.err)
+				}
+			}
+			time.Sleep(20 * time.Millisecond) // Time for an unwanted reconnect.
+			mu.Lock()
+			defer mu.Unlock()
+			if connections != 1 {
+				t.Errorf("%d reconnects, want none", connections-1)
+			}
+			if state, err := s.ConnectionState(); state != StateOnline || err != nil {
+				t.Errorf("state = %s (%v), want %s", state, err, StateOnline)
+			}
+		})
+	}
+}
+
+func TestSupervisedClient_AsleepAndAuthFailed(t *testing.T) {
+	mock := NewMockClient()
+	s := NewSupervisedClient("VIN1", func(ctx context.Context) (Client, error) {
+		return mock, nil
+	}, testSupervisorOptions())
+	defer s.Close()
+	waitForState(t, s, StateOnline)
+
+	mock.Sleep()
+	if online, err := s.IsOnline(context.Background()); online || err != nil {
+		t.Fatalf("IsOnline() = %v, %v; want false, nil", online, err)
+	}
+	if state, _ := s.ConnectionState(); state != StateAsleep {
+		t.Errorf("state after the vehicle fell asleep = %s, want %s", state, StateAsleep)
+	}
+
+	denied := NewSupervisedClient("VIN2", func(ctx context.Context) (Client, error) {
+		return nil, ErrUnauthorized
+	}, testSupervisorOptions())
+	defer denied.Close()
+	waitForState(t, denied, StateAuthFailed)
+}
@@ -0,0 +1,411 @@
+// Package teslacam indexes the TeslaCam folder a Tesla writes to its USB drive: the rolling
+// RecentClips buffer and the events kept in SavedClips and SentryClips.
+package teslacam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEventNotFound is returned for an event or clip that is not in the archive.
+var ErrEventNotFound = errors.New("camera event not found")
+
+// Kind says which TeslaCam folder an event comes from.
+type Kind string
+
+const (
+	KindRecent Kind = "recent" // RecentClips: the last hour of driving, one event per drive.
+	KindSaved  Kind = "saved"  // SavedClips: saved with the dashcam button or by voice.
+	KindSentry Kind = "sentry" // SentryClips: recorded by Sentry Mode.
+)
+
+// kindDirs maps each kind to its folder under the archive root.
+var kindDirs = map[Kind]string{
+	KindRecent: "RecentClips",
+	KindSaved:  "SavedClips",
+	KindSentry: "SentryClips",
+}
+
+// ParseKind validates an event kind given by a client.
+func ParseKind(s string) (Kind, error) {
+	if _, ok := kindDirs[Kind(s)]; !ok {
+		return "", fmt.Errorf("unknown camera event kind %q; use recent, saved or sentry", s)
+	}
+	return Kind(s), nil
+}
+
+// Event is one recording in the archive and the clips it is made of.
+type Event struct {
+	ID        string    `json:"id"` // e.g. sentry-2024-05-01_10-15-30; stable across rescans.
+	Kind      Kind      `json:"kind"`
+	Timestamp time.Time `json:"timestamp"` // When the event was triggered, or when a drive's first clip starts.
+	Reason    string    `json:"reason,omitempty"`
+	City      string    `json:"city,omitempty"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+	// TriggerCamera is the camera index event.json reports as having triggered the recording.
+	TriggerCamera string   `json:"trigger_camera,omitempty"`
+	Cameras       []string `json:"cameras"`
+	Clips         []Clip   `json:"clips"`
+	// Telemetry is filled in on request, since it means reading every front clip; see
+	// Archive.EventTelemetry.
+	Telemetry *TelemetrySummary `json:"telemetry,omitempty"`
+
+	dir string // The event's folder; empty for drives, whose clips can span folders.
+}
+
+// Clip is one camera's recording of one segment, usually a minute long.
+type Clip struct {
+	Name   string    `json:"name"` // File name, e.g. 2024-05-01_10-14-30-front.mp4.
+	Camera string    `json:"camera"`
+	Start  time.Time `json:"start"`
+	Size   int64     `json:"size"`
+
+	path string
+}
+
+// clipName matches TeslaCam clip files: the segment's start time and the camera.
+var clipName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2})-([a-z_]+)\.mp4$`)
+
+const timestampLayout = "2006-01-02_15-04-05"
+
+// DefaultMaxAge is how long an index is reused before the archive is scanned again.
+const DefaultMaxAge = 30 * time.Second
+
+// Archive is an index of a TeslaCam folder. The folder is rescanned at most every DefaultMaxAge,
+// so clips the vehicle or a sync job adds show up without a restart. It is safe for concurrent
+// use.
+type Archive struct {
+	root   string
+	opts   ArchiveOptions
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu             sync.Mutex
+	events         []Event
+	indexedAt      time.Time
+	telemetryCache map[string]cachedTelemetry // By clip path.
+}
+
+// ArchiveOptions configures an Archive.
+type ArchiveOptions struct {
+	// Location interprets clip timestamps, which vehicles write in their local time.
+	Location *time.Location
+	// ThumbnailDir caches the thumbnails generated for events without one of their own.
+	ThumbnailDir string
+	// FFmpeg is the ffmpeg binary that extracts thumbnails. Without it, only the thumbnails
+	// vehicles save with their events are available.
+	FFmpeg string
+}
+
+// DefaultArchiveOptions returns the options used when nothing else is configured: local time,
+// a thumbnail cache in the temporary directory, and ffmpeg from PATH.
+func DefaultArchiveOptions() ArchiveOptions {
+	return ArchiveOptions{
+		Location:     time.Local,
+		ThumbnailDir: filepath.Join(os.TempDir(), "teslacam-thumbnails"),
+		FFmpeg:       "ffmpeg",
+	}
+}
+
+// NewArchive indexes the TeslaCam folder at root, the directory holding RecentClips, SavedClips
+// and SentryClips.
+func NewArchive(root string, opts ArchiveOptions) (*Archive, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("opening TeslaCam archive: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("opening TeslaCam archive: %s is not a directory", root)
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	return &Archive{root: root, opts: opts, maxAge: DefaultMaxAge, now: time.Now}, nil
+}
+
+// ArchiveFromEnvironment opens the archive named by TESLA_CAMERA_DIR, interpreting timestamps in
+// TESLA_CAMERA_TZ (an IANA zone name, local time by default). TESLA_CAMERA_THUMBNAIL_DIR and
+// TESLA_FFMPEG override the thumbnail cache and the ffmpeg binary. It returns nil, nil when
+// TESLA_CAMERA_DIR is unset.
+func ArchiveFromEnvironment() (*Archive, error) {
+	root := os.Getenv("TESLA_CAMERA_DIR")
+	if root == "" {
+		return nil, nil
+	}
+	opts := DefaultArchiveOptions()
+	if name := os.Getenv("TESLA_CAMERA_TZ"); name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("TESLA_CAMERA_TZ: %w", err)
+		}
+		opts.Location = loc
+	}
+	if dir := os.Getenv("TESLA_CAMERA_THUMBNAIL_DIR"); dir != "" {
+		opts.ThumbnailDir = dir
+	}
+	if ffmpeg := os.Getenv("TESLA_FFMPEG"); ffmpeg != "" {
+		opts.FFmpeg = ffmpeg
+	}
+	return NewArchive(root, opts)
+}
+
+// Events returns the events in the archive, newest first.
+func (a *Archive) Events() ([]Event, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.events == nil || a.now().Sub(a.indexedAt) >= a.maxAge {
+		events, err := a.scan()
+		if err != nil {
+			return nil, err
+		}
+		a.events, a.indexedAt = events, a.now()
+	}
+	return slices.Clone(a.events), nil
+}
+
+// Event returns the event with the given ID.
+func (a *Archive) Event(id string) (Event, error) {
+	events, err := a.Events()
+	if err != nil {
+		return Event{}, err
+	}
+	for _, e := range events {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Event{}, fmt.Errorf("%w: %s", ErrEventNotFound, id)
+}
+
+// OpenClip opens the named clip of an event for reading. Only files in the index can be opened,
+// so a client cannot reach outside the archive.
+func (a *Archive) OpenClip(eventID, name string) (*os.File, Clip, error) {
+	event, err := a.Event(eventID)
+	if err != nil {
+		return nil, Clip{}, err
+	}
+	i := slices.IndexFunc(event.Clips, func(c Clip) bool { return c.Name == name })
+	if i < 0 {
+		return nil, Clip{}, fmt.Errorf("%w: %s has no clip %s", ErrEventNotFound, eventID, name)
+	}
+	f, err := os.Open(event.Clips[i].path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Rotated out of RecentClips since the last scan.
+		return nil, Clip{}, fmt.Errorf("%w: %s/%s", ErrEventNotFound, eventID, name)
+	}
+	if err != nil {
+		return nil, Clip{}, err
+	}
+	return f, event.Clips[i], nil
+}
+
+// scan walks the three TeslaCam folders. Missing folders are fine: a drive only has SentryClips
+// once Sentry Mode has recorded something.
+func (a *Archive) scan() ([]Event, error) {
+	events := []Event{}
+	for kind, name := range kindDirs {
+		dir := filepath.Join(a.root, name)
+		var found []Event
+		var err error
+		if kind == KindRecent {
+			found, err = a.scanRecent(dir)
+		} else {
+			found, err = a.scanEvents(kind, dir)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("indexing %s: %w", name, err)
+		}
+		events = append(events, found...)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.After(events[j].Timestamp)
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
+
+// maxSegmentGap is the largest gap between the starts of two RecentClips segments that still
+// belong to the same drive. Segments are a minute long, give or take a second.
+const maxSegmentGap = 90 * time.Second
+
+// scanRecent groups the segments in RecentClips into drives: runs of segments that follow each
+// other without a gap. Older firmware writes the clips straight into the folder; newer firmware
+// adds a folder per day, so a drive past midnight spans two folders.
+func (a *Archive) scanRecent(dir string) ([]Event, error) {
+	var clips []Clip
+	var addClips func(dir string, depth int) error
+	addClips = func(dir string, depth int) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				if depth == 0 {
+					if err := addClips(filepath.Join(dir, entry.Name()), depth+1); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if clip, ok := a.clip(dir, entry); ok {
+				clips = append(clips, clip)
+			}
+		}
+		return nil
+	}
+	if err := addClips(dir, 0); err != nil {
+		return nil, err
+	}
+	sort.Slice(clips, func(i, j int) bool { return clips[i].Start.Before(clips[j].Start) })
+
+	var events []Event
+	var current *Event
+	for _, clip := range clips {
+		if current == nil || clip.Start.Sub(current.Clips[len(current.Clips)-1].Start) > maxSegmentGap {
+			if current != nil {
+				events = append(events, finish(*current))
+			}
+			current = &Event{ID: string(KindRecent) + "-" + clip.Start.Format(timestampLayout), Kind: KindRecent, Timestamp: clip.Start}
+		}
+		current.Clips = append(current.Clips, clip)
+	}
+	if current != nil {
+		events = append(events, finish(*current))
+	}
+	return events, nil
+}
+
+// scanEvents reads SavedClips or SentryClips, which hold a folder per event named after the time
+// it was triggered, with the clips leading up to it and an event.json describing it.
+func (a *Archive) scanEvents(kind Kind, dir string) ([]Event, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		e, err := a.readEvent(kind, filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Printf("Skipping TeslaCam event %s: %v", entry.Name(), err)
+			continue
+		}
+		if len(e.Clips) > 0 {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (a *Archive) readEvent(kind Kind, dir string) (Event, error) {
+	name := filepath.Base(dir)
+	e := Event{ID: string(kind) + "-" + name, Kind: kind, dir: dir}
+	if ts, err := time.ParseInLocation(timestampLayout, name, a.opts.Location); err == nil {
+		e.Timestamp = ts
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Event{}, err
+	}
+	for _, entry := range entries {
+		if clip, ok := a.clip(dir, entry); ok {
+			e.Clips = append(e.Clips, clip)
+		}
+	}
+	if err := a.readEventJSON(&e, filepath.Join(dir, "event.json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Event{}, err
+	}
+	if e.Timestamp.IsZero() && len(e.Clips) > 0 {
+		e.Timestamp = e.Clips[0].Start
+	}
+	return finish(e), nil
+}
+
+// eventJSON is the metadata file firmware 10.0 and later write next to saved and sentry clips.
+// Coordinates and the camera are strings.
+type eventJSON struct {
+	Timestamp string `json:"timestamp"` // Local time, e.g. 2024-05-01T10:15:30.
+	City      string `json:"city"`
+	Lat       string `json:"est_lat"`
+	Lon       string `json:"est_lon"`
+	Reason    string `json:"reason"`
+	Camera    string `json:"camera"`
+}
+
+func (a *Archive) readEventJSON(e *Event, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var meta eventJSON
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return fmt.Errorf("reading event.json: %w", err)
+	}
+	if ts, err := time.ParseInLocation("2006-01-02T15:04:05", meta.Timestamp, a.opts.Location); err == nil {
+		e.Timestamp = ts
+	}
+	e.Reason, e.City, e.TriggerCamera = meta.Reason, meta.City, meta.Camera
+	e.Latitude, e.Longitude = parseCoordinate(meta.Lat), parseCoordinate(meta.Lon)
+	return nil
+}
+
+func parseCoordinate(s string) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// clip describes entry, in dir, if it is a TeslaCam clip.
+func (a *Archive) clip(dir string, entry fs.DirEntry) (Clip, bool) {
+	m := clipName.FindStringSubmatch(entry.Name())
+	if m == nil || !entry.Type().IsRegular() {
+		return Clip{}, false
+	}
+	start, err := time.ParseInLocation(timestampLayout, m[1], a.opts.Location)
+	if err != nil {
+		return Clip{}, false
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return Clip{}, false
+	}
+	return Clip{Name: entry.Name(), Camera: m[2], Start: start, Size: info.Size(), path: filepath.Join(dir, entry.Name())}, true
+}
+
+// finish sorts e's clips by time and camera and fills in the cameras that recorded it.
+func finish(e Event) Event {
+	sort.Slice(e.Clips, func(i, j int) bool {
+		if !e.Clips[i].Start.Equal(e.Clips[j].Start) {
+			return e.Clips[i].Start.Before(e.Clips[j].Start)
+		}
+		return e.Clips[i].Camera < e.Clips[j].Camera
+	})
+	e.Cameras = []string{}
+	for _, c := range e.Clips {
+		if !slices.Contains(e.Cameras, c.Camera) {
+			e.Cameras = append(e.Cameras, c.Camera)
+		}
+	}
+	sort.Strings(e.Cameras)
+	return e
+}
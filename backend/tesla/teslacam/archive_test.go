@@ -0,0 +1,159 @@
+package teslacam
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeFiles creates each file under root with its contents.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestArchive(t *testing.T) (*Archive, string) {
+	t.Helper()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"RecentClips/2024-05-01_09-00-00-front.mp4":                            "recent front",
+		"RecentClips/2024-05-01_09-00-00-back.mp4":                             "recent back",
+		"RecentClips/2024-05-02/2024-05-02_08-00-00-front.mp4":                 "newer firmware",
+		"RecentClips/notes.txt":                                                "not a clip",
+		"SavedClips/2024-05-01_10-15-30/2024-05-01_10-14-30-front.mp4":         "saved front",
+		"SavedClips/2024-05-01_10-15-30/2024-05-01_10-14-30-left_repeater.mp4": "saved left",
+		"SavedClips/2024-05-01_10-15-30/2024-05-01_10-13-30-front.mp4":         "saved earlier",
+		"SavedClips/2024-05-01_10-15-30/thumb.png":                             "png",
+		"SavedClips/2024-05-01_10-15-30/event.json": `{"timestamp":"2024-05-01T10:15:31","city":"Palo Alto",` +
+			`"est_lat":"37.4419","est_lon":"-122.1430","reason":"user_interaction_dashcam_icon_tapped","camera":"0"}`,
+		"SentryClips/2024-05-03_22-01-02/2024-05-03_22-00-00-back.mp4": "sentry back",
+		"SentryClips/2024-05-04_00-00-00/event.json":                   `{"reason":"sentry_aware_object_detection"}`,
+	})
+	return openTestArchive(t, root), root
+}
+
+func openTestArchive(t *testing.T, root string) *Archive {
+	t.Helper()
+	opts := DefaultArchiveOptions()
+	opts.Location = time.UTC
+	opts.ThumbnailDir = t.TempDir()
+	opts.FFmpeg = ""
+	archive, err := NewArchive(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func TestArchive_Events(t *testing.T) {
+	archive, _ := newTestArchive(t)
+	events, err := archive.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	// Newest first; the sentry folder without clips is left out.
+	want := []string{"sentry-2024-05-03_22-01-02", "recent-2024-05-02_08-00-00", "saved-2024-05-01_10-15-30", "recent-2024-05-01_09-00-00"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("event IDs = %v, want %v", ids, want)
+	}
+
+	saved := events[2]
+	lat, lon := 37.4419, -122.1430
+	if saved.Kind != KindSaved || saved.Reason != "user_interaction_dashcam_icon_tapped" || saved.City != "Palo Alto" ||
+		!reflect.DeepEqual(saved.Latitude, &lat) || !reflect.DeepEqual(saved.Longitude, &lon) || saved.TriggerCamera != "0" {
+		t.Errorf("saved event metadata = %+v", saved)
+	}
+	if want := time.Date(2024, 5, 1, 10, 15, 31, 0, time.UTC); !saved.Timestamp.Equal(want) {
+		t.Errorf("saved event timestamp = %v, want %v from event.json", saved.Timestamp, want)
+	}
+	if want := []string{"front", "left_repeater"}; !reflect.DeepEqual(saved.Cameras, want) {
+		t.Errorf("saved event cameras = %v, want %v", saved.Cameras, want)
+	}
+	var clips []string
+	for _, c := range saved.Clips {
+		clips = append(clips, c.Name)
+	}
+	wantClips := []string{"2024-05-01_10-13-30-front.mp4", "2024-05-01_10-14-30-front.mp4", "2024-05-01_10-14-30-left_repeater.mp4"}
+	if !reflect.DeepEqual(clips, wantClips) {
+		t.Errorf("saved event clips = %v, want %v", clips, wantClips)
+	}
+
+	recent := events[3]
+	if recent.Kind != KindRecent || !reflect.DeepEqual(recent.Cameras, []string{"back", "front"}) || recent.Clips[0].Size != int64(len("recent back")) {
+		t.Errorf("recent event = %+v", recent)
+	}
+	if sentry := events[0]; sentry.Reason != "" || !sentry.Timestamp.Equal(time.Date(2024, 5, 3, 22, 1, 2, 0, time.UTC)) {
+		t.Errorf("sentry event without event.json = %+v, want the folder's timestamp", sentry)
+	}
+}
+
+func TestArchive_OpenClip(t *testing.T) {
+	archive, _ := newTestArchive(t)
+	f, clip, err := archive.OpenClip("saved-2024-05-01_10-15-30", "2024-05-01_10-14-30-left_repeater.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "saved left" || clip.Camera != "left_repeater" {
+		t.Errorf("OpenClip() = %q, %+v", data, clip)
+	}
+
+	for _, tc := range []struct{ event, name string }{
+		{"saved-2024-05-01_10-15-30", "thumb.png"},
+		{"saved-2024-05-01_10-15-30", "../../RecentClips/2024-05-01_09-00-00-front.mp4"},
+		{"saved-nope", "2024-05-01_10-14-30-front.mp4"},
+	} {
+		if _, _, err := archive.OpenClip(tc.event, tc.name); !errors.Is(err, ErrEventNotFound) {
+			t.Errorf("OpenClip(%q, %q) = %v, want ErrEventNotFound", tc.event, tc.name, err)
+		}
+	}
+}
+
+func TestArchive_Rescans(t *testing.T) {
+	archive, root := newTestArchive(t)
+	now := time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)
+	archive.now = func() time.Time { return now }
+	before, _ := archive.Events()
+
+	writeFiles(t, root, map[string]string{"RecentClips/2024-05-05_00-00-00-front.mp4": "new"})
+	if events, _ := archive.Events(); len(events) != len(before) {
+		t.Errorf("Events() within DefaultMaxAge = %d events, want the cached %d", len(events), len(before))
+	}
+	now = now.Add(DefaultMaxAge)
+	if events, _ := archive.Events(); len(events) != len(before)+1 || events[0].ID != "recent-2024-05-05_00-00-00" {
+		t.Errorf("Events() after DefaultMaxAge = %d events, want the new clip first", len(events))
+	}
+}
+
+func TestArchiveFromEnvironment(t *testing.T) {
+	t.Setenv("TESLA_CAMERA_DIR", "")
+	if archive, err := ArchiveFromEnvironment(); archive != nil || err != nil {
+		t.Errorf("ArchiveFromEnvironment() unset = %v, %v; want nil, nil", archive, err)
+	}
+	t.Setenv("TESLA_CAMERA_DIR", filepath.Join(t.TempDir(), "missing"))
+	if _, err := ArchiveFromEnvironment(); err == nil {
+		t.Error("ArchiveFromEnvironment() with a missing directory succeeded, want an error")
+	}
+	t.Setenv("TESLA_CAMERA_DIR", t.TempDir())
+	t.Setenv("TESLA_CAMERA_TZ", "Mars/Olympus_Mons")
+	if _, err := ArchiveFromEnvironment(); err == nil {
+		t.Error("ArchiveFromEnvironment() with an unknown zone succeeded, want an error")
+	}
+}
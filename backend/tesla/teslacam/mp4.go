@@ -0,0 +1,107 @@
+package teslacam
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// errBoxNotFound is returned for a file without a box it should have, e.g. the moov box of a
+// clip the vehicle was still writing when the drive was unplugged.
+var errBoxNotFound = errors.New("mp4: box not found")
+
+// clipDuration reads the duration of the MP4 file at path from its movie header, without
+// decoding any video.
+func clipDuration(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	moov, err := findBox(f, 0, info.Size(), "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, err := findBox(f, moov.body, moov.end, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+	return readMovieHeader(io.NewSectionReader(f, mvhd.body, mvhd.end-mvhd.body))
+}
+
+// box locates an MP4 box: its contents run from body to end.
+type box struct {
+	body, end int64
+}
+
+// findBox returns the first box of the given type among the boxes between start and end.
+func findBox(r io.ReaderAt, start, end int64, boxType string) (box, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return box{}, fmt.Errorf("mp4: reading box header: %w", err)
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0: // The box runs to the end of its parent.
+			size = end - offset
+		case 1: // A 64-bit size follows the type.
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return box{}, fmt.Errorf("mp4: reading box size: %w", err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize || offset+size > end {
+			return box{}, fmt.Errorf("mp4: box %q at %d has invalid size %d", header[4:8], offset, size)
+		}
+		if string(header[4:8]) == boxType {
+			return box{body: offset + headerSize, end: offset + size}, nil
+		}
+		offset += size
+	}
+	return box{}, fmt.Errorf("%w: %s", errBoxNotFound, boxType)
+}
+
+// readMovieHeader reads the duration from the body of an mvhd box.
+func readMovieHeader(r io.Reader) (time.Duration, error) {
+	var version [4]byte // Version and flags.
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return 0, fmt.Errorf("mp4: reading movie header: %w", err)
+	}
+	var timescale uint32
+	var duration uint64
+	if version[0] == 1 {
+		var h struct {
+			Created, Modified uint64
+			Timescale         uint32
+			Duration          uint64
+		}
+		if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+			return 0, fmt.Errorf("mp4: reading movie header: %w", err)
+		}
+		timescale, duration = h.Timescale, h.Duration
+	} else {
+		var h struct {
+			Created, Modified uint32
+			Timescale         uint32
+			Duration          uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+			return 0, fmt.Errorf("mp4: reading movie header: %w", err)
+		}
+		timescale, duration = h.Timescale, uint64(h.Duration)
+	}
+	if timescale == 0 {
+		return 0, errors.New("mp4: movie header has no timescale")
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), nil
+}
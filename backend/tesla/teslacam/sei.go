@@ -0,0 +1,248 @@
+package teslacam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// H.264 NAL unit types and SEI payload types used below.
+const (
+	nalSlice    = 1
+	nalSliceIDR = 5
+	nalSEI      = 6
+
+	seiUserDataUnregistered = 5
+)
+
+// rawSample is the SEI metadata of one frame, before it is placed on the clip's timeline.
+type rawSample struct {
+	frame int // Index of the frame the metadata precedes.
+	meta  seiMetadata
+}
+
+// readSEI walks the video samples in the mdat boxes of the MP4 file at path and decodes the
+// telemetry SEI messages between them. It also returns the number of frames, so samples can be
+// spread over the clip's duration. Tesla writes video-only files with 4-byte NAL lengths, which
+// lets the media data be read as one stream of NAL units without the sample tables.
+func readSEI(path string) (samples []rawSample, frames int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	for offset := int64(0); offset < info.Size(); {
+		mdat, err := findBox(f, offset, info.Size(), "mdat")
+		if errors.Is(err, errBoxNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		r := bufio.NewReader(io.NewSectionReader(f, mdat.body, mdat.end-mdat.body))
+		if err := readNALUnits(r, &samples, &frames); err != nil {
+			return nil, 0, err
+		}
+		offset = mdat.end
+	}
+	return samples, frames, nil
+}
+
+func readNALUnits(r *bufio.Reader, samples *[]rawSample, frames *int) error {
+	var length [4]byte
+	for {
+		if _, err := io.ReadFull(r, length[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("mp4: reading NAL unit length: %w", err)
+		}
+		n := int(binary.BigEndian.Uint32(length[:]))
+		if n == 0 {
+			continue
+		}
+		header, err := r.Peek(min(n, 2))
+		if err != nil {
+			return fmt.Errorf("mp4: reading NAL unit: %w", err)
+		}
+		switch header[0] & 0x1f {
+		case nalSEI:
+			nal := make([]byte, n)
+			if _, err := io.ReadFull(r, nal); err != nil {
+				return fmt.Errorf("mp4: reading SEI: %w", err)
+			}
+			if meta, ok := parseSEI(nal); ok {
+				*samples = append(*samples, rawSample{frame: *frames, meta: meta})
+			}
+			continue
+		case nalSlice, nalSliceIDR:
+			// first_mb_in_slice is ue(v); it is 0, the start of a new frame, when its first
+			// bit is set.
+			if len(header) > 1 && header[1]&0x80 != 0 {
+				*frames++
+			}
+		}
+		if _, err := r.Discard(n); err != nil {
+			return fmt.Errorf("mp4: skipping NAL unit: %w", err)
+		}
+	}
+}
+
+// parseSEI returns the Tesla metadata in an SEI NAL unit, if it carries any.
+func parseSEI(nal []byte) (seiMetadata, bool) {
+	rbsp := unescapeRBSP(nal[1:])
+	for len(rbsp) > 1 { // The last byte holds the RBSP stop bit.
+		payloadType, n := seiValue(rbsp)
+		rbsp = rbsp[n:]
+		size, n := seiValue(rbsp)
+		rbsp = rbsp[n:]
+		if size > len(rbsp) {
+			return seiMetadata{}, false
+		}
+		payload := rbsp[:size]
+		rbsp = rbsp[size:]
+		if payloadType != seiUserDataUnregistered {
+			continue
+		}
+		// Instead of a registered UUID, Tesla marks its payload with a run of 'B's and an 'i'.
+		data := bytes.TrimLeft(payload, "B")
+		if len(data) == 0 || data[0] != 'i' || len(data) == len(payload) {
+			continue
+		}
+		if meta, err := decodeSEIMetadata(data[1:]); err == nil {
+			return meta, true
+		}
+	}
+	return seiMetadata{}, false
+}
+
+// seiValue reads an SEI payload type or size: a run of 0xFF bytes, each adding 255, and a final
+// byte.
+func seiValue(b []byte) (value, n int) {
+	for n < len(b) {
+		value += int(b[n])
+		n++
+		if b[n-1] != 0xff {
+			break
+		}
+	}
+	return value, n
+}
+
+// unescapeRBSP removes the emulation prevention bytes (the 3 in 00 00 03) an encoder inserts so
+// that a NAL unit never contains a start code.
+func unescapeRBSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// seiMetadata is Tesla's per-frame dashcam metadata, decoded from its protobuf encoding by
+// field number so the backend does not need generated code for it.
+type seiMetadata struct {
+	Version          uint64
+	Gear             uint64 // 0 park, 1 drive, 2 reverse, 3 neutral.
+	FrameSeq         uint64
+	SpeedMPS         float32
+	AcceleratorPedal float32 // Percent.
+	SteeringAngle    float32 // Degrees.
+	BlinkerLeft      bool
+	BlinkerRight     bool
+	BrakeApplied     bool
+	Autopilot        uint64 // 0 none, 1 self driving, 2 autosteer, 3 traffic-aware cruise control.
+	Latitude         float64
+	Longitude        float64
+	Heading          float64
+}
+
+func decodeSEIMetadata(b []byte) (seiMetadata, error) {
+	var m seiMetadata
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return m, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return m, protowire.ParseError(n)
+			}
+			b = b[n:]
+			switch num {
+			case 1:
+				m.Version = v
+			case 2:
+				m.Gear = v
+			case 3:
+				m.FrameSeq = v
+			case 7:
+				m.BlinkerLeft = v != 0
+			case 8:
+				m.BlinkerRight = v != 0
+			case 9:
+				m.BrakeApplied = v != 0
+			case 10:
+				m.Autopilot = v
+			}
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return m, protowire.ParseError(n)
+			}
+			b = b[n:]
+			switch f := math.Float32frombits(v); num {
+			case 4:
+				m.SpeedMPS = f
+			case 5:
+				m.AcceleratorPedal = f
+			case 6:
+				m.SteeringAngle = f
+			}
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return m, protowire.ParseError(n)
+			}
+			b = b[n:]
+			switch f := math.Float64frombits(v); num {
+			case 11:
+				m.Latitude = f
+			case 12:
+				m.Longitude = f
+			case 13:
+				m.Heading = f
+			}
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return m, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,250 @@
+package teslacam
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// ErrNoTelemetry is returned for a clip without telemetry, such as one recorded before the
+// vehicle's firmware started embedding it.
+var ErrNoTelemetry = errors.New("clip has no telemetry")
+
+const mpsToMPH = 2.2369362920544
+
+// gears and autopilotStates name the enum values of Tesla's SEI metadata. Gears use the letters
+// the vehicle state reports as its shift state.
+var (
+	gears           = []string{"P", "D", "R", "N"}
+	autopilotStates = []string{"none", "self_driving", "autosteer", "tacc"}
+)
+
+func enumName(names []string, v uint64) string {
+	if v < uint64(len(names)) {
+		return names[v]
+	}
+	return fmt.Sprintf("unknown_%d", v)
+}
+
+// TelemetrySample is the vehicle's state at one frame of a clip.
+type TelemetrySample struct {
+	OffsetSeconds       float64   `json:"offset_seconds"` // From the start of the clip.
+	Time                time.Time `json:"time"`
+	FrameSeq            uint64    `json:"frame_seq"`
+	SpeedMPS            float64   `json:"speed_mps"`
+	SpeedMPH            float64   `json:"speed_mph"`
+	Gear                string    `json:"gear"`
+	SteeringAngleDeg    float64   `json:"steering_angle_deg"`
+	AcceleratorPedalPct float64   `json:"accelerator_pedal_percent"`
+	BrakeApplied        bool      `json:"brake_applied"`
+	BlinkerLeft         bool      `json:"blinker_left"`
+	BlinkerRight        bool      `json:"blinker_right"`
+	Autopilot           string    `json:"autopilot"` // none, self_driving, autosteer, tacc
+	// Latitude and Longitude are null until the vehicle has a GPS fix.
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+	HeadingDeg float64  `json:"heading_deg"`
+}
+
+// ClipTelemetry is the telemetry of one clip, one sample per frame that carried any.
+type ClipTelemetry struct {
+	EventID string            `json:"event_id"`
+	Clip    string            `json:"clip"`
+	Start   time.Time         `json:"start"`
+	Samples []TelemetrySample `json:"samples"`
+}
+
+// TelemetrySummary overlays an event with how the vehicle was driven during it.
+type TelemetrySummary struct {
+	Samples         int     `json:"samples"`
+	MaxSpeedMPH     float64 `json:"max_speed_mph"`
+	AverageSpeedMPH float64 `json:"average_speed_mph"`
+	// TriggerSpeedMPH is the speed when a saved or sentry event was triggered, if a clip covers
+	// that moment.
+	TriggerSpeedMPH *float64 `json:"trigger_speed_mph,omitempty"`
+	// Autopilot lists the driver assistance states other than none seen during the event.
+	Autopilot []string `json:"autopilot"`
+}
+
+// cachedTelemetry is the parsed telemetry of a clip file, valid while the file is unchanged.
+type cachedTelemetry struct {
+	size    int64
+	modTime time.Time
+	samples []TelemetrySample
+	err     error
+}
+
+// ClipTelemetry extracts the telemetry embedded in a clip of an event. Parsing reads the whole
+// file, so results are cached until the file changes.
+func (a *Archive) ClipTelemetry(eventID, name string) (*ClipTelemetry, error) {
+	f, clip, err := a.OpenClip(eventID, name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	samples, err := a.telemetry(clip)
+	if err != nil {
+		return nil, err
+	}
+	return &ClipTelemetry{EventID: eventID, Clip: name, Start: clip.Start, Samples: samples}, nil
+}
+
+// EventTelemetry summarizes the telemetry of an event from its front camera, which every
+// vehicle records; other cameras carry the same metadata.
+func (a *Archive) EventTelemetry(id string) (*TelemetrySummary, error) {
+	event, err := a.Event(id)
+	if err != nil {
+		return nil, err
+	}
+	summary := &TelemetrySummary{Autopilot: []string{}}
+	var total float64
+	var nearest time.Duration = -1
+	for _, clip := range event.Clips {
+		if clip.Camera != "front" {
+			continue
+		}
+		samples, err := a.telemetry(clip)
+		if errors.Is(err, ErrNoTelemetry) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			summary.Samples++
+			total += s.SpeedMPH
+			summary.MaxSpeedMPH = max(summary.MaxSpeedMPH, s.SpeedMPH)
+			if s.Autopilot != "none" && !slices.Contains(summary.Autopilot, s.Autopilot) {
+				summary.Autopilot = append(summary.Autopilot, s.Autopilot)
+			}
+			if event.Kind != KindRecent {
+				// Timestamps are to the second, so the closest sample within a second counts.
+				if d := s.Time.Sub(event.Timestamp).Abs(); d <= time.Second && (nearest < 0 || d < nearest) {
+					nearest = d
+					speed := s.SpeedMPH
+					summary.TriggerSpeedMPH = &speed
+				}
+			}
+		}
+	}
+	if summary.Samples == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTelemetry, id)
+	}
+	summary.AverageSpeedMPH = total / float64(summary.Samples)
+	return summary, nil
+}
+
+// telemetry returns the samples of clip, parsing the file unless the cache is current.
+func (a *Archive) telemetry(clip Clip) ([]TelemetrySample, error) {
+	info, err := os.Stat(clip.path)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	cached, ok := a.telemetryCache[clip.path]
+	a.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.samples, cached.err
+	}
+
+	samples, err := parseTelemetry(clip)
+	a.mu.Lock()
+	if a.telemetryCache == nil {
+		a.telemetryCache = make(map[string]cachedTelemetry)
+	}
+	a.telemetryCache[clip.path] = cachedTelemetry{size: info.Size(), modTime: info.ModTime(), samples: samples, err: err}
+	a.mu.Unlock()
+	return samples, err
+}
+
+// parseTelemetry places the SEI samples of clip on its timeline. A sample's offset is that of
+// its frame, assuming a constant frame rate over the clip's duration.
+func parseTelemetry(clip Clip) ([]TelemetrySample, error) {
+	raw, frames, err := readSEI(clip.path)
+	if err != nil {
+		// A clip the vehicle was still writing, or a damaged one, has no telemetry to offer.
+		return nil, fmt.Errorf("%w: %s: %v", ErrNoTelemetry, clip.Name, err)
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTelemetry, clip.Name)
+	}
+	duration, err := clipDuration(clip.path)
+	if err != nil || frames == 0 {
+		duration, frames = defaultSegmentDuration, max(frames, len(raw))
+	}
+	frameDuration := duration / time.Duration(frames)
+
+	samples := make([]TelemetrySample, 0, len(raw))
+	for _, r := range raw {
+		offset := time.Duration(r.frame) * frameDuration
+		s := TelemetrySample{
+			OffsetSeconds:       offset.Seconds(),
+			Time:                clip.Start.Add(offset),
+			FrameSeq:            r.meta.FrameSeq,
+			SpeedMPS:            float64(r.meta.SpeedMPS),
+			SpeedMPH:            float64(r.meta.SpeedMPS) * mpsToMPH,
+			Gear:                enumName(gears, r.meta.Gear),
+			SteeringAngleDeg:    float64(r.meta.SteeringAngle),
+			AcceleratorPedalPct: float64(r.meta.AcceleratorPedal),
+			BrakeApplied:        r.meta.BrakeApplied,
+			BlinkerLeft:         r.meta.BlinkerLeft,
+			BlinkerRight:        r.meta.BlinkerRight,
+			Autopilot:           enumName(autopilotStates, r.meta.Autopilot),
+			HeadingDeg:          r.meta.Heading,
+		}
+		if r.meta.Latitude != 0 || r.meta.Longitude != 0 {
+			lat, lon := r.meta.Latitude, r.meta.Longitude
+			s.Latitude, s.Longitude = &lat, &lon
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// gpx is the subset of GPX 1.1 needed for a track.
+type gpx struct {
+	XMLName xml.Name `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Track   gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Segment []gpxTrkpt `xml:"trkseg>trkpt"`
+}
+
+type gpxTrkpt struct {
+	Lat  float64   `xml:"lat,attr"`
+	Lon  float64   `xml:"lon,attr"`
+	Time time.Time `xml:"time"`
+}
+
+// WriteGPX writes the clip's GPS positions as a GPX track. Frames repeat a position until the
+// next GPS fix, so only changes are written.
+func (t *ClipTelemetry) WriteGPX(w io.Writer) error {
+	doc := gpx{Version: "1.1", Creator: "tesla-backend", Track: gpxTrack{Name: t.Clip, Segment: []gpxTrkpt{}}}
+	for _, s := range t.Samples {
+		if s.Latitude == nil {
+			continue
+		}
+		if n := len(doc.Track.Segment); n > 0 && doc.Track.Segment[n-1].Lat == *s.Latitude && doc.Track.Segment[n-1].Lon == *s.Longitude {
+			continue
+		}
+		doc.Track.Segment = append(doc.Track.Segment, gpxTrkpt{Lat: *s.Latitude, Lon: *s.Longitude, Time: s.Time.UTC()})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,168 @@
+package teslacam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodeSEIMetadata encodes m the way the vehicle does.
+func encodeSEIMetadata(m seiMetadata) []byte {
+	var b []byte
+	varint := func(num protowire.Number, v uint64) {
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		b = protowire.AppendVarint(b, v)
+	}
+	float := func(num protowire.Number, f float32) {
+		b = protowire.AppendTag(b, num, protowire.Fixed32Type)
+		b = protowire.AppendFixed32(b, math.Float32bits(f))
+	}
+	double := func(num protowire.Number, f float64) {
+		b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(f))
+	}
+	varint(1, m.Version)
+	varint(2, m.Gear)
+	varint(3, m.FrameSeq)
+	float(4, m.SpeedMPS)
+	float(6, m.SteeringAngle)
+	if m.BrakeApplied {
+		varint(9, 1)
+	}
+	varint(10, m.Autopilot)
+	double(11, m.Latitude)
+	double(12, m.Longitude)
+	// A field this parser does not know about, as newer firmware may add.
+	b = protowire.AppendTag(b, 99, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte("future"))
+	return b
+}
+
+// escapeRBSP inserts emulation prevention bytes, as an encoder does.
+func escapeRBSP(b []byte) []byte {
+	var out []byte
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// seiNAL wraps m in a user data SEI NAL unit with Tesla's marker.
+func seiNAL(m seiMetadata) []byte {
+	payload := append([]byte("BBBBBBBBBBBBBBBi"), encodeSEIMetadata(m)...)
+	rbsp := []byte{seiUserDataUnregistered}
+	for n := len(payload); ; n -= 255 {
+		if n < 255 {
+			rbsp = append(rbsp, byte(n))
+			break
+		}
+		rbsp = append(rbsp, 0xff)
+	}
+	rbsp = append(append(rbsp, payload...), 0x80)
+	return append([]byte{nalSEI}, escapeRBSP(rbsp)...)
+}
+
+// fakeTelemetryMP4 returns a clip of the given duration with one frame per sample, each preceded
+// by its SEI, and an extra frame without any.
+func fakeTelemetryMP4(duration time.Duration, samples ...seiMetadata) string {
+	var mdat []byte
+	nal := func(b []byte) {
+		mdat = binary.BigEndian.AppendUint32(mdat, uint32(len(b)))
+		mdat = append(mdat, b...)
+	}
+	frame := []byte{0x65, 0x88, 0x84, 0x00} // IDR slice starting a frame.
+	for _, m := range samples {
+		nal(seiNAL(m))
+		nal(frame)
+		nal([]byte{0x65, 0x48, 0x00}) // Second slice of the same frame.
+	}
+	nal(frame)
+	moov := []byte(fakeMP4(duration))
+	moov = moov[bytes.Index(moov, []byte("moov"))-4:]
+	return string(bytes.Join([][]byte{mp4Box("ftyp", []byte("isom")), mp4Box("mdat", mdat), moov}, nil))
+}
+
+func TestParseSEI(t *testing.T) {
+	// 2 m/s encodes as 00 00 00 40, so the NAL unit needs emulation prevention.
+	want := seiMetadata{Version: 1, Gear: 1, FrameSeq: 65536, SpeedMPS: 2, SteeringAngle: -12.25, BrakeApplied: true, Autopilot: 2, Latitude: 37.4419, Longitude: -122.143}
+	nal := seiNAL(want)
+	if !bytes.Contains(nal, []byte{0, 0, 3}) {
+		t.Fatal("test SEI needs an emulation prevention byte")
+	}
+	if got, ok := parseSEI(nal); !ok || got != want {
+		t.Errorf("parseSEI() = %+v, %v; want %+v", got, ok, want)
+	}
+
+	other := append([]byte{nalSEI, seiUserDataUnregistered, 20}, bytes.Repeat([]byte{0x11}, 20)...)
+	if _, ok := parseSEI(append(other, 0x80)); ok {
+		t.Error("parseSEI() accepted user data from another encoder")
+	}
+}
+
+func TestArchive_ClipTelemetry(t *testing.T) {
+	root := t.TempDir()
+	dir := "SavedClips/2024-05-01_10-15-02/"
+	writeFiles(t, root, map[string]string{
+		dir + "2024-05-01_10-15-00-front.mp4": fakeTelemetryMP4(4*time.Second,
+			seiMetadata{FrameSeq: 1, SpeedMPS: 10, Latitude: 37.1, Longitude: -122.1},
+			seiMetadata{FrameSeq: 2, SpeedMPS: 20, Autopilot: 3, Latitude: 37.1, Longitude: -122.1},
+			seiMetadata{FrameSeq: 3, SpeedMPS: 30, Gear: 2, Latitude: 37.2, Longitude: -122.2},
+		),
+		dir + "2024-05-01_10-15-00-back.mp4": fakeMP4(4 * time.Second),
+	})
+	archive := openTestArchive(t, root)
+
+	telemetry, err := archive.ClipTelemetry("saved-2024-05-01_10-15-02", "2024-05-01_10-15-00-front.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(telemetry.Samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(telemetry.Samples))
+	}
+	// Four frames over four seconds.
+	last := telemetry.Samples[2]
+	if last.OffsetSeconds != 2 || !last.Time.Equal(time.Date(2024, 5, 1, 10, 15, 2, 0, time.UTC)) || last.Gear != "R" ||
+		math.Abs(last.SpeedMPH-67.108) > 0.001 || *last.Latitude != 37.2 {
+		t.Errorf("last sample = %+v", last)
+	}
+	if telemetry.Samples[1].Autopilot != "tacc" {
+		t.Errorf("second sample autopilot = %q, want tacc", telemetry.Samples[1].Autopilot)
+	}
+
+	var gpx strings.Builder
+	if err := telemetry.WriteGPX(&gpx); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(gpx.String(), "<trkpt"); n != 2 || !strings.Contains(gpx.String(), `<trkpt lat="37.2" lon="-122.2">`) {
+		t.Errorf("GPX has %d points, want the two distinct positions:\n%s", n, gpx.String())
+	}
+
+	if _, err := archive.ClipTelemetry("saved-2024-05-01_10-15-02", "2024-05-01_10-15-00-back.mp4"); !errors.Is(err, ErrNoTelemetry) {
+		t.Errorf("ClipTelemetry() of a clip without SEI = %v, want ErrNoTelemetry", err)
+	}
+
+	summary, err := archive.EventTelemetry("saved-2024-05-01_10-15-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Samples != 3 || math.Abs(summary.MaxSpeedMPH-67.108) > 0.001 || summary.TriggerSpeedMPH == nil ||
+		*summary.TriggerSpeedMPH != summary.MaxSpeedMPH || len(summary.Autopilot) != 1 {
+		t.Errorf("EventTelemetry() = %+v, want the speed at 10:15:02 and tacc", summary)
+	}
+}
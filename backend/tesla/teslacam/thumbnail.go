@@ -0,0 +1,69 @@
+package teslacam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// ErrNoThumbnail is returned for an event without a thumbnail of its own when ffmpeg is not
+// available to extract one.
+var ErrNoThumbnail = errors.New("no thumbnail available for this camera event")
+
+// thumbnailOffset is where in the first clip the thumbnail frame is taken, past the dark first
+// frames some cameras record.
+const thumbnailOffset = "1"
+
+// Thumbnail returns the path of a thumbnail image for the event with the given ID: the thumb.png
+// the vehicle saves with saved and sentry events, or else a frame of the event's first front
+// clip, extracted with ffmpeg and cached in ThumbnailDir.
+func (a *Archive) Thumbnail(ctx context.Context, id string) (string, error) {
+	event, err := a.Event(id)
+	if err != nil {
+		return "", err
+	}
+	if event.dir != "" {
+		thumb := filepath.Join(event.dir, "thumb.png")
+		if _, err := os.Stat(thumb); err == nil {
+			return thumb, nil
+		}
+	}
+
+	cached := filepath.Join(a.opts.ThumbnailDir, event.ID+".jpg")
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+	ffmpeg, err := exec.LookPath(a.opts.FFmpeg)
+	if a.opts.FFmpeg == "" || err != nil {
+		return "", fmt.Errorf("%w: install ffmpeg to generate one", ErrNoThumbnail)
+	}
+	if err := os.MkdirAll(a.opts.ThumbnailDir, 0755); err != nil {
+		return "", fmt.Errorf("creating thumbnail cache: %w", err)
+	}
+
+	clip := event.Clips[0]
+	if i := slices.IndexFunc(event.Clips, func(c Clip) bool { return c.Camera == "front" }); i >= 0 {
+		clip = event.Clips[i]
+	}
+	// Write to a temporary file first so a concurrent request never serves half an image.
+	tmp := filepath.Join(a.opts.ThumbnailDir, fmt.Sprintf(".%s-%d.jpg", event.ID, os.Getpid()))
+	cmd := exec.CommandContext(ctx, ffmpeg, "-y", "-loglevel", "error", "-ss", thumbnailOffset, "-i", clip.path,
+		"-frames:v", "1", "-vf", "scale=320:-2", tmp)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("extracting thumbnail from %s: %w: %s", clip.Name, err, out)
+	}
+	if err := os.Rename(tmp, cached); err != nil {
+		os.Remove(tmp)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("extracting thumbnail from %s: ffmpeg wrote no image", clip.Name)
+		}
+		return "", err
+	}
+	return cached, nil
+}
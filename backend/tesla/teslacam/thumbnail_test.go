@@ -0,0 +1,61 @@
+package teslacam
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestArchive_Thumbnail(t *testing.T) {
+	archive, root := newTestArchive(t)
+	ctx := context.Background()
+
+	if _, err := archive.Thumbnail(ctx, "saved-2024-05-01_10-15-30"); err != nil {
+		t.Errorf("Thumbnail() of an event with thumb.png = %v", err)
+	}
+	if path, _ := archive.Thumbnail(ctx, "saved-2024-05-01_10-15-30"); path != filepath.Join(root, "SavedClips", "2024-05-01_10-15-30", "thumb.png") {
+		t.Errorf("Thumbnail() = %q, want the vehicle's thumb.png", path)
+	}
+	if _, err := archive.Thumbnail(ctx, "recent-2024-05-01_09-00-00"); !errors.Is(err, ErrNoThumbnail) {
+		t.Errorf("Thumbnail() without ffmpeg = %v, want ErrNoThumbnail", err)
+	}
+	if _, err := archive.Thumbnail(ctx, "recent-nope"); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Thumbnail() of an unknown event = %v, want ErrEventNotFound", err)
+	}
+}
+
+func TestArchive_ThumbnailWithFFmpeg(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	archive, _ := newTestArchive(t)
+	// The fake writes its arguments to the output file, which ffmpeg takes last.
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\nfor last; do :; done\necho \"$@\" > \"$last\"\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	archive.opts.FFmpeg = ffmpeg
+
+	path, err := archive.Thumbnail(context.Background(), "recent-2024-05-01_09-00-00")
+	if err != nil {
+		t.Fatalf("Thumbnail() = %v", err)
+	}
+	args, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-05-01_09-00-00-front.mp4"; !strings.Contains(string(args), want) {
+		t.Errorf("ffmpeg was run with %q, want the front clip %s", args, want)
+	}
+
+	// The second request is served from the cache.
+	archive.opts.FFmpeg = ""
+	if cached, err := archive.Thumbnail(context.Background(), "recent-2024-05-01_09-00-00"); cached != path || err != nil {
+		t.Errorf("Thumbnail() again = %q, %v; want the cached %q", cached, err, path)
+	}
+}
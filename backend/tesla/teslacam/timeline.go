@@ -0,0 +1,136 @@
+package teslacam
+
+import (
+	"slices"
+	"time"
+)
+
+// defaultSegmentDuration is assumed for a segment whose length cannot be read or inferred.
+const defaultSegmentDuration = time.Minute
+
+// stitchTolerance is how far apart the end of one file and the start of the next may be for a
+// player to treat them as one continuous recording.
+const stitchTolerance = 2 * time.Second
+
+// Timeline is the playback manifest of an event. Offsets are seconds from Start, so a player can
+// keep every camera on the same clock.
+type Timeline struct {
+	EventID         string    `json:"event_id"`
+	Start           time.Time `json:"start"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Cameras         []string  `json:"cameras"`
+	// Segments are the minute-long slices of the event, each with every angle recorded for it.
+	Segments []Segment `json:"segments"`
+	// Tracks stitch each camera's consecutive files into continuous ranges.
+	Tracks []Track `json:"tracks"`
+}
+
+// Segment is one slice of the timeline and the clip each camera recorded for it.
+type Segment struct {
+	OffsetSeconds   float64        `json:"offset_seconds"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Clips           []TimelineClip `json:"clips"`
+}
+
+// TimelineClip places one clip on the timeline.
+type TimelineClip struct {
+	Clip
+	OffsetSeconds   float64 `json:"offset_seconds"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	URL             string  `json:"url,omitempty"` // Filled in by the server that serves the clip.
+}
+
+// Track is one camera's recording of the event.
+type Track struct {
+	Camera string  `json:"camera"`
+	Ranges []Range `json:"ranges"`
+}
+
+// Range is a stretch of a track with no gap in it: its clips play back to back. A camera that
+// missed a segment has one range on each side of the gap.
+type Range struct {
+	StartSeconds float64        `json:"start_seconds"`
+	EndSeconds   float64        `json:"end_seconds"`
+	Clips        []TimelineClip `json:"clips"`
+}
+
+// Timeline builds the playback manifest of the event with the given ID. Clip durations come from
+// the files' MP4 headers.
+func (a *Archive) Timeline(id string) (*Timeline, error) {
+	event, err := a.Event(id)
+	if err != nil {
+		return nil, err
+	}
+	return newTimeline(event), nil
+}
+
+func newTimeline(event Event) *Timeline {
+	// Group the clips by start time; event.Clips is already sorted by time, then camera.
+	var starts []time.Time
+	bySegment := make(map[time.Time][]Clip)
+	for _, clip := range event.Clips {
+		if len(starts) == 0 || !starts[len(starts)-1].Equal(clip.Start) {
+			starts = append(starts, clip.Start)
+		}
+		bySegment[clip.Start] = append(bySegment[clip.Start], clip)
+	}
+
+	t := &Timeline{EventID: event.ID, Cameras: event.Cameras, Segments: []Segment{}, Tracks: []Track{}}
+	if len(starts) == 0 {
+		return t
+	}
+	t.Start = starts[0]
+	var end time.Duration
+	for i, start := range starts {
+		segment := Segment{OffsetSeconds: start.Sub(t.Start).Seconds()}
+		var segmentDuration time.Duration
+		for _, clip := range bySegment[start] {
+			d, err := clipDuration(clip.path)
+			if err != nil {
+				d = 0 // Truncated or still being written; inferred below.
+			}
+			segmentDuration = max(segmentDuration, d)
+			segment.Clips = append(segment.Clips, TimelineClip{Clip: clip, OffsetSeconds: segment.OffsetSeconds, DurationSeconds: d.Seconds()})
+		}
+		if segmentDuration == 0 {
+			// No readable header: assume the segment lasts until the next one starts.
+			segmentDuration = defaultSegmentDuration
+			if i+1 < len(starts) {
+				segmentDuration = starts[i+1].Sub(start)
+			}
+		}
+		for j := range segment.Clips {
+			if segment.Clips[j].DurationSeconds == 0 {
+				segment.Clips[j].DurationSeconds = segmentDuration.Seconds()
+			}
+		}
+		segment.DurationSeconds = segmentDuration.Seconds()
+		t.Segments = append(t.Segments, segment)
+		end = max(end, start.Sub(t.Start)+segmentDuration)
+	}
+	t.DurationSeconds = end.Seconds()
+
+	for _, camera := range t.Cameras {
+		track := Track{Camera: camera, Ranges: []Range{}}
+		for _, segment := range t.Segments {
+			i := slices.IndexFunc(segment.Clips, func(c TimelineClip) bool { return c.Camera == camera })
+			if i < 0 {
+				continue
+			}
+			clip := segment.Clips[i]
+			last := len(track.Ranges) - 1
+			if last >= 0 && clip.OffsetSeconds-track.Ranges[last].EndSeconds <= stitchTolerance.Seconds() {
+				track.Ranges[last].Clips = append(track.Ranges[last].Clips, clip)
+				track.Ranges[last].EndSeconds = clip.OffsetSeconds + clip.DurationSeconds
+				continue
+			}
+			track.Ranges = append(track.Ranges, Range{
+				StartSeconds: clip.OffsetSeconds,
+				EndSeconds:   clip.OffsetSeconds + clip.DurationSeconds,
+				Clips:        []TimelineClip{clip},
+			})
+		}
+		t.Tracks = append(t.Tracks, track)
+	}
+	return t
+}
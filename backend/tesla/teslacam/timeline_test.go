@@ -0,0 +1,116 @@
+package teslacam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mp4Box encodes one MP4 box.
+func mp4Box(boxType string, body ...[]byte) []byte {
+	contents := bytes.Join(body, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(contents)))
+	return append(append(b, boxType...), contents...)
+}
+
+// fakeMP4 returns a file with just enough structure for clipDuration: an ftyp box, some media
+// data, and a version 0 movie header.
+func fakeMP4(duration time.Duration) string {
+	var mvhd []byte
+	mvhd = append(mvhd, 0, 0, 0, 0)                  // Version and flags.
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 0)    // Created.
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 0)    // Modified.
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 1000) // Timescale.
+	mvhd = binary.BigEndian.AppendUint32(mvhd, uint32(duration.Milliseconds()))
+	return string(bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("isom")),
+		mp4Box("mdat", make([]byte, 100)),
+		mp4Box("moov", mp4Box("mvhd", mvhd)),
+	}, nil))
+}
+
+func TestClipDuration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clip.mp4")
+	if err := os.WriteFile(path, []byte(fakeMP4(59500*time.Millisecond)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := clipDuration(path); d != 59500*time.Millisecond || err != nil {
+		t.Errorf("clipDuration() = %v, %v; want 59.5s", d, err)
+	}
+
+	truncated := filepath.Join(dir, "truncated.mp4")
+	if err := os.WriteFile(truncated, []byte(fakeMP4(time.Minute))[:20], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clipDuration(truncated); err == nil {
+		t.Error("clipDuration() of a truncated file succeeded, want an error")
+	}
+}
+
+func TestArchive_GroupsRecentClipsIntoDrives(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"RecentClips/2024-05-01/2024-05-01_23-58-00-front.mp4": fakeMP4(time.Minute),
+		"RecentClips/2024-05-01/2024-05-01_23-59-00-front.mp4": fakeMP4(time.Minute),
+		"RecentClips/2024-05-02/2024-05-02_00-00-00-front.mp4": fakeMP4(time.Minute),
+		"RecentClips/2024-05-02/2024-05-02_07-00-00-front.mp4": fakeMP4(time.Minute),
+	})
+	events, err := openTestArchive(t, root).Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].ID != "recent-2024-05-01_23-58-00" || len(events[1].Clips) != 3 || len(events[0].Clips) != 1 {
+		t.Errorf("Events() = %+v, want the drive past midnight as one event and the morning drive as another", events)
+	}
+}
+
+func TestArchive_Timeline(t *testing.T) {
+	root := t.TempDir()
+	event := "SentryClips/2024-05-03_22-03-10/"
+	writeFiles(t, root, map[string]string{
+		event + "2024-05-03_22-01-00-front.mp4":         fakeMP4(time.Minute),
+		event + "2024-05-03_22-01-00-back.mp4":          fakeMP4(59 * time.Second),
+		event + "2024-05-03_22-02-00-front.mp4":         fakeMP4(time.Minute),
+		event + "2024-05-03_22-03-00-front.mp4":         fakeMP4(30 * time.Second),
+		event + "2024-05-03_22-03-00-back.mp4":          fakeMP4(30 * time.Second),
+		event + "2024-05-03_22-03-00-left_repeater.mp4": "still being written",
+	})
+	timeline, err := openTestArchive(t, root).Timeline("sentry-2024-05-03_22-03-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !timeline.Start.Equal(time.Date(2024, 5, 3, 22, 1, 0, 0, time.UTC)) || timeline.DurationSeconds != 150 {
+		t.Errorf("timeline starts %v and lasts %vs, want 22:01:00 and 150s", timeline.Start, timeline.DurationSeconds)
+	}
+	var offsets []float64
+	for _, s := range timeline.Segments {
+		offsets = append(offsets, s.OffsetSeconds, s.DurationSeconds)
+	}
+	if want := []float64{0, 60, 60, 60, 120, 30}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("segment offsets and durations = %v, want %v", offsets, want)
+	}
+	if last := timeline.Segments[2].Clips; len(last) != 3 || last[2].Camera != "left_repeater" || last[2].DurationSeconds != 30 {
+		t.Errorf("last segment clips = %+v, want the unreadable clip to take the segment's duration", last)
+	}
+
+	ranges := make(map[string][][2]float64)
+	for _, track := range timeline.Tracks {
+		for _, r := range track.Ranges {
+			ranges[track.Camera] = append(ranges[track.Camera], [2]float64{r.StartSeconds, r.EndSeconds})
+		}
+	}
+	want := map[string][][2]float64{
+		"front":         {{0, 150}},
+		"back":          {{0, 59}, {120, 150}}, // No back clip for 22:02.
+		"left_repeater": {{120, 150}},
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("track ranges = %v, want %v", ranges, want)
+	}
+}
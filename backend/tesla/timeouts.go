@@ -0,0 +1,68 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Timeouts holds the per-operation deadlines applied to Client calls.
+type Timeouts struct {
+	Connect time.Duration // Establishing the SDK connection and session.
+	Stats   time.Duration // Fetching vehicle state.
+	Command time.Duration // Sending a command such as lock or unlock.
+	Camera  time.Duration // Fetching the camera feed.
+	Wake    time.Duration // Waiting for a sleeping vehicle to come online.
+}
+
+// DefaultTimeouts returns the deadlines used when nothing else is configured.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Connect: 60 * time.Second,
+		Stats:   20 * time.Second,
+		Command: 30 * time.Second,
+		Camera:  20 * time.Second,
+		Wake:    60 * time.Second,
+	}
+}
+
+// TimeoutsFromEnvironment returns DefaultTimeouts overridden by TESLA_CONNECT_TIMEOUT,
+// TESLA_STATS_TIMEOUT, TESLA_COMMAND_TIMEOUT, TESLA_CAMERA_TIMEOUT and TESLA_WAKE_TIMEOUT.
+// Values use time.ParseDuration syntax (e.g. "15s"); invalid values are logged and ignored.
+func TimeoutsFromEnvironment() Timeouts {
+	t := DefaultTimeouts()
+	readDurationEnv("TESLA_CONNECT_TIMEOUT", &t.Connect)
+	readDurationEnv("TESLA_STATS_TIMEOUT", &t.Stats)
+	readDurationEnv("TESLA_COMMAND_TIMEOUT", &t.Command)
+	readDurationEnv("TESLA_CAMERA_TIMEOUT", &t.Camera)
+	readDurationEnv("TESLA_WAKE_TIMEOUT", &t.Wake)
+	return t
+}
+
+func readDurationEnv(name string, dst *time.Duration) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Ignoring invalid %s=%q; using %s", name, value, *dst)
+		return
+	}
+	*dst = d
+}
+
+// contextError converts err into an ErrTimeout-wrapping error when ctx expired while op was running.
+// Cancellation by the caller is passed through unchanged so it can be told apart from a slow vehicle.
+func contextError(ctx context.Context, op string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w", op, ErrTimeout)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeoutsFromEnvironment(t *testing.T) {
+	t.Setenv("TESLA_STATS_TIMEOUT", "5s")
+	t.Setenv("TESLA_COMMAND_TIMEOUT", "not-a-duration")
+	t.Setenv("TESLA_CAMERA_TIMEOUT", "")
+
+	got := TimeoutsFromEnvironment()
+	want := DefaultTimeouts()
+	want.Stats = 5 * time.Second
+
+	if got != want {
+		t.Errorf("TimeoutsFromEnvironment() = %+v, want %+v", got, want)
+	}
+}
+
+func TestContextError(t *testing.T) {
+	sdkErr := errors.New("sdk failure")
+
+	if err := contextError(context.Background(), "locking vehicle", sdkErr); err != sdkErr {
+		t.Errorf("contextError with live context = %v, want %v", err, sdkErr)
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if err := contextError(expired, "locking vehicle", sdkErr); !errors.Is(err, ErrTimeout) {
+		t.Errorf("contextError with expired deadline = %v, want ErrTimeout", err)
+	}
+
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	err := contextError(cancelled, "locking vehicle", sdkErr)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Errorf("contextError with cancelled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,180 @@
+package tesla
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tire identifies one wheel position.
+type Tire string
+
+const (
+	TireFrontLeft  Tire = "front_left"
+	TireFrontRight Tire = "front_right"
+	TireRearLeft   Tire = "rear_left"
+	TireRearRight  Tire = "rear_right"
+)
+
+// Tires lists every wheel position in the order TirePressureState declares them.
+var Tires = []Tire{TireFrontLeft, TireFrontRight, TireRearLeft, TireRearRight}
+
+// fields returns t's pressure, recommended pressure and warning fields in ts.
+func (t Tire) fields(ts *TirePressureState) (pressure **float64, recommended *float64, warning **string, err error) {
+	switch t {
+	case TireFrontLeft:
+		return &ts.FrontLeftBar, ts.RecommendedFrontBar, &ts.FrontLeftWarning, nil
+	case TireFrontRight:
+		return &ts.FrontRightBar, ts.RecommendedFrontBar, &ts.FrontRightWarning, nil
+	case TireRearLeft:
+		return &ts.RearLeftBar, ts.RecommendedRearBar, &ts.RearLeftWarning, nil
+	case TireRearRight:
+		return &ts.RearRightBar, ts.RecommendedRearBar, &ts.RearRightWarning, nil
+	}
+	return nil, nil, nil, fmt.Errorf("unknown tire %q", t)
+}
+
+// TireThresholds are the pressures, in bar, outside which a tire raises an alert, independent of
+// the vehicle's own TPMS warnings.
+type TireThresholds struct {
+	LowBar  float64 `json:"low_bar"`
+	HighBar float64 `json:"high_bar"`
+}
+
+// DefaultTireThresholds returns the thresholds used when nothing else is configured: a band
+// around the 2.9 bar most Teslas recommend.
+func DefaultTireThresholds() TireThresholds {
+	return TireThresholds{LowBar: 2.6, HighBar: 3.3}
+}
+
+// TireThresholdsFromEnvironment returns DefaultTireThresholds overridden by TESLA_TIRE_LOW_BAR and
+// TESLA_TIRE_HIGH_BAR. Invalid values, or a low threshold that is not below the high one, are
+// logged and ignored.
+func TireThresholdsFromEnvironment() TireThresholds {
+	t := DefaultTireThresholds()
+	configured := t
+	readPressureEnv("TESLA_TIRE_LOW_BAR", &configured.LowBar)
+	readPressureEnv("TESLA_TIRE_HIGH_BAR", &configured.HighBar)
+	if configured.LowBar >= configured.HighBar {
+		log.Printf("Ignoring tire thresholds: low %g bar is not below high %g bar; using %g-%g bar",
+			configured.LowBar, configured.HighBar, t.LowBar, t.HighBar)
+		return t
+	}
+	return configured
+}
+
+func readPressureEnv(name string, dst *float64) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	bar, err := strconv.ParseFloat(value, 64)
+	if err != nil || bar <= 0 {
+		log.Printf("Ignoring invalid %s=%q; using %g", name, value, *dst)
+		return
+	}
+	*dst = bar
+}
+
+// TireReport is the TPMS view of a vehicle: each wheel's pressure and warning, and the alerts
+// they raise.
+type TireReport struct {
+	VIN        string         `json:"vin"`
+	FetchedAt  time.Time      `json:"fetched_at"`
+	Thresholds TireThresholds `json:"thresholds"`
+	Tires      []TireReading  `json:"tires"`
+	Alerts     []TireAlert    `json:"alerts"`
+}
+
+// TireReading is one wheel's TPMS data. Fields are null when the vehicle did not report them.
+type TireReading struct {
+	Position       Tire     `json:"position"`
+	PressureBar    *float64 `json:"pressure_bar"`
+	RecommendedBar *float64 `json:"recommended_bar"`
+	Warning        *string  `json:"warning"` // The vehicle's own flag: none, soft, hard.
+}
+
+// TireAlert explains why a wheel needs attention.
+type TireAlert struct {
+	Position Tire   `json:"position"`
+	Kind     string `json:"kind"`     // low, high, soft_warning, hard_warning
+	Severity string `json:"severity"` // warning, critical
+	Message  string `json:"message"`
+}
+
+// NewTireReport builds the TPMS report for state, raising an alert for every wheel the vehicle
+// warns about or whose pressure is outside thresholds. It returns an ErrNotSupported error if
+// state has no tire pressure data.
+func NewTireReport(state *VehicleState, thresholds TireThresholds) (*TireReport, error) {
+	if state.TirePressure == nil {
+		return nil, fmt.Errorf("tire pressure monitoring: %w", ErrNotSupported)
+	}
+	report := &TireReport{
+		VIN:        state.VIN,
+		FetchedAt:  state.FetchedAt,
+		Thresholds: thresholds,
+		Tires:      make([]TireReading, 0, len(Tires)),
+		Alerts:     []TireAlert{},
+	}
+	for _, tire := range Tires {
+		pressure, recommended, warning, _ := tire.fields(state.TirePressure)
+		reading := TireReading{Position: tire, PressureBar: *pressure, RecommendedBar: recommended, Warning: *warning}
+		report.Tires = append(report.Tires, reading)
+		report.Alerts = append(report.Alerts, reading.alerts(thresholds)...)
+	}
+	return report, nil
+}
+
+// alerts returns the alerts raised by r: the vehicle's warning first, then the thresholds.
+func (r TireReading) alerts(thresholds TireThresholds) []TireAlert {
+	var alerts []TireAlert
+	if r.Warning != nil {
+		switch *r.Warning {
+		case "hard":
+			alerts = append(alerts, TireAlert{Position: r.Position, Kind: "hard_warning", Severity: "critical",
+				Message: fmt.Sprintf("Vehicle reports a severe pressure loss in the %s tire", r.Position.name())})
+		case "soft":
+			alerts = append(alerts, TireAlert{Position: r.Position, Kind: "soft_warning", Severity: "warning",
+				Message: fmt.Sprintf("Vehicle reports low pressure in the %s tire", r.Position.name())})
+		}
+	}
+	if r.PressureBar == nil {
+		return alerts
+	}
+	switch bar := *r.PressureBar; {
+	case bar < thresholds.LowBar:
+		alerts = append(alerts, TireAlert{Position: r.Position, Kind: "low", Severity: "warning",
+			Message: fmt.Sprintf("%s tire is at %.2f bar, below %.2f bar", r.Position.title(), bar, thresholds.LowBar)})
+	case bar > thresholds.HighBar:
+		alerts = append(alerts, TireAlert{Position: r.Position, Kind: "high", Severity: "warning",
+			Message: fmt.Sprintf("%s tire is at %.2f bar, above %.2f bar", r.Position.title(), bar, thresholds.HighBar)})
+	}
+	return alerts
+}
+
+// name returns t in words, e.g. "front left".
+func (t Tire) name() string {
+	switch t {
+	case TireFrontLeft:
+		return "front left"
+	case TireFrontRight:
+		return "front right"
+	case TireRearLeft:
+		return "rear left"
+	case TireRearRight:
+		return "rear right"
+	}
+	return string(t)
+}
+
+// title returns name with a capital letter, to start a sentence.
+func (t Tire) title() string {
+	name := t.name()
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,106 @@
+package tesla
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewTireReport(t *testing.T) {
+	state := &VehicleState{
+		VIN: "VIN123",
+		TirePressure: &TirePressureState{
+			FrontLeftBar:        ptr(2.9),
+			FrontRightBar:       ptr(2.2),
+			RearLeftBar:         ptr(3.5),
+			RecommendedFrontBar: ptr(2.9),
+			RecommendedRearBar:  ptr(2.9),
+			FrontLeftWarning:    ptr("none"),
+			FrontRightWarning:   ptr("soft"),
+			RearRightWarning:    ptr("hard"),
+		},
+	}
+
+	report, err := NewTireReport(state, DefaultTireThresholds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Tires) != 4 || report.Tires[3].PressureBar != nil || *report.Tires[2].RecommendedBar != 2.9 {
+		t.Errorf("Tires = %+v", report.Tires)
+	}
+	type alert struct {
+		Position Tire
+		Kind     string
+		Severity string
+	}
+	var got []alert
+	for _, a := range report.Alerts {
+		got = append(got, alert{a.Position, a.Kind, a.Severity})
+	}
+	want := []alert{
+		{TireFrontRight, "soft_warning", "warning"},
+		{TireFrontRight, "low", "warning"},
+		{TireRearLeft, "high", "warning"},
+		{TireRearRight, "hard_warning", "critical"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alerts = %+v, want %+v", got, want)
+	}
+
+	if _, err := NewTireReport(&VehicleState{}, DefaultTireThresholds()); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("NewTireReport without tire pressure = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestTireThresholdsFromEnvironment(t *testing.T) {
+	t.Setenv("TESLA_TIRE_LOW_BAR", "2.4")
+	t.Setenv("TESLA_TIRE_HIGH_BAR", "lots")
+	if got, want := TireThresholdsFromEnvironment(), (TireThresholds{LowBar: 2.4, HighBar: 3.3}); got != want {
+		t.Errorf("TireThresholdsFromEnvironment() = %+v, want %+v", got, want)
+	}
+
+	t.Setenv("TESLA_TIRE_HIGH_BAR", "2.0")
+	if got, want := TireThresholdsFromEnvironment(), DefaultTireThresholds(); got != want {
+		t.Errorf("TireThresholdsFromEnvironment() with low above high = %+v, want %+v", got, want)
+	}
+}
+
+func TestSimulator_SlowLeak(t *testing.T) {
+	mc := newSimulatedMock(t)
+	if err := mc.Apply(SimAction{Action: "tire_leak", Tire: TireRearLeft, BarPerHour: ptr(0.1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	mc.Advance(3 * time.Hour)
+	ts := mc.Snapshot().TirePressure
+	if *ts.RearLeftBar != 2.6 || *ts.RearLeftWarning != "none" || *ts.FrontLeftBar != 2.9 {
+		t.Errorf("after 3h: rear left %v bar (%s), front left %v bar; want 2.6 (none), 2.9",
+			*ts.RearLeftBar, *ts.RearLeftWarning, *ts.FrontLeftBar)
+	}
+	mc.Advance(3 * time.Hour)
+	if ts = mc.Snapshot().TirePressure; *ts.RearLeftBar != 2.3 || *ts.RearLeftWarning != "soft" {
+		t.Errorf("after 6h: rear left %v bar (%s), want 2.3 (soft)", *ts.RearLeftBar, *ts.RearLeftWarning)
+	}
+	mc.Advance(24 * time.Hour)
+	if ts = mc.Snapshot().TirePressure; *ts.RearLeftBar != 0 || *ts.RearLeftWarning != "hard" {
+		t.Errorf("after 30h: rear left %v bar (%s), want 0 (hard)", *ts.RearLeftBar, *ts.RearLeftWarning)
+	}
+
+	if err := mc.Apply(SimAction{Action: "inflate_tire"}); err != nil {
+		t.Fatal(err)
+	}
+	mc.Advance(time.Hour)
+	if ts = mc.Snapshot().TirePressure; *ts.RearLeftBar != 2.9 || *ts.RearLeftWarning != "none" {
+		t.Errorf("after inflating: rear left %v bar (%s), want 2.9 (none)", *ts.RearLeftBar, *ts.RearLeftWarning)
+	}
+
+	for _, bad := range []SimAction{
+		{Action: "tire_leak", Tire: TireRearLeft},
+		{Action: "tire_leak", Tire: "spare", BarPerHour: ptr(0.1)},
+	} {
+		if err := mc.Apply(bad); err == nil {
+			t.Errorf("Apply(%+v) succeeded, want an error", bad)
+		}
+	}
+}
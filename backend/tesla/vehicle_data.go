@@ -0,0 +1,437 @@
+package tesla
+
+import (
+	"strings"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+)
+
+// newVehicleState converts the SDK's carserver.VehicleData into a VehicleState.
+// Categories missing from data are left nil, and optional protobuf fields that the vehicle did not
+// populate are left nil rather than reported as zero.
+func newVehicleState(vin string, data *carserver.VehicleData, fetchedAt time.Time) *VehicleState {
+	state := &VehicleState{
+		Version:   VehicleStateVersion,
+		VIN:       vin,
+		FetchedAt: fetchedAt.UTC(),
+	}
+	if data == nil {
+		return state
+	}
+	if cs := data.GetChargeState(); cs != nil {
+		state.Charge = chargeStateFromData(cs)
+	}
+	if cs := data.GetClimateState(); cs != nil {
+		state.Climate = climateStateFromData(cs)
+	}
+	if ds := data.GetDriveState(); ds != nil {
+		state.Drive = driveStateFromData(ds)
+	}
+	if ls := data.GetLocationState(); ls != nil {
+		state.Location = locationStateFromData(ls)
+	}
+	if cs := data.GetClosuresState(); cs != nil {
+		state.Closures = closuresStateFromData(cs)
+		state.Security = securityStateFromData(cs)
+	}
+	if ts := data.GetTirePressureState(); ts != nil {
+		state.TirePressure = tirePressureStateFromData(ts)
+	}
+	if ms := data.GetMediaState(); ms != nil {
+		state.Media = mediaStateFromData(ms)
+	}
+	if us := data.GetSoftwareUpdateState(); us != nil {
+		state.SoftwareUpdate = softwareUpdateStateFromData(us)
+	}
+	return state
+}
+
+func chargeStateFromData(cs *carserver.ChargeState) *ChargeState {
+	out := &ChargeState{}
+	if cs.GetOptionalBatteryLevel() != nil {
+		out.BatteryLevelPercent = ptr(int(cs.GetBatteryLevel()))
+	}
+	if cs.GetOptionalUsableBatteryLevel() != nil {
+		out.UsableBatteryLevelPercent = ptr(int(cs.GetUsableBatteryLevel()))
+	}
+	if cs.GetOptionalBatteryRange() != nil {
+		out.RangeMiles = ptr(float64(cs.GetBatteryRange()))
+	}
+	if cs.GetOptionalEstBatteryRange() != nil {
+		out.EstimatedRangeMiles = ptr(float64(cs.GetEstBatteryRange()))
+	}
+	if cs.GetOptionalChargeLimitSoc() != nil {
+		out.ChargeLimitPercent = ptr(int(cs.GetChargeLimitSoc()))
+	}
+	if cs.GetOptionalChargerPower() != nil {
+		out.ChargerPowerKW = ptr(int(cs.GetChargerPower()))
+	}
+	if cs.GetOptionalChargerVoltage() != nil {
+		out.ChargerVoltageVolts = ptr(int(cs.GetChargerVoltage()))
+	}
+	if cs.GetOptionalChargerActualCurrent() != nil {
+		out.ChargerCurrentAmps = ptr(int(cs.GetChargerActualCurrent()))
+	}
+	if cs.GetOptionalChargingAmps() != nil {
+		out.ChargingAmpsRequested = ptr(int(cs.GetChargingAmps()))
+	}
+	if cs.GetOptionalChargeRateMphFloat() != nil {
+		out.ChargeRateMPH = ptr(float64(cs.GetChargeRateMphFloat()))
+	} else if cs.GetOptionalChargeRateMph() != nil {
+		out.ChargeRateMPH = ptr(float64(cs.GetChargeRateMph()))
+	}
+	if cs.GetOptionalChargeEnergyAdded() != nil {
+		out.EnergyAddedKWh = ptr(float64(cs.GetChargeEnergyAdded()))
+	}
+	if cs.GetOptionalMinutesToFullCharge() != nil {
+		out.MinutesToFullCharge = ptr(int(cs.GetMinutesToFullCharge()))
+	}
+	if cs.GetOptionalChargePortDoorOpen() != nil {
+		out.ChargePortDoorOpen = ptr(cs.GetChargePortDoorOpen())
+	}
+	if cs.GetOptionalFastChargerPresent() != nil {
+		out.FastChargerPresent = ptr(cs.GetFastChargerPresent())
+	}
+	if cs.GetOptionalScheduledChargingStartTimeMinutes() != nil {
+		out.ScheduledChargingStartMin = ptr(int(cs.GetScheduledChargingStartTimeMinutes()))
+	}
+	if cs.GetOptionalScheduledChargingPending() != nil {
+		out.ScheduledChargingPending = ptr(cs.GetScheduledChargingPending())
+	}
+	if cs.GetOptionalScheduledChargingMode() != nil {
+		switch cs.GetScheduledChargingMode() {
+		case carserver.ChargeState_ScheduledChargingModeOff:
+			out.ScheduledChargingMode = ptr("off")
+		case carserver.ChargeState_ScheduledChargingModeStartAt:
+			out.ScheduledChargingMode = ptr("start_at")
+		case carserver.ChargeState_ScheduledChargingModeDepartBy:
+			out.ScheduledChargingMode = ptr("depart_by")
+		}
+	}
+
+	switch cs.GetChargingState().GetType().(type) {
+	case *carserver.ChargeState_ChargingState_Disconnected:
+		out.ChargingState = ptr("disconnected")
+	case *carserver.ChargeState_ChargingState_NoPower:
+		out.ChargingState = ptr("no_power")
+	case *carserver.ChargeState_ChargingState_Starting:
+		out.ChargingState = ptr("starting")
+	case *carserver.ChargeState_ChargingState_Charging:
+		out.ChargingState = ptr("charging")
+	case *carserver.ChargeState_ChargingState_Complete:
+		out.ChargingState = ptr("complete")
+	case *carserver.ChargeState_ChargingState_Stopped:
+		out.ChargingState = ptr("stopped")
+	case *carserver.ChargeState_ChargingState_Calibrating:
+		out.ChargingState = ptr("calibrating")
+	}
+
+	switch cs.GetChargePortLatch().GetType().(type) {
+	case *carserver.ChargePortLatchState_Engaged:
+		out.ChargePortLatch = ptr("engaged")
+	case *carserver.ChargePortLatchState_Disengaged:
+		out.ChargePortLatch = ptr("disengaged")
+	case *carserver.ChargePortLatchState_Blocking:
+		out.ChargePortLatch = ptr("blocking")
+	}
+
+	switch cs.GetConnChargeCable().GetType().(type) {
+	case *carserver.ChargeState_CableType_IEC:
+		out.ChargeCable = ptr("IEC")
+	case *carserver.ChargeState_CableType_SAE:
+		out.ChargeCable = ptr("SAE")
+	case *carserver.ChargeState_CableType_GB_AC:
+		out.ChargeCable = ptr("GB_AC")
+	case *carserver.ChargeState_CableType_GB_DC:
+		out.ChargeCable = ptr("GB_DC")
+	}
+	return out
+}
+
+func climateStateFromData(cs *carserver.ClimateState) *ClimateState {
+	out := &ClimateState{}
+	if cs.GetOptionalInsideTempCelsius() != nil {
+		out.InsideTempCelsius = ptr(float64(cs.GetInsideTempCelsius()))
+	}
+	if cs.GetOptionalOutsideTempCelsius() != nil {
+		out.OutsideTempCelsius = ptr(float64(cs.GetOutsideTempCelsius()))
+	}
+	if cs.GetOptionalDriverTempSetting() != nil {
+		out.DriverTempSettingCelsius = ptr(float64(cs.GetDriverTempSetting()))
+	}
+	if cs.GetOptionalPassengerTempSetting() != nil {
+		out.PassengerTempSettingCelsius = ptr(float64(cs.GetPassengerTempSetting()))
+	}
+	if cs.GetOptionalIsClimateOn() != nil {
+		out.IsClimateOn = ptr(cs.GetIsClimateOn())
+	}
+	if cs.GetOptionalFanStatus() != nil {
+		out.FanLevel = ptr(int(cs.GetFanStatus()))
+	}
+	if cs.GetOptionalIsFrontDefrosterOn() != nil {
+		out.IsFrontDefrosterOn = ptr(cs.GetIsFrontDefrosterOn())
+	}
+	if cs.GetOptionalIsRearDefrosterOn() != nil {
+		out.IsRearDefrosterOn = ptr(cs.GetIsRearDefrosterOn())
+	}
+	if cs.GetOptionalIsPreconditioning() != nil {
+		out.IsPreconditioning = ptr(cs.GetIsPreconditioning())
+	}
+	if cs.GetOptionalSeatHeaterLeft() != nil {
+		out.SeatHeaterLeftLevel = ptr(int(cs.GetSeatHeaterLeft()))
+	}
+	if cs.GetOptionalSeatHeaterRight() != nil {
+		out.SeatHeaterRightLevel = ptr(int(cs.GetSeatHeaterRight()))
+	}
+	if cs.GetOptionalSeatHeaterRearLeft() != nil {
+		out.SeatHeaterRearLeftLevel = ptr(int(cs.GetSeatHeaterRearLeft()))
+	}
+	if cs.GetOptionalSeatHeaterRearCenter() != nil {
+		out.SeatHeaterRearCenterLevel = ptr(int(cs.GetSeatHeaterRearCenter()))
+	}
+	if cs.GetOptionalSeatHeaterRearRight() != nil {
+		out.SeatHeaterRearRightLevel = ptr(int(cs.GetSeatHeaterRearRight()))
+	}
+	if cs.GetOptionalSteeringWheelHeater() != nil {
+		out.SteeringWheelHeaterOn = ptr(cs.GetSteeringWheelHeater())
+	}
+
+	switch cs.GetClimateKeeperMode().GetType().(type) {
+	case *carserver.ClimateState_ClimateKeeperMode_Off:
+		out.ClimateKeeperMode = ptr(string(ClimateKeeperOff))
+	case *carserver.ClimateState_ClimateKeeperMode_On:
+		out.ClimateKeeperMode = ptr(string(ClimateKeeperKeep))
+	case *carserver.ClimateState_ClimateKeeperMode_Dog:
+		out.ClimateKeeperMode = ptr(string(ClimateKeeperDog))
+	case *carserver.ClimateState_ClimateKeeperMode_Party:
+		out.ClimateKeeperMode = ptr(string(ClimateKeeperCamp))
+	}
+
+	switch cs.GetDefrostMode().GetType().(type) {
+	case *carserver.ClimateState_DefrostMode_Off:
+		out.DefrostMode = ptr("off")
+	case *carserver.ClimateState_DefrostMode_Normal:
+		out.DefrostMode = ptr("normal")
+	case *carserver.ClimateState_DefrostMode_Max:
+		out.DefrostMode = ptr("max")
+	}
+	return out
+}
+
+func driveStateFromData(ds *carserver.DriveState) *DriveState {
+	out := &DriveState{}
+	switch ds.GetShiftState().GetType().(type) {
+	case *carserver.ShiftState_P:
+		out.ShiftState = ptr("P")
+	case *carserver.ShiftState_R:
+		out.ShiftState = ptr("R")
+	case *carserver.ShiftState_N:
+		out.ShiftState = ptr("N")
+	case *carserver.ShiftState_D:
+		out.ShiftState = ptr("D")
+	}
+	if ds.GetOptionalSpeedFloat() != nil {
+		out.SpeedMPH = ptr(float64(ds.GetSpeedFloat()))
+	} else if ds.GetOptionalSpeed() != nil {
+		out.SpeedMPH = ptr(float64(ds.GetSpeed()))
+	}
+	if ds.GetOptionalPower() != nil {
+		out.PowerKW = ptr(int(ds.GetPower()))
+	}
+	if ds.GetOptionalOdometerInHundredthsOfAMile() != nil {
+		out.OdometerMiles = ptr(float64(ds.GetOdometerInHundredthsOfAMile()) / 100)
+	}
+	return out
+}
+
+func locationStateFromData(ls *carserver.LocationState) *LocationState {
+	out := &LocationState{}
+	if ls.GetOptionalLatitude() != nil {
+		out.Latitude = ptr(float64(ls.GetLatitude()))
+	}
+	if ls.GetOptionalLongitude() != nil {
+		out.Longitude = ptr(float64(ls.GetLongitude()))
+	}
+	if ls.GetOptionalHeading() != nil {
+		out.HeadingDegrees = ptr(int(ls.GetHeading()))
+	}
+	if ls.GetOptionalLocationName() != nil {
+		out.LocationName = ptr(ls.GetLocationName())
+	}
+	return out
+}
+
+func closuresStateFromData(cs *carserver.ClosuresState) *ClosuresState {
+	out := &ClosuresState{}
+	if cs.GetOptionalDoorOpenDriverFront() != nil {
+		out.DoorDriverFrontOpen = ptr(cs.GetDoorOpenDriverFront())
+	}
+	if cs.GetOptionalDoorOpenDriverRear() != nil {
+		out.DoorDriverRearOpen = ptr(cs.GetDoorOpenDriverRear())
+	}
+	if cs.GetOptionalDoorOpenPassengerFront() != nil {
+		out.DoorPassengerFrontOpen = ptr(cs.GetDoorOpenPassengerFront())
+	}
+	if cs.GetOptionalDoorOpenPassengerRear() != nil {
+		out.DoorPassengerRearOpen = ptr(cs.GetDoorOpenPassengerRear())
+	}
+	if cs.GetOptionalDoorOpenTrunkFront() != nil {
+		out.FrunkOpen = ptr(cs.GetDoorOpenTrunkFront())
+	}
+	if cs.GetOptionalDoorOpenTrunkRear() != nil {
+		out.TrunkOpen = ptr(cs.GetDoorOpenTrunkRear())
+	}
+	if cs.GetOptionalWindowOpenDriverFront() != nil {
+		out.WindowDriverFrontOpen = ptr(cs.GetWindowOpenDriverFront())
+	}
+	if cs.GetOptionalWindowOpenDriverRear() != nil {
+		out.WindowDriverRearOpen = ptr(cs.GetWindowOpenDriverRear())
+	}
+	if cs.GetOptionalWindowOpenPassengerFront() != nil {
+		out.WindowPassengerFrontOpen = ptr(cs.GetWindowOpenPassengerFront())
+	}
+	if cs.GetOptionalWindowOpenPassengerRear() != nil {
+		out.WindowPassengerRearOpen = ptr(cs.GetWindowOpenPassengerRear())
+	}
+	if cs.GetOptionalSunRoofPercentOpen() != nil {
+		out.SunroofPercentOpen = ptr(int(cs.GetSunRoofPercentOpen()))
+	}
+	if cs.GetOptionalTonneauPercentOpen() != nil {
+		out.TonneauPercentOpen = ptr(int(cs.GetTonneauPercentOpen()))
+	}
+	return out
+}
+
+func securityStateFromData(cs *carserver.ClosuresState) *SecurityState {
+	out := &SecurityState{}
+	if cs.GetOptionalLocked() != nil {
+		out.Locked = ptr(cs.GetLocked())
+	}
+	if cs.GetOptionalSentryModeAvailable() != nil {
+		out.SentryModeAvailable = ptr(cs.GetSentryModeAvailable())
+	}
+	if cs.GetOptionalValetMode() != nil {
+		out.ValetMode = ptr(cs.GetValetMode())
+	}
+	if cs.GetOptionalIsUserPresent() != nil {
+		out.UserPresent = ptr(cs.GetIsUserPresent())
+	}
+
+	switch cs.GetSentryModeState().GetType().(type) {
+	case *carserver.ClosuresState_SentryModeState_Off:
+		out.SentryMode = ptr("off")
+	case *carserver.ClosuresState_SentryModeState_Idle:
+		out.SentryMode = ptr("idle")
+	case *carserver.ClosuresState_SentryModeState_Armed:
+		out.SentryMode = ptr("armed")
+	case *carserver.ClosuresState_SentryModeState_Aware:
+		out.SentryMode = ptr("aware")
+	case *carserver.ClosuresState_SentryModeState_Panic:
+		out.SentryMode = ptr("panic")
+	case *carserver.ClosuresState_SentryModeState_Quiet:
+		out.SentryMode = ptr("quiet")
+	}
+	return out
+}
+
+func tirePressureStateFromData(ts *carserver.TirePressureState) *TirePressureState {
+	out := &TirePressureState{}
+	if ts.GetOptionalTpmsPressureFl() != nil {
+		out.FrontLeftBar = ptr(float64(ts.GetTpmsPressureFl()))
+	}
+	if ts.GetOptionalTpmsPressureFr() != nil {
+		out.FrontRightBar = ptr(float64(ts.GetTpmsPressureFr()))
+	}
+	if ts.GetOptionalTpmsPressureRl() != nil {
+		out.RearLeftBar = ptr(float64(ts.GetTpmsPressureRl()))
+	}
+	if ts.GetOptionalTpmsPressureRr() != nil {
+		out.RearRightBar = ptr(float64(ts.GetTpmsPressureRr()))
+	}
+	if ts.GetOptionalTpmsRcpFrontValue() != nil {
+		out.RecommendedFrontBar = ptr(float64(ts.GetTpmsRcpFrontValue()))
+	}
+	if ts.GetOptionalTpmsRcpRearValue() != nil {
+		out.RecommendedRearBar = ptr(float64(ts.GetTpmsRcpRearValue()))
+	}
+	out.FrontLeftWarning = tireWarning(ts.GetOptionalTpmsSoftWarningFl() != nil, ts.GetTpmsSoftWarningFl(), ts.GetOptionalTpmsHardWarningFl() != nil, ts.GetTpmsHardWarningFl())
+	out.FrontRightWarning = tireWarning(ts.GetOptionalTpmsSoftWarningFr() != nil, ts.GetTpmsSoftWarningFr(), ts.GetOptionalTpmsHardWarningFr() != nil, ts.GetTpmsHardWarningFr())
+	out.RearLeftWarning = tireWarning(ts.GetOptionalTpmsSoftWarningRl() != nil, ts.GetTpmsSoftWarningRl(), ts.GetOptionalTpmsHardWarningRl() != nil, ts.GetTpmsHardWarningRl())
+	out.RearRightWarning = tireWarning(ts.GetOptionalTpmsSoftWarningRr() != nil, ts.GetTpmsSoftWarningRr(), ts.GetOptionalTpmsHardWarningRr() != nil, ts.GetTpmsHardWarningRr())
+	return out
+}
+
+// tireWarning folds one tire's soft and hard warning flags into "none", "soft" or "hard". It is
+// nil when the vehicle reported neither flag.
+func tireWarning(softSet, soft, hardSet, hard bool) *string {
+	switch {
+	case hard:
+		return ptr("hard")
+	case soft:
+		return ptr("soft")
+	case softSet || hardSet:
+		return ptr("none")
+	}
+	return nil
+}
+
+func mediaStateFromData(ms *carserver.MediaState) *MediaState {
+	out := &MediaState{}
+	if ms.GetOptionalMediaPlaybackStatus() != nil {
+		out.PlaybackStatus = ptr(strings.ToLower(ms.GetMediaPlaybackStatus().String()))
+	}
+	if ms.GetOptionalNowPlayingSource() != nil {
+		out.Source = ptr(strings.TrimPrefix(ms.GetNowPlayingSource().String(), "MediaSourceType_"))
+	}
+	if ms.GetOptionalNowPlayingTitle() != nil {
+		out.NowPlayingTitle = ptr(ms.GetNowPlayingTitle())
+	}
+	if ms.GetOptionalNowPlayingArtist() != nil {
+		out.NowPlayingArtist = ptr(ms.GetNowPlayingArtist())
+	}
+	if ms.GetOptionalAudioVolume() != nil {
+		out.Volume = ptr(float64(ms.GetAudioVolume()))
+	}
+	if ms.GetOptionalAudioVolumeMax() != nil {
+		out.VolumeMax = ptr(float64(ms.GetAudioVolumeMax()))
+	}
+	if ms.GetOptionalRemoteControlEnabled() != nil {
+		out.RemoteControlEnabled = ptr(ms.GetRemoteControlEnabled())
+	}
+	return out
+}
+
+func softwareUpdateStateFromData(us *carserver.SoftwareUpdateState) *SoftwareUpdateState {
+	out := &SoftwareUpdateState{}
+	switch us.GetStatus().GetType().(type) {
+	case *carserver.SoftwareUpdateState_SoftwareUpdateStatus_Unknown:
+		out.Status = ptr("unknown")
+	case *carserver.SoftwareUpdateState_SoftwareUpdateStatus_Available:
+		out.Status = ptr("available")
+	case *carserver.SoftwareUpdateState_SoftwareUpdateStatus_Scheduled:
+		out.Status = ptr("scheduled")
+	case *carserver.SoftwareUpdateState_SoftwareUpdateStatus_Downloading:
+		out.Status = ptr("downloading")
+	case *carserver.SoftwareUpdateState_SoftwareUpdateStatus_DownloadingWifiWait:
+		out.Status = ptr("downloading_wifi_wait")
+	case *carserver.SoftwareUpdateState_SoftwareUpdateStatus_Installing:
+		out.Status = ptr("installing")
+	}
+	if us.GetOptionalVersion() != nil {
+		out.Version = ptr(us.GetVersion())
+	}
+	if us.GetOptionalDownloadPerc() != nil {
+		out.DownloadPercent = ptr(int(us.GetDownloadPerc()))
+	}
+	if us.GetOptionalInstallPerc() != nil {
+		out.InstallPercent = ptr(int(us.GetInstallPerc()))
+	}
+	if us.GetOptionalExpectedDurationSec() != nil {
+		out.ExpectedDurationSeconds = ptr(int(us.GetExpectedDurationSec()))
+	}
+	if us.GetOptionalScheduledTimeMs() != nil {
+		out.ScheduledAt = ptr(time.UnixMilli(int64(us.GetScheduledTimeMs())).UTC())
+	}
+	return out
+}
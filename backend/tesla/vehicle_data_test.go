@@ -0,0 +1,175 @@
+package tesla
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/teslamotors/vehicle-command/pkg/protocol/protobuf/carserver"
+)
+
+func TestNewVehicleState_ConvertsPopulatedFields(t *testing.T) {
+	data := &carserver.VehicleData{
+		ChargeState: &carserver.ChargeState{
+			OptionalBatteryLevel:   &carserver.ChargeState_BatteryLevel{BatteryLevel: 64},
+			OptionalBatteryRange:   &carserver.ChargeState_BatteryRange{BatteryRange: 180.5},
+			OptionalChargeLimitSoc: &carserver.ChargeState_ChargeLimitSoc{ChargeLimitSoc: 90},
+			ChargingState: &carserver.ChargeState_ChargingState{
+				Type: &carserver.ChargeState_ChargingState_Charging{Charging: &carserver.Void{}},
+			},
+		},
+		DriveState: &carserver.DriveState{
+			ShiftState: &carserver.ShiftState{Type: &carserver.ShiftState_D{D: &carserver.Void{}}},
+			OptionalOdometerInHundredthsOfAMile: &carserver.DriveState_OdometerInHundredthsOfAMile{
+				OdometerInHundredthsOfAMile: 1234567,
+			},
+		},
+		ClosuresState: &carserver.ClosuresState{
+			OptionalLocked:            &carserver.ClosuresState_Locked{Locked: false},
+			OptionalDoorOpenTrunkRear: &carserver.ClosuresState_DoorOpenTrunkRear{DoorOpenTrunkRear: true},
+		},
+	}
+	fetchedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	got := newVehicleState("VIN123", data, fetchedAt)
+
+	want := &VehicleState{
+		Version:   VehicleStateVersion,
+		VIN:       "VIN123",
+		FetchedAt: fetchedAt,
+		Charge: &ChargeState{
+			BatteryLevelPercent: ptr(64),
+			RangeMiles:          ptr(180.5),
+			ChargeLimitPercent:  ptr(90),
+			ChargingState:       ptr("charging"),
+		},
+		Drive: &DriveState{
+			ShiftState:    ptr("D"),
+			OdometerMiles: ptr(12345.67),
+		},
+		Closures: &ClosuresState{TrunkOpen: ptr(true)},
+		Security: &SecurityState{Locked: ptr(false)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("newVehicleState() = %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+// jsonKeys returns the sorted dotted key paths of v's JSON encoding, descending into objects.
+func jsonKeys(t *testing.T, v interface{}) []string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			keys = append(keys, prefix+k)
+			if child, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", child)
+			}
+		}
+	}
+	walk("", decoded)
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMockAndRealStatesShareJSONShape(t *testing.T) {
+	mockState, err := NewMockClient().GetVehicleStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An empty category still serialises every field (as null), so this is the real client's shape.
+	realState := newVehicleState("VIN123", &carserver.VehicleData{
+		ChargeState:         &carserver.ChargeState{},
+		ClimateState:        &carserver.ClimateState{},
+		DriveState:          &carserver.DriveState{},
+		LocationState:       &carserver.LocationState{},
+		ClosuresState:       &carserver.ClosuresState{},
+		TirePressureState:   &carserver.TirePressureState{},
+		MediaState:          &carserver.MediaState{},
+		SoftwareUpdateState: &carserver.SoftwareUpdateState{},
+	}, time.Now())
+
+	mockKeys, realKeys := jsonKeys(t, mockState), jsonKeys(t, realState)
+	if !reflect.DeepEqual(mockKeys, realKeys) {
+		t.Errorf("mock and real JSON shapes differ:\nmock: %v\nreal: %v", mockKeys, realKeys)
+	}
+}
+
+func TestNewVehicleState_ConvertsTiresMediaAndSoftwareUpdate(t *testing.T) {
+	data := &carserver.VehicleData{
+		TirePressureState: &carserver.TirePressureState{
+			OptionalTpmsPressureFl:    &carserver.TirePressureState_TpmsPressureFl{TpmsPressureFl: 2.5},
+			OptionalTpmsSoftWarningFl: &carserver.TirePressureState_TpmsSoftWarningFl{TpmsSoftWarningFl: true},
+			OptionalTpmsHardWarningFr: &carserver.TirePressureState_TpmsHardWarningFr{TpmsHardWarningFr: false},
+		},
+		MediaState: &carserver.MediaState{
+			OptionalMediaPlaybackStatus: &carserver.MediaState_MediaPlaybackStatus{MediaPlaybackStatus: carserver.MediaPlaybackStatus_Playing},
+		},
+		SoftwareUpdateState: &carserver.SoftwareUpdateState{
+			Status: &carserver.SoftwareUpdateState_SoftwareUpdateStatus{
+				Type: &carserver.SoftwareUpdateState_SoftwareUpdateStatus_Installing{Installing: &carserver.Void{}},
+			},
+			OptionalVersion:     &carserver.SoftwareUpdateState_Version{Version: "2025.2.6"},
+			OptionalInstallPerc: &carserver.SoftwareUpdateState_InstallPerc{InstallPerc: 40},
+		},
+	}
+
+	got := newVehicleState("VIN123", data, time.Now())
+
+	wantTires := &TirePressureState{FrontLeftBar: ptr(2.5), FrontLeftWarning: ptr("soft"), FrontRightWarning: ptr("none")}
+	if !reflect.DeepEqual(got.TirePressure, wantTires) {
+		t.Errorf("TirePressure = %+v, want %+v", got.TirePressure, wantTires)
+	}
+	if got.Media == nil || got.Media.PlaybackStatus == nil || *got.Media.PlaybackStatus != "playing" {
+		t.Errorf("Media = %+v, want playing", got.Media)
+	}
+	wantUpdate := &SoftwareUpdateState{Status: ptr("installing"), Version: ptr("2025.2.6"), InstallPercent: ptr(40)}
+	if !reflect.DeepEqual(got.SoftwareUpdate, wantUpdate) {
+		t.Errorf("SoftwareUpdate = %+v, want %+v", got.SoftwareUpdate, wantUpdate)
+	}
+}
+
+func TestParseStateCategories(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    []StateCategory
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "charge", want: []StateCategory{CategoryCharge}},
+		{in: "charge, tire_pressure,", want: []StateCategory{CategoryCharge, CategoryTirePressure}},
+		{in: "charge,tires", wantErr: true},
+	} {
+		got, err := ParseStateCategories(tc.in)
+		if (err != nil) != tc.wantErr || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseStateCategories(%q) = %v, %v; want %v, error %v", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestMockClient_GetVehicleStatsCategories(t *testing.T) {
+	state, err := NewMockClient().GetVehicleStats(context.Background(), CategoryClimate, CategoryTirePressure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Climate == nil || state.TirePressure == nil || state.Charge != nil || state.Security != nil || state.Media != nil {
+		t.Errorf("GetVehicleStats(climate, tire_pressure) = %+v", state)
+	}
+	if _, err := NewMockClient().GetVehicleStats(context.Background(), "tires"); err == nil {
+		t.Error("GetVehicleStats(tires) succeeded, want an error")
+	}
+}
@@ -0,0 +1,182 @@
+package tesla
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// VehicleStateVersion identifies the JSON shape of VehicleState. It is bumped whenever a field is
+// renamed, removed or changes units, so dashboards can detect a backend they don't understand.
+const VehicleStateVersion = 1
+
+// VehicleState is the typed snapshot returned by Client.GetVehicleStats.
+//
+// Every client (real, mock, ...) fills this same struct so dev mode and real mode serialise to an
+// identical JSON shape. A nil sub-struct means that category was not fetched; a nil field inside
+// a sub-struct means the vehicle did not report that value. Units are part of each field name.
+type VehicleState struct {
+	Version     int       `json:"version"`
+	VIN         string    `json:"vin"`
+	DisplayName string    `json:"display_name"`
+	FetchedAt   time.Time `json:"fetched_at"`
+
+	Charge   *ChargeState   `json:"charge"`
+	Climate  *ClimateState  `json:"climate"`
+	Drive    *DriveState    `json:"drive"`
+	Closures *ClosuresState `json:"closures"`
+	Location *LocationState `json:"location"`
+	Security *SecurityState `json:"security"`
+
+	TirePressure   *TirePressureState   `json:"tire_pressure"`
+	Media          *MediaState          `json:"media"`
+	SoftwareUpdate *SoftwareUpdateState `json:"software_update"`
+
+	// Errors maps each requested category that could not be fetched to the reason; that
+	// category's sub-struct is nil while the others are still filled in.
+	Errors map[StateCategory]string `json:"errors,omitempty"`
+}
+
+// ChargeState describes the battery and charging session.
+type ChargeState struct {
+	BatteryLevelPercent       *int     `json:"battery_level_percent"`
+	UsableBatteryLevelPercent *int     `json:"usable_battery_level_percent"`
+	RangeMiles                *float64 `json:"range_miles"`
+	EstimatedRangeMiles       *float64 `json:"estimated_range_miles"`
+	ChargingState             *string  `json:"charging_state"` // disconnected, no_power, starting, charging, complete, stopped, calibrating
+	ChargeLimitPercent        *int     `json:"charge_limit_percent"`
+	ChargerPowerKW            *int     `json:"charger_power_kw"`
+	ChargerVoltageVolts       *int     `json:"charger_voltage_volts"`
+	ChargerCurrentAmps        *int     `json:"charger_current_amps"`
+	ChargingAmpsRequested     *int     `json:"charging_amps_requested"`
+	ChargeRateMPH             *float64 `json:"charge_rate_mph"`
+	EnergyAddedKWh            *float64 `json:"energy_added_kwh"`
+	MinutesToFullCharge       *int     `json:"minutes_to_full_charge"`
+	ChargePortDoorOpen        *bool    `json:"charge_port_door_open"`
+	ChargePortLatch           *string  `json:"charge_port_latch"` // engaged, disengaged, blocking
+	ChargeCable               *string  `json:"charge_cable"`      // IEC, SAE, GB_AC, GB_DC
+	FastChargerPresent        *bool    `json:"fast_charger_present"`
+	ScheduledChargingMode     *string  `json:"scheduled_charging_mode"`          // off, start_at, depart_by
+	ScheduledChargingStartMin *int     `json:"scheduled_charging_start_minutes"` // Minutes after local midnight.
+	ScheduledChargingPending  *bool    `json:"scheduled_charging_pending"`
+}
+
+// ClimateState describes cabin temperatures and HVAC settings. Seat heater levels run 0 (off) to 3 (high).
+type ClimateState struct {
+	InsideTempCelsius           *float64 `json:"inside_temp_celsius"`
+	OutsideTempCelsius          *float64 `json:"outside_temp_celsius"`
+	DriverTempSettingCelsius    *float64 `json:"driver_temp_setting_celsius"`
+	PassengerTempSettingCelsius *float64 `json:"passenger_temp_setting_celsius"`
+	IsClimateOn                 *bool    `json:"is_climate_on"`
+	FanLevel                    *int     `json:"fan_level"`
+	IsFrontDefrosterOn          *bool    `json:"is_front_defroster_on"`
+	IsRearDefrosterOn           *bool    `json:"is_rear_defroster_on"`
+	IsPreconditioning           *bool    `json:"is_preconditioning"`
+	DefrostMode                 *string  `json:"defrost_mode"`        // off, normal, max
+	ClimateKeeperMode           *string  `json:"climate_keeper_mode"` // off, keep, dog, camp
+	SeatHeaterLeftLevel         *int     `json:"seat_heater_left_level"`
+	SeatHeaterRightLevel        *int     `json:"seat_heater_right_level"`
+	SeatHeaterRearLeftLevel     *int     `json:"seat_heater_rear_left_level"`
+	SeatHeaterRearCenterLevel   *int     `json:"seat_heater_rear_center_level"`
+	SeatHeaterRearRightLevel    *int     `json:"seat_heater_rear_right_level"`
+	SteeringWheelHeaterOn       *bool    `json:"steering_wheel_heater_on"`
+}
+
+// DriveState describes the vehicle's motion.
+type DriveState struct {
+	ShiftState    *string  `json:"shift_state"` // P, R, N, D
+	SpeedMPH      *float64 `json:"speed_mph"`
+	PowerKW       *int     `json:"power_kw"`
+	OdometerMiles *float64 `json:"odometer_miles"`
+}
+
+// ClosuresState describes doors, trunks, windows and the sunroof; true means open.
+type ClosuresState struct {
+	DoorDriverFrontOpen      *bool `json:"door_driver_front_open"`
+	DoorDriverRearOpen       *bool `json:"door_driver_rear_open"`
+	DoorPassengerFrontOpen   *bool `json:"door_passenger_front_open"`
+	DoorPassengerRearOpen    *bool `json:"door_passenger_rear_open"`
+	FrunkOpen                *bool `json:"frunk_open"`
+	TrunkOpen                *bool `json:"trunk_open"`
+	WindowDriverFrontOpen    *bool `json:"window_driver_front_open"`
+	WindowDriverRearOpen     *bool `json:"window_driver_rear_open"`
+	WindowPassengerFrontOpen *bool `json:"window_passenger_front_open"`
+	WindowPassengerRearOpen  *bool `json:"window_passenger_rear_open"`
+	SunroofPercentOpen       *int  `json:"sunroof_percent_open"`
+	TonneauPercentOpen       *int  `json:"tonneau_percent_open"`
+}
+
+// LocationState describes where the vehicle is.
+type LocationState struct {
+	Latitude       *float64 `json:"latitude"`
+	Longitude      *float64 `json:"longitude"`
+	HeadingDegrees *int     `json:"heading_degrees"`
+	LocationName   *string  `json:"location_name"`
+}
+
+// SecurityState describes locks and guard modes.
+type SecurityState struct {
+	Locked              *bool   `json:"locked"`
+	SentryMode          *string `json:"sentry_mode"` // off, idle, armed, aware, panic, quiet
+	SentryModeAvailable *bool   `json:"sentry_mode_available"`
+	ValetMode           *bool   `json:"valet_mode"`
+	UserPresent         *bool   `json:"user_present"`
+}
+
+// TirePressureState reports each tire's pressure and the vehicle's own low-pressure warnings.
+// A warning is "none", "soft" (slightly low) or "hard" (dangerously low).
+type TirePressureState struct {
+	FrontLeftBar        *float64 `json:"front_left_bar"`
+	FrontRightBar       *float64 `json:"front_right_bar"`
+	RearLeftBar         *float64 `json:"rear_left_bar"`
+	RearRightBar        *float64 `json:"rear_right_bar"`
+	RecommendedFrontBar *float64 `json:"recommended_front_bar"`
+	RecommendedRearBar  *float64 `json:"recommended_rear_bar"`
+	FrontLeftWarning    *string  `json:"front_left_warning"`
+	FrontRightWarning   *string  `json:"front_right_warning"`
+	RearLeftWarning     *string  `json:"rear_left_warning"`
+	RearRightWarning    *string  `json:"rear_right_warning"`
+}
+
+// MediaState describes what the infotainment system is playing.
+type MediaState struct {
+	PlaybackStatus       *string  `json:"playback_status"` // stopped, playing, paused
+	Source               *string  `json:"source"`          // e.g. Spotify, Bluetooth, FM
+	NowPlayingTitle      *string  `json:"now_playing_title"`
+	NowPlayingArtist     *string  `json:"now_playing_artist"`
+	Volume               *float64 `json:"volume"`
+	VolumeMax            *float64 `json:"volume_max"`
+	RemoteControlEnabled *bool    `json:"remote_control_enabled"`
+}
+
+// SoftwareUpdateState describes a pending or running firmware update.
+type SoftwareUpdateState struct {
+	Status                  *string    `json:"status"` // unknown, available, scheduled, downloading, downloading_wifi_wait, installing
+	Version                 *string    `json:"version"`
+	DownloadPercent         *int       `json:"download_percent"`
+	InstallPercent          *int       `json:"install_percent"`
+	ExpectedDurationSeconds *int       `json:"expected_duration_seconds"`
+	ScheduledAt             *time.Time `json:"scheduled_at"`
+}
+
+// Clone returns a deep copy of s, so callers can hand out snapshots of state they keep mutating.
+func (s *VehicleState) Clone() *VehicleState {
+	if s == nil {
+		return nil
+	}
+	// Every field is plain data, so a JSON round trip is a faithful deep copy.
+	data, err := json.Marshal(s)
+	if err != nil {
+		panic(fmt.Sprintf("tesla: cloning VehicleState: %v", err))
+	}
+	clone := new(VehicleState)
+	if err := json.Unmarshal(data, clone); err != nil {
+		panic(fmt.Sprintf("tesla: cloning VehicleState: %v", err))
+	}
+	return clone
+}
+
+// ptr returns a pointer to v; it keeps the nullable-field literals in the clients readable.
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,61 @@
+package tesla
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Backoff describes a bounded exponential retry schedule.
+type Backoff struct {
+	Initial    time.Duration // Delay before the first retry.
+	Max        time.Duration // Upper bound for any single delay.
+	Multiplier float64       // Growth factor between consecutive delays.
+}
+
+// DefaultWakeBackoff is the polling schedule used by WaitUntilOnline: 1s, 2s, 4s, 8s, then every 10s.
+var DefaultWakeBackoff = Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+
+// Delay returns how long to wait before retry number attempt, counting from 0.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := float64(b.Initial)
+	for i := 0; i < attempt && d < float64(b.Max); i++ {
+		d *= b.Multiplier
+	}
+	if d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+// WaitUntilOnline wakes client's vehicle if it is asleep and polls until it reports being online,
+// backing off between polls according to b. It returns nil as soon as the vehicle is online and
+// an error wrapping ErrTimeout if ctx expires first.
+func WaitUntilOnline(ctx context.Context, client Client, b Backoff) error {
+	online, err := client.IsOnline(ctx)
+	if err != nil {
+		return err
+	}
+	if online {
+		return nil
+	}
+	if _, err := client.Wake(ctx); err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		timer := time.NewTimer(b.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return contextError(ctx, "waiting for vehicle to wake", ctx.Err())
+		case <-timer.C:
+		}
+		online, err := client.IsOnline(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for vehicle to wake: %w", err)
+		}
+		if online {
+			return nil
+		}
+	}
+}
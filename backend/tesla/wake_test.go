@@ -0,0 +1,57 @@
+package tesla
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for attempt, w := range want {
+		if got := b.Delay(attempt); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, w)
+		}
+	}
+}
+
+// slowWaker is a vehicle that comes online a fixed number of polls after being woken.
+type slowWaker struct {
+	Client
+	woken      bool
+	pollsAwake int
+}
+
+func (s *slowWaker) Wake(ctx context.Context) (bool, error) {
+	s.woken = true
+	return true, nil
+}
+
+func (s *slowWaker) IsOnline(ctx context.Context) (bool, error) {
+	if !s.woken {
+		return false, nil
+	}
+	s.pollsAwake--
+	return s.pollsAwake <= 0, nil
+}
+
+func TestWaitUntilOnline(t *testing.T) {
+	fast := Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond, Multiplier: 2}
+
+	car := &slowWaker{pollsAwake: 3}
+	if err := WaitUntilOnline(context.Background(), car, fast); err != nil {
+		t.Fatalf("WaitUntilOnline() = %v, want nil", err)
+	}
+	if !car.woken {
+		t.Error("WaitUntilOnline did not wake the vehicle")
+	}
+
+	never := &slowWaker{pollsAwake: 1 << 30}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := WaitUntilOnline(ctx, never, fast); !errors.Is(err, ErrTimeout) {
+		t.Errorf("WaitUntilOnline() on a vehicle that never wakes = %v, want ErrTimeout", err)
+	}
+}
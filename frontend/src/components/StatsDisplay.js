@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { getStats } from '../services/api';
+import { getStats, streamStats } from '../services/api';
 import BatteryWidget from './widgets/BatteryWidget';
 import ChargingStatusWidget from './widgets/ChargingStatusWidget';
 import ClimateWidget from './widgets/ClimateWidget';
@@ -7,6 +7,16 @@ import SecurityWidget from './widgets/SecurityWidget';
 import DoorsWidget from './widgets/DoorsWidget';
 import DriveInfoWidget from './widgets/DriveInfoWidget';
 
+// mergeState applies the changed fields of a streamed state event to state.
+const mergeState = (state, changes) => {
+  const merged = { ...state };
+  for (const [field, value] of Object.entries(changes)) {
+    const isObject = value !== null && typeof value === 'object' && !Array.isArray(value);
+    merged[field] = isObject && merged[field] ? mergeState(merged[field], value) : value;
+  }
+  return merged;
+};
+
 const StatsDisplay = ({ isDevMode, apiKey }) => {
   const [stats, setStats] = useState(null);
   // Helper function to safely access nested properties, especially for protobuf wrapper types
@@ -43,70 +53,76 @@ const StatsDisplay = ({ isDevMode, apiKey }) => {
     };
 
     fetchStats();
+    // Keep the stats current with the changes the backend pushes.
+    const closeStream = streamStats(isDevMode, apiKey, {
+      onSnapshot: setStats,
+      onChanges: (changes) => setStats((current) => mergeState(current, changes)),
+    });
+    return () => closeStream?.();
   }, [isDevMode, apiKey]);
 
   if (isLoading) return <p className="loading-text">Loading stats...</p>;
   if (error) return <p className="error-text">Error: {error}</p>;
   if (!stats) return <p className="info-text">No stats to display. {isDevMode ? "" : "Ensure API key is correct or submit one."}</p>;
 
-  // Destructure states for easier access, assuming they exist based on carserver.VehicleData structure
-  const chargeState = stats.chargeState || {};
-  const climateState = stats.climateState || {};
-  const closuresState = stats.closuresState || {};
-  const driveState = stats.driveState || {};
-  const vehicleState = stats.vehicleState || {}; // For Sentry Mode, Lock status etc.
-  const locationState = stats.locationState || {}; // For heading, latitude, longitude
+  // Destructure the typed VehicleState sub-objects (see backend/tesla/vehicle_state.go)
+  const chargeState = stats.charge || {};
+  const climateState = stats.climate || {};
+  const closuresState = stats.closures || {};
+  const driveState = stats.drive || {};
+  const securityState = stats.security || {};
+  const locationState = stats.location || {};
 
   return (
     <div className="component-section stats-display-container">
       <h2>Vehicle Dashboard</h2>
       <div className="widgets-container">
         <BatteryWidget
-          level={getValue(chargeState.batteryLevel, null)}
-          usableLevel={getValue(chargeState.usableBatteryLevel, null)}
-          range={getValue(chargeState.batteryRange, null)} // Assuming batteryRange is 'charge_energy_added' / efficiency or similar
-          chargingState={getValue(chargeState.chargingState, '')} // e.g. "Charging", "Stopped"
+          level={getValue(chargeState.battery_level_percent, null)}
+          usableLevel={getValue(chargeState.usable_battery_level_percent, null)}
+          range={getValue(chargeState.range_miles, null)}
+          chargingState={getValue(chargeState.charging_state, '')}
         />
         <ChargingStatusWidget
-          chargingState={getValue(chargeState.chargingState, '')}
-          chargeLimit={getValue(chargeState.chargeLimitSoc, null)}
-          timeToFull={getValue(chargeState.minutesToFullCharge, null)}
-          chargerPower={getValue(chargeState.chargerPower, null)}
-          chargeRateMph={getValue(chargeState.chargeRate, null)} // chargeRate is often in mi/hr or km/hr
-          connChargeCable={getValue(chargeState.connChargeCable, '')}
-          fastChargerPresent={getValue(chargeState.fastChargerPresent, false)}
+          chargingState={getValue(chargeState.charging_state, '')}
+          chargeLimit={getValue(chargeState.charge_limit_percent, null)}
+          timeToFull={getValue(chargeState.minutes_to_full_charge, null)}
+          chargerPower={getValue(chargeState.charger_power_kw, null)}
+          chargeRateMph={getValue(chargeState.charge_rate_mph, null)}
+          connChargeCable={getValue(chargeState.charge_cable, '')}
+          fastChargerPresent={getValue(chargeState.fast_charger_present, false)}
         />
         <ClimateWidget
-          insideTemp={getValue(climateState.insideTemp, null)}
-          outsideTemp={getValue(climateState.outsideTemp, null)}
-          isClimateOn={getValue(climateState.isClimateOn, false)}
-          fanStatus={getValue(climateState.fanStatus, null)}
-          driverTempSetting={getValue(climateState.driverTempSetting, null)}
-          passengerTempSetting={getValue(climateState.passengerTempSetting, null)}
+          insideTemp={getValue(climateState.inside_temp_celsius, null)}
+          outsideTemp={getValue(climateState.outside_temp_celsius, null)}
+          isClimateOn={getValue(climateState.is_climate_on, false)}
+          fanStatus={getValue(climateState.fan_level, null)}
+          driverTempSetting={getValue(climateState.driver_temp_setting_celsius, null)}
+          passengerTempSetting={getValue(climateState.passenger_temp_setting_celsius, null)}
         />
         <SecurityWidget
-          locked={getValue(vehicleState.locked, null)} // VehicleState often has lock status
-          sentryMode={getValue(vehicleState.sentryMode, false)} // And Sentry mode
-          sentryModeAvailable={getValue(vehicleState.sentryModeAvailable, false)}
+          locked={getValue(securityState.locked, null)}
+          sentryMode={getValue(securityState.sentry_mode, 'off') !== 'off'}
+          sentryModeAvailable={getValue(securityState.sentry_mode_available, false)}
         />
         <DoorsWidget
-          doorDriverFrontOpen={getValue(closuresState.df, false)} // df, pf, dr, pr are common Tesla API closure names
-          doorPassengerFrontOpen={getValue(closuresState.pf, false)}
-          doorDriverRearOpen={getValue(closuresState.dr, false)}
-          doorPassengerRearOpen={getValue(closuresState.pr, false)}
-          frunkOpen={getValue(closuresState.ft, false)} // ft for frunk
-          trunkOpen={getValue(closuresState.rt, false)} // rt for rear trunk
-          windowDriverFrontOpen={getValue(closuresState.fdWindow, false)} // Example, actual names may vary
-          windowPassengerFrontOpen={getValue(closuresState.fpWindow, false)}
-          windowDriverRearOpen={getValue(closuresState.rdWindow, false)}
-          windowPassengerRearOpen={getValue(closuresState.rpWindow, false)}
+          doorDriverFrontOpen={getValue(closuresState.door_driver_front_open, false)}
+          doorPassengerFrontOpen={getValue(closuresState.door_passenger_front_open, false)}
+          doorDriverRearOpen={getValue(closuresState.door_driver_rear_open, false)}
+          doorPassengerRearOpen={getValue(closuresState.door_passenger_rear_open, false)}
+          frunkOpen={getValue(closuresState.frunk_open, false)}
+          trunkOpen={getValue(closuresState.trunk_open, false)}
+          windowDriverFrontOpen={getValue(closuresState.window_driver_front_open, false)}
+          windowPassengerFrontOpen={getValue(closuresState.window_passenger_front_open, false)}
+          windowDriverRearOpen={getValue(closuresState.window_driver_rear_open, false)}
+          windowPassengerRearOpen={getValue(closuresState.window_passenger_rear_open, false)}
         />
         <DriveInfoWidget
-          shiftState={getValue(driveState.shiftState, '')} // E.g., "P", "D", "R"
-          speed={getValue(driveState.speed, null)} // Speed might be in a different field or nested
-          power={getValue(driveState.power, null)}
-          odometer={getValue(vehicleState.odometer, null)} // Odometer is often in VehicleState
-          heading={getValue(locationState.heading, null)}
+          shiftState={getValue(driveState.shift_state, '')}
+          speed={getValue(driveState.speed_mph, null)}
+          power={getValue(driveState.power_kw, null)}
+          odometer={getValue(driveState.odometer_miles, null)}
+          heading={getValue(locationState.heading_degrees, null)}
           latitude={getValue(locationState.latitude, null)}
           longitude={getValue(locationState.longitude, null)}
         />
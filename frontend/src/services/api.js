@@ -17,8 +17,8 @@ async function request(endpoint, options = {}) {
   try {
     const response = await fetch(url, config);
     if (!response.ok) {
-      const errorData = await response.json().catch(() => ({ message: 'An unknown error occurred' }));
-      throw new Error(errorData.message || `HTTP error! status: ${response.status}`);
+      const errorData = await response.json().catch(() => ({ error: { message: 'An unknown error occurred' } }));
+      throw new Error(errorData.error?.message || `HTTP error! status: ${response.status}`);
     }
     if (response.status === 204 || response.headers.get("content-length") === "0") { // No Content
         return null;
@@ -53,3 +53,61 @@ export const getCameraFeed = async (isDevMode, apiKey) => {
   const headers = !isDevMode && apiKey ? { 'X-API-KEY': apiKey } : {};
   return request(endpoint, { headers });
 };
+
+// streamStats follows the vehicle state the backend pushes as it changes (see
+// backend/handlers/stream.go), reconnecting with Last-Event-ID so no change is missed. onSnapshot
+// gets a whole state; onChanges gets only the fields that changed, with null for removed ones.
+// fetch is used instead of EventSource so the API key can be sent as a header.
+// Returns a function that closes the stream.
+export const streamStats = (isDevMode, apiKey, { onSnapshot, onChanges }) => {
+  const endpoint = isDevMode ? '/dev/stream' : '/stream';
+  const controller = new AbortController();
+  let lastEventId = '';
+  let retryMs = 5000;
+
+  const dispatch = (block) => {
+    const event = {};
+    for (const line of block.split('\n')) {
+      const sep = line.indexOf(': ');
+      if (!line.startsWith(':') && sep > 0) { // Lines starting with ':' are heartbeats.
+        event[line.slice(0, sep)] = line.slice(sep + 2);
+      }
+    }
+    if (event.retry) retryMs = Number(event.retry);
+    if (event.id) lastEventId = event.id;
+    if (event.event === 'snapshot') onSnapshot(JSON.parse(event.data));
+    if (event.event === 'state') onChanges(JSON.parse(event.data));
+  };
+
+  const follow = async () => {
+    while (!controller.signal.aborted) {
+      try {
+        const headers = !isDevMode && apiKey ? { 'X-API-KEY': apiKey } : {};
+        if (lastEventId) headers['Last-Event-ID'] = lastEventId;
+        const response = await fetch(`${API_BASE_URL}${endpoint}`, { headers, signal: controller.signal });
+        if (!response.ok) {
+          throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+        let buffer = '';
+        for (;;) {
+          const { value, done } = await reader.read();
+          if (done) break;
+          buffer += value;
+          let end;
+          while ((end = buffer.indexOf('\n\n')) >= 0) {
+            dispatch(buffer.slice(0, end));
+            buffer = buffer.slice(end + 2);
+          }
+        }
+      } catch (error) {
+        if (controller.signal.aborted) return;
+        console.error('Stats stream error:', error);
+      }
+      await new Promise((resolve) => setTimeout(resolve, retryMs));
+    }
+  };
+
+  follow();
+  return () => controller.abort();
+};